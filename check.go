@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+
+	"github.com/hashicorp/cli"
+	"github.com/ship-digital/pull-watch/internal/config"
+	"github.com/ship-digital/pull-watch/internal/git"
+	"github.com/ship-digital/pull-watch/internal/logger"
+	"github.com/ship-digital/pull-watch/internal/runner"
+)
+
+// Exit codes of the check command, one per comparison result
+const (
+	checkExitEqual    = 0
+	checkExitError    = 1
+	checkExitBehind   = 2
+	checkExitAhead    = 3
+	checkExitDiverged = 4
+)
+
+type CheckCommand struct {
+	ui cli.Ui
+
+	commonFlags
+	remoteFlags
+	format string
+}
+
+func (c *CheckCommand) setupFlags(flags *flag.FlagSet) {
+	c.commonFlags.setupFlags(flags)
+	c.remoteFlags.setupFlags(flags)
+	flags.StringVar(&c.format, "format", "text", "Output format: 'text' or 'json'")
+}
+
+func (c *CheckCommand) Run(args []string) int {
+	flags := flag.NewFlagSet("check", flag.ContinueOnError)
+	c.setupFlags(flags)
+	if err := parseFlags(c.ui, flags, args); err != nil {
+		return checkExitError
+	}
+
+	if c.format != "text" && c.format != "json" {
+		c.ui.Error(fmt.Sprintf("Error: invalid format %q (expected \"text\" or \"json\")", c.format))
+		return checkExitError
+	}
+
+	// Keep stderr quiet by default, cron mails every line it sees
+	logLevel := c.logLevel(logger.QuietLevel)
+	cfg := &config.Config{
+		GitDir:   c.gitDir,
+		LogLevel: logLevel,
+		Logger:   c.newLogger(logLevel),
+	}
+	if err := c.remoteFlags.apply(cfg); err != nil {
+		c.ui.Error(fmt.Sprintf("Error: %v", err))
+		return checkExitError
+	}
+
+	result, err := runner.Check(cfg)
+	if err != nil {
+		reportError(c.ui, err)
+		return checkExitError
+	}
+
+	if c.format == "json" {
+		out, err := json.Marshal(struct {
+			Status       string `json:"status"`
+			Branch       string `json:"branch"`
+			LocalCommit  string `json:"local_commit"`
+			RemoteCommit string `json:"remote_commit"`
+		}{
+			Status:       result.Status.String(),
+			Branch:       result.Branch,
+			LocalCommit:  result.LocalCommit,
+			RemoteCommit: result.RemoteCommit,
+		})
+		if err != nil {
+			c.ui.Error(fmt.Sprintf("Error: %v", err))
+			return checkExitError
+		}
+		c.ui.Output(string(out))
+	} else {
+		c.ui.Output(fmt.Sprintf("%s %s %s %s", result.Status, result.Branch, result.LocalCommit, result.RemoteCommit))
+	}
+
+	switch result.Status {
+	case git.CommitsEqual:
+		return checkExitEqual
+	case git.AIsAncestorOfB:
+		return checkExitBehind
+	case git.BIsAncestorOfA:
+		return checkExitAhead
+	case git.CommitsDiverged:
+		return checkExitDiverged
+	default:
+		return checkExitError
+	}
+}
+
+func (c *CheckCommand) Help() string {
+	return fmt.Sprintf(`
+Usage: pull-watch check [options]
+
+ Compare the local branch with its upstream once, without pulling.
+
+ Prints "<status> <branch> <local commit> <remote commit>" (or a JSON object
+ with -format json) where status is one of equal, behind, ahead or diverged.
+
+Exit codes:
+  %d  equal
+  %d  error
+  %d  behind
+  %d  ahead
+  %d  diverged
+
+Options:
+%s`, checkExitEqual, checkExitError, checkExitBehind, checkExitAhead, checkExitDiverged, flagDefaults(c.setupFlags))
+}
+
+func (c *CheckCommand) Synopsis() string {
+	return "Compare local and remote commits once and exit"
+}
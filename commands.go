@@ -0,0 +1,234 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/cli"
+	"github.com/ship-digital/pull-watch/internal/config"
+	"github.com/ship-digital/pull-watch/internal/git"
+	"github.com/ship-digital/pull-watch/internal/logger"
+	"github.com/ship-digital/pull-watch/internal/runner"
+)
+
+// commonFlags are the flags shared by the subcommands
+type commonFlags struct {
+	gitDir        string
+	quiet         bool
+	verbose       bool
+	showTimestamp bool
+}
+
+func (f *commonFlags) setupFlags(flags *flag.FlagSet) {
+	flags.StringVar(&f.gitDir, "git-dir", ".", "Git repository directory")
+	flags.BoolVar(&f.verbose, "verbose", false, "Enable verbose logging")
+	flags.BoolVar(&f.quiet, "quiet", false, "Show only errors and warnings")
+	flags.BoolVar(&f.showTimestamp, "timestamp", false, "Show timestamps in logs")
+}
+
+// logLevel returns the level selected by the flags, defaulting to def
+func (f *commonFlags) logLevel(def logger.LogLevel) logger.LogLevel {
+	switch {
+	case f.verbose:
+		return logger.VerboseLevel
+	case f.quiet:
+		return logger.QuietLevel
+	default:
+		return def
+	}
+}
+
+func (f *commonFlags) newLogger(level logger.LogLevel) *logger.Logger {
+	opts := []logger.Option{logger.WithLogLevel(level)}
+	if f.showTimestamp {
+		opts = append(opts, logger.WithTimestamp())
+	}
+	return logger.New(opts...)
+}
+
+// remoteFlags are the flags of the commands that talk to the remote
+type remoteFlags struct {
+	sshKey          string
+	knownHosts      string
+	tokenEnv        string
+	tokenFile       string
+	tokenUser       string
+	lsRemoteTimeout time.Duration
+	fetchTimeout    time.Duration
+	pullTimeout     time.Duration
+	retries         int
+	retryBackoff    time.Duration
+	staleLockAge    time.Duration
+	pullStrategy    string
+	submodules      string
+	submoduleRemote bool
+	lfs             bool
+	lfsTimeout      time.Duration
+	sparse          listFlag
+	detect          string
+	fetchDepth      int
+	fetchFilter     string
+}
+
+func (f *remoteFlags) setupFlags(flags *flag.FlagSet) {
+	flags.StringVar(&f.sshKey, "git-ssh-key", "", "SSH private key `file` git uses for the remote, instead of the agent and ~/.ssh")
+	flags.StringVar(&f.knownHosts, "git-known-hosts", "", "known_hosts `file` the remote's SSH host key must be in")
+	flags.StringVar(&f.tokenEnv, "git-token-env", "", "Environment `variable` holding an HTTPS token for the remote")
+	flags.StringVar(&f.tokenFile, "git-token-file", "", "`File` holding an HTTPS token for the remote, read again for every fetch so it can be rotated")
+	flags.StringVar(&f.tokenUser, "git-token-user", "x-access-token", "Username sent with the HTTPS token")
+	flags.DurationVar(&f.lsRemoteTimeout, "ls-remote-timeout", git.DefaultLsRemoteTimeout, "Timeout of each attempt to look up the remote commit")
+	flags.DurationVar(&f.fetchTimeout, "fetch-timeout", git.DefaultFetchTimeout, "Timeout of each attempt to fetch from the remote")
+	flags.DurationVar(&f.pullTimeout, "pull-timeout", git.DefaultPullTimeout, "Timeout of each attempt to pull from the remote")
+	flags.IntVar(&f.retries, "git-retries", 3, "Retries of remote git operations failing with network errors, timeouts or server errors (5xx)")
+	flags.DurationVar(&f.retryBackoff, "git-retry-backoff", time.Second, "Wait before the first retry, doubled (with jitter) for each one after")
+	flags.DurationVar(&f.staleLockAge, "stale-lock-age", git.DefaultStaleLockAge, "Age at which lock files and unfinished merges or rebases left by an interrupted git are cleaned up before pulling (0 never cleans up)")
+	flags.StringVar(&f.pullStrategy, "pull-strategy", config.PullFFOnly, "How remote commits are pulled, whatever the git config says: 'ff-only' (leave a diverged branch alone), 'rebase' (rebase local commits onto them), 'merge' (merge them) or 'reset' (discard local commits and changes)")
+	flags.StringVar(&f.submodules, "submodules", config.SubmodulesOff, "What happens to submodules after a pull: 'off', 'update' (check out the pinned commits, rolling back the pull if that fails) or 'recursive' (the same for nested submodules)")
+	flags.BoolVar(&f.submoduleRemote, "submodule-remote", false, "Also restart when a submodule's branch, set in .gitmodules, moves on its remote (needs -submodules)")
+	flags.BoolVar(&f.lfs, "lfs", false, "Fetch and check out Git LFS files after every pull, rolling back the pull if that fails (needs git-lfs)")
+	flags.DurationVar(&f.lfsTimeout, "lfs-timeout", git.DefaultLFSTimeout, "Timeout of each attempt to fetch LFS objects")
+	flags.Var(&f.sparse, "sparse", "Only check out these `directories` (cone mode sparse checkout, comma separated or repeated), pulls that change nothing in them don't restart the command")
+	flags.StringVar(&f.detect, "detect", config.DetectLsRemote, "How new remote commits are found: 'ls-remote' (fetch only when a commit is missing) or 'fetch' (fetch the upstream branch and compare locally, for shallow and partial clones)")
+	flags.IntVar(&f.fetchDepth, "fetch-depth", 0, "Keep the clone shallow, fetching this many commits and deepening only when needed to relate two commits (needs -detect fetch, 0 fetches the full history)")
+	flags.StringVar(&f.fetchFilter, "fetch-filter", "", "Partial clone `filter` used when fetching, like blob:none (needs -detect fetch)")
+}
+
+// apply validates the flags and sets them on cfg
+func (f *remoteFlags) apply(cfg *config.Config) error {
+	auth, err := f.gitAuth()
+	if err != nil {
+		return err
+	}
+	if f.retries < 0 {
+		return fmt.Errorf("invalid -git-retries %d", f.retries)
+	}
+	switch f.pullStrategy {
+	case config.PullFFOnly, config.PullRebase, config.PullMerge, config.PullReset:
+	default:
+		return fmt.Errorf("invalid -pull-strategy %q (expected %q, %q, %q or %q)", f.pullStrategy, config.PullFFOnly, config.PullRebase, config.PullMerge, config.PullReset)
+	}
+	switch f.submodules {
+	case config.SubmodulesOff, config.SubmodulesUpdate, config.SubmodulesRecursive:
+	default:
+		return fmt.Errorf("invalid -submodules %q (expected %q, %q or %q)", f.submodules, config.SubmodulesOff, config.SubmodulesUpdate, config.SubmodulesRecursive)
+	}
+	if f.submoduleRemote && f.submodules == config.SubmodulesOff {
+		return fmt.Errorf("-submodule-remote needs -submodules update or recursive")
+	}
+	switch f.detect {
+	case config.DetectLsRemote, config.DetectFetch:
+	default:
+		return fmt.Errorf("invalid -detect %q (expected %q or %q)", f.detect, config.DetectLsRemote, config.DetectFetch)
+	}
+	if f.fetchDepth < 0 {
+		return fmt.Errorf("invalid -fetch-depth %d", f.fetchDepth)
+	}
+	if (f.fetchDepth > 0 || f.fetchFilter != "") && f.detect != config.DetectFetch {
+		return fmt.Errorf("-fetch-depth and -fetch-filter need -detect fetch")
+	}
+	sparse, err := f.sparseDirs()
+	if err != nil {
+		return err
+	}
+	cfg.Auth = auth
+	cfg.GitTimeouts = config.GitTimeouts{LsRemote: f.lsRemoteTimeout, Fetch: f.fetchTimeout, Pull: f.pullTimeout, LFS: f.lfsTimeout}
+	cfg.GitRetries = f.retries
+	cfg.RetryBackoff = f.retryBackoff
+	cfg.StaleLockAge = f.staleLockAge
+	cfg.PullStrategy = f.pullStrategy
+	cfg.Submodules = config.Submodules{Mode: f.submodules, Remote: f.submoduleRemote}
+	cfg.LFS = f.lfs
+	cfg.Sparse = sparse
+	cfg.Detection = config.Detection{Strategy: f.detect, Depth: f.fetchDepth, Filter: f.fetchFilter}
+	return nil
+}
+
+// sparseDirs validates the sparse checkout directories, relative to the top of
+// the working tree and with slashes as git wants them in cone mode
+func (f *remoteFlags) sparseDirs() ([]string, error) {
+	var dirs []string
+	for _, dir := range f.sparse {
+		clean := path.Clean(strings.Trim(filepath.ToSlash(dir), "/"))
+		if clean == "." || clean == ".." || strings.HasPrefix(clean, "../") || strings.HasPrefix(clean, "-") || strings.ContainsAny(clean, "*?[") {
+			return nil, fmt.Errorf("invalid -sparse %q (expected a directory inside the repository)", dir)
+		}
+		dirs = append(dirs, clean)
+	}
+	return dirs, nil
+}
+
+// gitAuth validates the credentials, files are made absolute as git runs in the repository
+func (f *remoteFlags) gitAuth() (config.GitAuth, error) {
+	auth := config.GitAuth{TokenEnv: f.tokenEnv, TokenUser: f.tokenUser}
+	if f.tokenEnv != "" && f.tokenFile != "" {
+		return auth, errors.New("-git-token-env and -git-token-file can't be used together")
+	}
+	for _, file := range []struct {
+		flag string
+		path string
+		dest *string
+	}{
+		{"-git-ssh-key", f.sshKey, &auth.SSHKey},
+		{"-git-known-hosts", f.knownHosts, &auth.KnownHosts},
+		{"-git-token-file", f.tokenFile, &auth.TokenFile},
+	} {
+		if file.path == "" {
+			continue
+		}
+		path, err := filepath.Abs(file.path)
+		if err != nil {
+			return auth, fmt.Errorf("invalid %s: %w", file.flag, err)
+		}
+		if _, err := os.Stat(path); err != nil {
+			return auth, fmt.Errorf("invalid %s: %w", file.flag, err)
+		}
+		*file.dest = path
+	}
+	return auth, nil
+}
+
+// splitArgs splits args at the "--" separator into flags and the command to run
+func splitArgs(args []string) (flagArgs []string, cmdArgs []string, found bool) {
+	for i, arg := range args {
+		if arg == "--" {
+			return args[:i], args[i+1:], true
+		}
+	}
+	return args, nil, false
+}
+
+// parseFlags parses args into flags, reporting errors through the ui
+func parseFlags(ui cli.Ui, flags *flag.FlagSet, args []string) error {
+	flags.SetOutput(io.Discard)
+	if err := flags.Parse(args); err != nil {
+		ui.Error(fmt.Sprintf("Error: %v", err))
+		return err
+	}
+	return nil
+}
+
+// reportError prints err with a hint on what to do about it, when there is one
+func reportError(ui cli.Ui, err error) {
+	ui.Error(fmt.Sprintf("Error: %v", err))
+	if hint := runner.Hint(err); hint != "" {
+		ui.Error("Hint: " + hint)
+	}
+}
+
+// flagDefaults renders the defaults of the flags registered by setup
+func flagDefaults(setup func(*flag.FlagSet)) string {
+	flags := flag.NewFlagSet("", flag.ContinueOnError)
+	setup(flags)
+
+	var buf strings.Builder
+	flags.SetOutput(&buf)
+	flags.PrintDefaults()
+	return buf.String()
+}
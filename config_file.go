@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// fileOnlyFlags can only be given on the command line
+var fileOnlyFlags = map[string]bool{
+	"config":  true,
+	"version": true,
+}
+
+// applyConfigFile sets the flags named in the JSON object stored at path, except
+// those already given on the command line, and returns the command it sets.
+//
+// Keys are flag names. Lists are given as arrays, "env" as an object and
+// "command" as an array of the program and its arguments:
+//
+//	{"interval": "30s", "watch-exclude": ["*.log"], "env": {"PORT": "8080"}, "command": ["npm", "start"]}
+func applyConfigFile(flags *flag.FlagSet, path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var settings map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&settings); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+
+	given := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+
+	names := make([]string, 0, len(settings))
+	for name := range settings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var command []string
+	for _, name := range names {
+		value := settings[name]
+		if name == "command" {
+			if command, err = stringList(value); err != nil || len(command) == 0 {
+				return nil, fmt.Errorf("invalid config file %s: \"command\" must be a list of the program and its arguments", path)
+			}
+			continue
+		}
+		if flags.Lookup(name) == nil || fileOnlyFlags[name] {
+			return nil, fmt.Errorf("invalid config file %s: unknown setting %q", path, name)
+		}
+		if given[name] {
+			continue
+		}
+		values, err := flagValues(name, value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+		}
+		for _, v := range values {
+			if err := flags.Set(name, v); err != nil {
+				return nil, fmt.Errorf("invalid config file %s: invalid value %q for %q: %w", path, v, name, err)
+			}
+		}
+	}
+	return command, nil
+}
+
+// flagValues converts a JSON value to the values passed to flag.Set
+func flagValues(name string, value interface{}) ([]string, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{v}, nil
+	case bool, json.Number:
+		return []string{fmt.Sprint(v)}, nil
+	case []interface{}:
+		values, err := stringList(v)
+		if err != nil {
+			return nil, fmt.Errorf("%q: %w", name, err)
+		}
+		return values, nil
+	case map[string]interface{}:
+		// Objects map names to values, like "env": {"PORT": "8080"}
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		values := make([]string, 0, len(v))
+		for _, key := range keys {
+			switch item := v[key].(type) {
+			case string, bool, json.Number:
+				values = append(values, fmt.Sprintf("%s=%v", key, item))
+			default:
+				return nil, fmt.Errorf("%q: %q must be a string, number or boolean", name, key)
+			}
+		}
+		return values, nil
+	default:
+		return nil, fmt.Errorf("%q has an unsupported value", name)
+	}
+}
+
+// stringList converts a JSON array of scalars to strings
+func stringList(value interface{}) ([]string, error) {
+	items, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expected a list")
+	}
+	values := make([]string, 0, len(items))
+	for _, item := range items {
+		switch item := item.(type) {
+		case string, bool, json.Number:
+			values = append(values, fmt.Sprint(item))
+		default:
+			return nil, fmt.Errorf("list items must be strings, numbers or booleans")
+		}
+	}
+	return values, nil
+}
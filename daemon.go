@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/ship-digital/pull-watch/internal/config"
+	"github.com/ship-digital/pull-watch/internal/control"
+	"github.com/ship-digital/pull-watch/internal/git"
+	"github.com/ship-digital/pull-watch/internal/logger"
+	"github.com/ship-digital/pull-watch/internal/runner"
+)
+
+// daemonEnv marks the detached child started by "pull-watch daemon"
+const daemonEnv = "PULL_WATCH_DAEMON"
+
+// daemonStartTimeout bounds how long the parent waits for the detached daemon to listen
+const daemonStartTimeout = 10 * time.Second
+
+type DaemonCommand struct {
+	MainCommand
+
+	pidFile    string
+	socket     string
+	logFile    string
+	foreground bool
+}
+
+func (c *DaemonCommand) setupFlags(flags *flag.FlagSet) {
+	c.MainCommand.setupFlags(flags)
+	flags.StringVar(&c.pidFile, "pidfile", "", "Pidfile location (default \"<git dir>/pull-watch/daemon.pid\")")
+	flags.StringVar(&c.socket, "socket", "", "Control socket location (default \"<git dir>/pull-watch/daemon.sock\")")
+	flags.StringVar(&c.logFile, "log-file", "", "Where the detached daemon writes its own and the command's output (default \"<git dir>/pull-watch/daemon.log\")")
+	flags.BoolVar(&c.foreground, "foreground", false, "Don't detach, only write the pidfile and listen on the control socket (for process supervisors)")
+}
+
+func (c *DaemonCommand) Run(args []string) int {
+	if len(args) == 0 {
+		c.ui.Output(c.Help())
+		return 1
+	}
+
+	cfg, code := c.parseConfig(args, c.setupFlags, c.Help)
+	if cfg == nil {
+		return code
+	}
+
+	if err := c.resolvePaths(cfg); err != nil {
+		c.ui.Error(fmt.Sprintf("Error: %v", err))
+		return 1
+	}
+
+	if !c.foreground && os.Getenv(daemonEnv) == "" {
+		return c.detach(args)
+	}
+
+	logFile := ""
+	if os.Getenv(daemonEnv) != "" {
+		logFile = c.logFile
+		// The watched command shouldn't think it is the daemon
+		os.Unsetenv(daemonEnv)
+	}
+	return watchExitCode(c.ui, c.serve(cfg, logFile))
+}
+
+// resolvePaths fills in the default pidfile, socket and log locations inside the git directory
+func (c *DaemonCommand) resolvePaths(cfg *config.Config) error {
+	if c.pidFile != "" && c.socket != "" && c.logFile != "" {
+		return nil
+	}
+	gitDir, err := git.New(cfg).GetGitDir(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to locate git directory: %w", err)
+	}
+	if c.pidFile == "" {
+		c.pidFile = control.DefaultPidfilePath(gitDir)
+	}
+	if c.socket == "" {
+		c.socket = control.DefaultSocketPath(gitDir)
+	}
+	if c.logFile == "" {
+		c.logFile = control.DefaultLogPath(gitDir)
+	}
+	return nil
+}
+
+// detach starts pull-watch again in a new session with its output going to the
+// log file, and waits until it listens on the control socket
+func (c *DaemonCommand) detach(args []string) int {
+	if pid, err := control.ReadPidfile(c.pidFile); err == nil && control.ProcessAlive(pid) {
+		c.ui.Error(fmt.Sprintf("Error: pull-watch is already running with PID %d (pidfile %s)", pid, c.pidFile))
+		return 1
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		c.ui.Error(fmt.Sprintf("Error: failed to locate the pull-watch executable: %v", err))
+		return 1
+	}
+
+	if err := os.MkdirAll(filepath.Dir(c.logFile), 0o755); err != nil {
+		c.ui.Error(fmt.Sprintf("Error: failed to create log directory: %v", err))
+		return 1
+	}
+	logFile, err := os.OpenFile(c.logFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		c.ui.Error(fmt.Sprintf("Error: failed to open log file: %v", err))
+		return 1
+	}
+	defer logFile.Close()
+
+	cmd := exec.Command(exe, append([]string{"daemon"}, args...)...)
+	cmd.Env = append(os.Environ(), daemonEnv+"=1")
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	cmd.SysProcAttr = detachAttr()
+	if err := cmd.Start(); err != nil {
+		c.ui.Error(fmt.Sprintf("Error: failed to start daemon: %v", err))
+		return 1
+	}
+
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+
+	deadline := time.After(daemonStartTimeout)
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case err := <-exited:
+			c.ui.Error(fmt.Sprintf("Error: daemon exited during startup (%v), see %s", err, c.logFile))
+			return 1
+		case <-deadline:
+			c.ui.Error(fmt.Sprintf("Error: daemon with PID %d didn't open %s within %s, see %s", cmd.Process.Pid, c.socket, daemonStartTimeout, c.logFile))
+			return 1
+		case <-ticker.C:
+			if control.Alive(c.socket) {
+				c.ui.Output(fmt.Sprintf("pull-watch daemon started with PID %d, logging to %s", cmd.Process.Pid, c.logFile))
+				return 0
+			}
+		}
+	}
+}
+
+// serve runs the watcher in this process, controlled through the socket
+func (c *DaemonCommand) serve(cfg *config.Config, logFile string) error {
+	if err := control.WritePidfile(c.pidFile); err != nil {
+		return err
+	}
+	defer control.RemovePidfile(c.pidFile)
+
+	server, err := control.Listen(c.socket, logFile, cfg.Logger)
+	if err != nil {
+		return err
+	}
+	defer server.Close()
+
+	cfg.Logger.MultiColor(logger.DefaultLevel,
+		logger.InfoSegment("Daemon running with PID "),
+		logger.HighlightSegment(fmt.Sprintf("%d", os.Getpid())),
+		logger.InfoSegment(", control socket "),
+		logger.HighlightSegment(server.Path()),
+	)
+
+	return runner.Run(cfg, runner.WithControl(server.Requests()), runner.WithReload(c.reload))
+}
+
+func (c *DaemonCommand) Help() string {
+	return fmt.Sprintf(`
+Usage: pull-watch daemon [options] -- <command>
+
+ Run pull-watch in the background. It detaches from the terminal, writes a
+ pidfile and listens on a control socket used by the status, stop, restart,
+ reload and logs subcommands.
+
+Options:
+%s`, flagDefaults(c.setupFlags))
+}
+
+func (c *DaemonCommand) Synopsis() string {
+	return "Run pull-watch in the background"
+}
+
+// daemonPaths locates the socket and log file of a daemon from the flags shared by the control subcommands
+type daemonPaths struct {
+	commonFlags
+	socket string
+}
+
+func (p *daemonPaths) setupFlags(flags *flag.FlagSet) {
+	p.commonFlags.setupFlags(flags)
+	flags.StringVar(&p.socket, "socket", "", "Control socket location (default \"<git dir>/pull-watch/daemon.sock\")")
+}
+
+// resolveGitDir returns the .git directory of the repository selected by -git-dir
+func (p *daemonPaths) resolveGitDir() (string, error) {
+	logLevel := p.logLevel(logger.QuietLevel)
+	cfg := &config.Config{
+		GitDir:   p.gitDir,
+		LogLevel: logLevel,
+		Logger:   p.newLogger(logLevel),
+	}
+	gitDir, err := git.New(cfg).GetGitDir(context.Background())
+	if err != nil {
+		return "", fmt.Errorf("failed to locate git directory: %w", err)
+	}
+	return gitDir, nil
+}
+
+func (p *daemonPaths) socketPath() (string, error) {
+	if p.socket != "" {
+		return p.socket, nil
+	}
+	gitDir, err := p.resolveGitDir()
+	if err != nil {
+		return "", err
+	}
+	return control.DefaultSocketPath(gitDir), nil
+}
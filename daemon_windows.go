@@ -0,0 +1,14 @@
+//go:build windows
+
+package main
+
+import "syscall"
+
+const detachedProcess = 0x00000008
+
+// detachAttr starts the daemon without a console so it survives the terminal closing
+func detachAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{
+		CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP | detachedProcess,
+	}
+}
@@ -0,0 +1,331 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/hashicorp/cli"
+	"github.com/ship-digital/pull-watch/internal/control"
+	"github.com/ship-digital/pull-watch/internal/events"
+)
+
+// stopWaitTimeout bounds how long "pull-watch stop" waits for the daemon to exit
+const stopWaitTimeout = 30 * time.Second
+
+// ControlCommand sends a command to a running daemon over its control socket
+type ControlCommand struct {
+	ui       cli.Ui
+	command  control.Command
+	synopsis string
+	help     string
+
+	daemonPaths
+	format string
+}
+
+func (c *ControlCommand) setupFlags(flags *flag.FlagSet) {
+	c.daemonPaths.setupFlags(flags)
+	if c.command == control.CommandStatus {
+		flags.StringVar(&c.format, "format", "text", "Output format: 'text' or 'json'")
+	}
+}
+
+func (c *ControlCommand) Run(args []string) int {
+	flags := flag.NewFlagSet(string(c.command), flag.ContinueOnError)
+	c.setupFlags(flags)
+	if err := parseFlags(c.ui, flags, args); err != nil {
+		return 1
+	}
+
+	if c.command == control.CommandStatus && c.format != "text" && c.format != "json" {
+		c.ui.Error(fmt.Sprintf("Error: invalid format %q (expected \"text\" or \"json\")", c.format))
+		return 1
+	}
+
+	socket, err := c.socketPath()
+	if err != nil {
+		c.ui.Error(fmt.Sprintf("Error: %v", err))
+		return 1
+	}
+
+	resp, err := control.Send(socket, c.command)
+	if err != nil {
+		c.ui.Error(fmt.Sprintf("Error: %v", err))
+		return 1
+	}
+
+	switch c.command {
+	case control.CommandStatus:
+		return c.printStatus(resp.Status)
+	case control.CommandStop:
+		// The daemon answers before it exits, wait for it to let go of the socket
+		deadline := time.Now().Add(stopWaitTimeout)
+		for control.Alive(socket) {
+			if time.Now().After(deadline) {
+				c.ui.Error(fmt.Sprintf("Error: daemon still running after %s", stopWaitTimeout))
+				return 1
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+		c.ui.Output("pull-watch daemon stopped")
+	case control.CommandRestart:
+		c.ui.Output("Command restarted")
+	case control.CommandReload:
+		c.ui.Output("Configuration reloaded")
+	}
+	return 0
+}
+
+func (c *ControlCommand) printStatus(status *control.Status) int {
+	if status == nil {
+		c.ui.Error("Error: daemon sent no status")
+		return 1
+	}
+
+	if c.format == "json" {
+		out, err := json.Marshal(status)
+		if err != nil {
+			c.ui.Error(fmt.Sprintf("Error: %v", err))
+			return 1
+		}
+		c.ui.Output(string(out))
+		return 0
+	}
+
+	process := "not running"
+	if status.Process.Running {
+		process = fmt.Sprintf("running with PID %d for %s", status.Process.PID, time.Since(status.Process.StartedAt).Round(time.Second))
+	} else if status.Process.PID != 0 {
+		process = fmt.Sprintf("exited (last PID %d)", status.Process.PID)
+		if exit := status.Process.LastExit; exit != nil {
+			process = fmt.Sprintf("%s (last PID %d)", exit, status.Process.PID)
+		}
+	}
+	if status.Process.BackoffMS > 0 {
+		process += fmt.Sprintf(", backoff %s", time.Duration(status.Process.BackoffMS)*time.Millisecond)
+	}
+
+	lastCheck := "never"
+	if !status.LastCheck.IsZero() {
+		lastCheck = fmt.Sprintf("%s ago", time.Since(status.LastCheck).Round(time.Second))
+	}
+
+	var buf strings.Builder
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Daemon:\tPID %d, up %s\n", status.PID, time.Since(status.StartedAt).Round(time.Second))
+	fmt.Fprintf(w, "Mode:\t%s, every %s\n", status.Mode, status.PollInterval)
+	fmt.Fprintf(w, "Repository:\t%s\n", status.GitDir)
+	fmt.Fprintf(w, "Commit:\t%s\n", events.ShortCommit(status.Commit))
+	fmt.Fprintf(w, "Command:\t%s\n", strings.Join(status.Command, " "))
+	fmt.Fprintf(w, "Process:\t%s\n", process)
+	fmt.Fprintf(w, "Last check:\t%s\n", lastCheck)
+	if status.LastError != "" {
+		fmt.Fprintf(w, "Last error:\t%s\n", firstLine(status.LastError))
+	}
+	if status.LogFile != "" {
+		fmt.Fprintf(w, "Log file:\t%s\n", status.LogFile)
+	}
+	w.Flush()
+	c.ui.Output(strings.TrimSuffix(buf.String(), "\n"))
+	return 0
+}
+
+func (c *ControlCommand) Help() string {
+	return fmt.Sprintf(`
+Usage: pull-watch %s [options]
+
+ %s
+
+Options:
+%s`, c.command, c.help, flagDefaults(c.setupFlags))
+}
+
+func (c *ControlCommand) Synopsis() string {
+	return c.synopsis
+}
+
+// LogsCommand shows the log file of a running daemon
+type LogsCommand struct {
+	ui cli.Ui
+
+	daemonPaths
+	file   string
+	lines  int
+	follow bool
+}
+
+func (c *LogsCommand) setupFlags(flags *flag.FlagSet) {
+	c.daemonPaths.setupFlags(flags)
+	flags.StringVar(&c.file, "file", "", "Log file to show (default: asked from the daemon, or \"<git dir>/pull-watch/daemon.log\")")
+	flags.IntVar(&c.lines, "n", 50, "Number of lines to show (0 shows the whole file)")
+	flags.BoolVar(&c.follow, "f", false, "Keep showing new lines as they are written")
+}
+
+func (c *LogsCommand) Run(args []string) int {
+	flags := flag.NewFlagSet("logs", flag.ContinueOnError)
+	c.setupFlags(flags)
+	if err := parseFlags(c.ui, flags, args); err != nil {
+		return 1
+	}
+
+	path, err := c.logPath()
+	if err != nil {
+		c.ui.Error(fmt.Sprintf("Error: %v", err))
+		return 1
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		c.ui.Error(fmt.Sprintf("Error: %v", err))
+		return 1
+	}
+	defer f.Close()
+
+	tail, offset, err := tailLines(f, c.lines)
+	if err != nil {
+		c.ui.Error(fmt.Sprintf("Error: %v", err))
+		return 1
+	}
+	os.Stdout.Write(tail)
+
+	if !c.follow {
+		return 0
+	}
+	if err := follow(path, f, offset, os.Stdout); err != nil {
+		c.ui.Error(fmt.Sprintf("Error: %v", err))
+		return 1
+	}
+	return 0
+}
+
+// logPath asks the daemon where it logs, falling back to the default location
+func (c *LogsCommand) logPath() (string, error) {
+	if c.file != "" {
+		return c.file, nil
+	}
+	socket, err := c.socketPath()
+	if err != nil {
+		return "", err
+	}
+	if resp, err := control.Send(socket, control.CommandStatus); err == nil && resp.Status != nil && resp.Status.LogFile != "" {
+		return resp.Status.LogFile, nil
+	}
+	gitDir, err := c.resolveGitDir()
+	if err != nil {
+		return "", err
+	}
+	return control.DefaultLogPath(gitDir), nil
+}
+
+func (c *LogsCommand) Help() string {
+	return fmt.Sprintf(`
+Usage: pull-watch logs [options]
+
+ Show the output of a pull-watch daemon and the command it runs.
+
+Options:
+%s`, flagDefaults(c.setupFlags))
+}
+
+func (c *LogsCommand) Synopsis() string {
+	return "Show the output of the background daemon"
+}
+
+// tailLines returns the last n lines of f (all of it when n is 0) and the offset of its end
+func tailLines(f *os.File, n int) ([]byte, int64, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, 0, err
+	}
+	size := info.Size()
+
+	// Read backwards in chunks until enough newlines were seen
+	const chunk = 64 * 1024
+	var buf []byte
+	start := size
+	for start > 0 && (n == 0 || bytes.Count(buf, []byte("\n")) <= n) {
+		read := int64(chunk)
+		if start < read {
+			read = start
+		}
+		start -= read
+		part := make([]byte, read)
+		if _, err := f.ReadAt(part, start); err != nil && err != io.EOF {
+			return nil, 0, err
+		}
+		buf = append(part, buf...)
+	}
+
+	if n > 0 {
+		trimmed := bytes.TrimSuffix(buf, []byte("\n"))
+		for i := len(trimmed) - 1; i >= 0; i-- {
+			if trimmed[i] == '\n' {
+				n--
+				if n == 0 {
+					buf = buf[i+1:]
+					break
+				}
+			}
+		}
+	}
+	return buf, size, nil
+}
+
+// follow copies what is appended to the file at path from offset on until interrupted,
+// starting over when the file is truncated or replaced by a rotation
+func follow(path string, f *os.File, offset int64, w io.Writer) error {
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigChan)
+
+	ticker := time.NewTicker(250 * time.Millisecond)
+	defer ticker.Stop()
+	// f is replaced on rotation, close whichever is current
+	defer func() { f.Close() }()
+
+	for {
+		select {
+		case <-sigChan:
+			return nil
+		case <-ticker.C:
+		}
+
+		if info, err := os.Stat(path); err == nil {
+			current, err := f.Stat()
+			if err != nil {
+				return err
+			}
+			if !os.SameFile(info, current) || info.Size() < offset {
+				// Show what was left in the old file, then switch over
+				if _, err := f.Seek(offset, io.SeekStart); err == nil {
+					_, _ = io.Copy(w, f)
+				}
+				replaced, err := os.Open(path)
+				if err != nil {
+					continue
+				}
+				f.Close()
+				f, offset = replaced, 0
+			}
+		}
+
+		if _, err := f.Seek(offset, io.SeekStart); err != nil {
+			return err
+		}
+		n, err := io.Copy(w, bufio.NewReader(f))
+		offset += n
+		if err != nil {
+			return err
+		}
+	}
+}
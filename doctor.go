@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/ship-digital/pull-watch/internal/config"
+	"github.com/ship-digital/pull-watch/internal/git"
+	"github.com/ship-digital/pull-watch/internal/runner"
+)
+
+type DoctorCommand struct {
+	MainCommand
+
+	yes bool
+}
+
+func (c *DoctorCommand) setupFlags(flags *flag.FlagSet) {
+	c.MainCommand.setupFlags(flags)
+	flags.BoolVar(&c.yes, "yes", false, "Clean up lock files and interrupted git operations without asking")
+}
+
+func (c *DoctorCommand) Run(args []string) int {
+	c.commandOptional = true
+	cfg, code := c.parseConfig(args, c.setupFlags, c.Help)
+	if cfg == nil {
+		return code
+	}
+
+	results := runner.Diagnose(cfg)
+	width := 0
+	for _, d := range results {
+		width = max(width, len(d.Check))
+	}
+	failed, leftovers := false, false
+	for _, d := range results {
+		c.ui.Output(fmt.Sprintf("%-4s  %-*s  %s", d.Status, width, d.Check, d.Detail))
+		if d.Status != runner.DiagnosisPass && d.Fix != "" {
+			c.ui.Output(fmt.Sprintf("%s  fix: %s", strings.Repeat(" ", 4+2+width), d.Fix))
+		}
+		failed = failed || d.Status == runner.DiagnosisFail
+		leftovers = leftovers || (d.Check == "leftovers" && d.Status == runner.DiagnosisWarn)
+	}
+
+	if leftovers && !c.repair(cfg) {
+		return 1
+	}
+	if failed {
+		return 1
+	}
+	return 0
+}
+
+// repair cleans up what an interrupted git left behind, once the user agrees.
+// It reports false when the clean up failed.
+func (c *DoctorCommand) repair(cfg *config.Config) bool {
+	if !c.yes {
+		answer, err := c.ui.Ask("\nRemove the lock files and abort the interrupted operations? [y/N]")
+		if err != nil || !strings.HasPrefix(strings.ToLower(strings.TrimSpace(answer)), "y") {
+			c.ui.Output("Nothing changed")
+			return true
+		}
+	}
+
+	ctx := context.Background()
+	repo := git.New(cfg)
+	leftovers, err := repo.FindLeftovers(ctx)
+	if err != nil {
+		reportError(c.ui, err)
+		return false
+	}
+	// Asking replaces the age check, a running git still keeps its files
+	repaired, err := repo.RepairLeftovers(ctx, leftovers, 0)
+	for _, l := range repaired {
+		c.ui.Output("Repaired " + l.String())
+	}
+	if err != nil {
+		reportError(c.ui, err)
+		return false
+	}
+	return true
+}
+
+func (c *DoctorCommand) Help() string {
+	return fmt.Sprintf(`
+Usage: pull-watch doctor [options] [-- <command>]
+
+ Check everything pull-watch needs before it can watch: git, the repository,
+ the branch and its upstream, the remote and its credentials, the working
+ tree, the command and the signals used to stop it. Takes the same options
+ as pull-watch itself, and prints a fix for every check that didn't pass.
+
+ Lock files and merges, rebases, cherry-picks or reverts left unfinished by
+ an interrupted git are offered for clean up. The watcher does the same
+ before every pull for leftovers older than -stale-lock-age. Nothing is
+ touched while a git process is still running in the repository.
+
+ Exits with 0 when pull-watch can watch, 1 when a check failed or the clean
+ up did.
+
+Options:
+%s`, flagDefaults(c.setupFlags))
+}
+
+func (c *DoctorCommand) Synopsis() string {
+	return "Check the setup and repair what an interrupted git left behind"
+}
@@ -4,7 +4,9 @@ go 1.21
 
 require (
 	github.com/fatih/color v1.18.0
+	github.com/fsnotify/fsnotify v1.8.0
 	github.com/hashicorp/cli v1.1.6
+	golang.org/x/sys v0.28.0
 )
 
 require (
@@ -26,5 +28,4 @@ require (
 	github.com/shopspring/decimal v1.4.0 // indirect
 	github.com/spf13/cast v1.7.0 // indirect
 	golang.org/x/crypto v0.30.0 // indirect
-	golang.org/x/sys v0.28.0 // indirect
 )
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/hashicorp/cli"
+	"github.com/ship-digital/pull-watch/internal/config"
+	"github.com/ship-digital/pull-watch/internal/events"
+	"github.com/ship-digital/pull-watch/internal/git"
+	"github.com/ship-digital/pull-watch/internal/history"
+	"github.com/ship-digital/pull-watch/internal/logger"
+)
+
+type HistoryCommand struct {
+	ui cli.Ui
+
+	commonFlags
+	file   string
+	since  string
+	until  string
+	types  listFlag
+	format string
+	limit  int
+}
+
+func (c *HistoryCommand) setupFlags(flags *flag.FlagSet) {
+	c.commonFlags.setupFlags(flags)
+	flags.StringVar(&c.file, "file", "", "History file location (default \"<git dir>/pull-watch/history.jsonl\")")
+	flags.StringVar(&c.since, "since", "", "Only show events after this `time` (a duration like 12h, or 2006-01-02 [15:04[:05]] local time, or RFC 3339)")
+	flags.StringVar(&c.until, "until", "", "Only show events before this `time` (same formats as -since)")
+	flags.Var(&c.types, "type", fmt.Sprintf("Only show these event `types` (comma separated or repeated: %s)", joinTypes(events.Types)))
+	flags.StringVar(&c.format, "format", "text", "Output format: 'text' or 'json' (one event per line)")
+	flags.IntVar(&c.limit, "limit", 0, "Only show the most recent N events (0 shows all)")
+}
+
+func (c *HistoryCommand) Run(args []string) int {
+	flags := flag.NewFlagSet("history", flag.ContinueOnError)
+	c.setupFlags(flags)
+	if err := parseFlags(c.ui, flags, args); err != nil {
+		return 1
+	}
+
+	if c.format != "text" && c.format != "json" {
+		c.ui.Error(fmt.Sprintf("Error: invalid format %q (expected \"text\" or \"json\")", c.format))
+		return 1
+	}
+
+	now := time.Now()
+	var filter history.Filter
+	var err error
+	if filter.Since, err = parseTime(c.since, now); err != nil {
+		c.ui.Error(fmt.Sprintf("Error: invalid -since: %v", err))
+		return 1
+	}
+	if filter.Until, err = parseTime(c.until, now); err != nil {
+		c.ui.Error(fmt.Sprintf("Error: invalid -until: %v", err))
+		return 1
+	}
+	for _, t := range c.types {
+		if !isEventType(events.Type(t)) {
+			c.ui.Error(fmt.Sprintf("Error: unknown event type %q (expected one of %s)", t, joinTypes(events.Types)))
+			return 1
+		}
+		filter.Types = append(filter.Types, events.Type(t))
+	}
+
+	path := c.file
+	if path == "" {
+		logLevel := c.logLevel(logger.DefaultLevel)
+		cfg := &config.Config{
+			GitDir:   c.gitDir,
+			LogLevel: logLevel,
+			Logger:   c.newLogger(logLevel),
+		}
+		gitDir, err := git.New(cfg).GetGitDir(context.Background())
+		if err != nil {
+			c.ui.Error(fmt.Sprintf("Error: failed to locate git directory: %v", err))
+			return 1
+		}
+		path = history.DefaultPath(gitDir)
+	}
+
+	entries, err := history.Read(path, filter)
+	if err != nil {
+		c.ui.Error(fmt.Sprintf("Error: %v", err))
+		return 1
+	}
+	if c.limit > 0 && len(entries) > c.limit {
+		entries = entries[len(entries)-c.limit:]
+	}
+
+	if c.format == "json" {
+		for _, e := range entries {
+			out, err := json.Marshal(e)
+			if err != nil {
+				c.ui.Error(fmt.Sprintf("Error: %v", err))
+				return 1
+			}
+			c.ui.Output(string(out))
+		}
+		return 0
+	}
+
+	if len(entries) == 0 {
+		c.ui.Output("No matching events recorded in " + path)
+		return 0
+	}
+
+	var buf strings.Builder
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tTYPE\tCOMMIT\tSUBJECT\tDURATION\tOUTCOME")
+	for _, e := range entries {
+		duration := ""
+		if e.DurationMS > 0 {
+			duration = e.Duration().String()
+		}
+		outcome := e.Outcome
+		if e.Exit != nil {
+			outcome = e.Exit.String()
+		}
+		if e.Error != "" {
+			outcome = fmt.Sprintf("%s: %s", outcome, firstLine(e.Error))
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			e.Time.Local().Format("2006-01-02 15:04:05"),
+			e.Type,
+			events.ShortCommit(e.Commit),
+			e.Subject,
+			duration,
+			outcome,
+		)
+	}
+	w.Flush()
+	c.ui.Output(strings.TrimSuffix(buf.String(), "\n"))
+	return 0
+}
+
+func (c *HistoryCommand) Help() string {
+	return fmt.Sprintf(`
+Usage: pull-watch history [options]
+
+ Show the changes, pulls, restarts, crashes and errors recorded by pull-watch.
+
+Options:
+%s`, flagDefaults(c.setupFlags))
+}
+
+func (c *HistoryCommand) Synopsis() string {
+	return "Show the recorded deployment history"
+}
+
+// parseTime parses a relative duration (meaning that long before now) or an absolute time
+func parseTime(value string, now time.Time) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(-d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("can't parse %q as a duration or time", value)
+}
+
+func isEventType(t events.Type) bool {
+	for _, known := range events.Types {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
+
+func joinTypes(types []events.Type) string {
+	names := make([]string, len(types))
+	for i, t := range types {
+		names[i] = string(t)
+	}
+	return strings.Join(names, ", ")
+}
+
+func firstLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/ship-digital/pull-watch/internal/runner"
+	"github.com/ship-digital/pull-watch/internal/service"
+)
+
+type InstallServiceCommand struct {
+	MainCommand
+
+	kind        string
+	name        string
+	description string
+	user        string
+	restart     string
+	watchdog    time.Duration
+	outputPath  string
+	write       bool
+}
+
+// serviceFlags are the install-service flags that aren't passed on to the service
+var serviceFlags = map[string]bool{
+	"type":        true,
+	"name":        true,
+	"description": true,
+	"user":        true,
+	"restart":     true,
+	"watchdog":    true,
+	"output":      true,
+	"write":       true,
+}
+
+func (c *InstallServiceCommand) setupFlags(flags *flag.FlagSet) {
+	c.MainCommand.setupFlags(flags)
+
+	defaultKind := service.Systemd
+	if runtime.GOOS == "darwin" {
+		defaultKind = service.Launchd
+	}
+	flags.StringVar(&c.kind, "type", string(defaultKind), "Service manager: 'systemd', 'launchd', 'supervisord' or 'openrc'")
+	flags.StringVar(&c.name, "name", "", "Service name (default \"pull-watch-<repository directory>\")")
+	flags.StringVar(&c.description, "description", "", "Service description (default \"pull-watch for <repository directory>\")")
+	flags.StringVar(&c.user, "user", "", "User the service runs as (default: the current user)")
+	flags.StringVar(&c.restart, "restart", service.RestartAlways, "When the service manager restarts pull-watch: 'always', 'on-failure' or 'no'")
+	flags.DurationVar(&c.watchdog, "watchdog", time.Minute, "How long systemd waits for a sign of life from pull-watch before restarting it (0 disables)")
+	flags.StringVar(&c.outputPath, "output", "", "Write the service definition to this `file` instead of printing it")
+	flags.BoolVar(&c.write, "write", false, "Install the service definition where the service manager looks for it (usually needs root)")
+}
+
+func (c *InstallServiceCommand) Run(args []string) int {
+	if len(args) == 0 {
+		c.ui.Output(c.Help())
+		return 1
+	}
+
+	cfg, code := c.parseConfig(args, c.setupFlags, c.Help)
+	if cfg == nil {
+		return code
+	}
+
+	kind := service.Kind(c.kind)
+	if c.write && c.outputPath != "" {
+		c.ui.Error("Error: -write and -output can't be used together")
+		return 1
+	}
+
+	exe, err := os.Executable()
+	if err == nil {
+		exe, err = filepath.EvalSymlinks(exe)
+	}
+	if err != nil {
+		c.ui.Error(fmt.Sprintf("Error: failed to locate the pull-watch executable: %v", err))
+		return 1
+	}
+	workDir, err := os.Getwd()
+	if err != nil {
+		c.ui.Error(fmt.Sprintf("Error: failed to get the working directory: %v", err))
+		return 1
+	}
+	repoDir, err := filepath.Abs(cfg.GitDir)
+	if err != nil {
+		c.ui.Error(fmt.Sprintf("Error: %v", err))
+		return 1
+	}
+
+	flagArgs, _, _ := splitArgs(args)
+	spec := service.Spec{
+		Name:        c.name,
+		Description: c.description,
+		Args:        append(append(append([]string{exe}, serviceArgs(flagArgs)...), "--"), cfg.Command...),
+		WorkingDir:  workDir,
+		User:        c.user,
+		Restart:     c.restart,
+		// Service managers start with a minimal PATH, keep the one the command was found with
+		Env:      []string{"PATH=" + os.Getenv("PATH")},
+		Watchdog: c.watchdog,
+	}
+	if spec.Name == "" {
+		spec.Name = "pull-watch-" + filepath.Base(repoDir)
+	}
+	if spec.Description == "" {
+		spec.Description = "pull-watch for " + repoDir
+	}
+	if spec.User == "" {
+		if u, err := user.Current(); err == nil {
+			spec.User = u.Username
+		}
+	}
+	spec.StopTimeout = runner.StopDuration(runner.StopSequence(cfg))
+	spec.Delegate = cfg.Limits.NeedsCgroup()
+
+	definition, err := service.Render(kind, spec)
+	if err != nil {
+		c.ui.Error(fmt.Sprintf("Error: %v", err))
+		return 1
+	}
+
+	if !cfg.RunOnStart {
+		c.ui.Warn("Note: without -run-on-start the command only starts once a new commit arrives")
+	}
+
+	path := c.outputPath
+	if c.write {
+		path = service.DefaultPath(kind, spec.Name)
+	}
+	if path == "" {
+		c.ui.Output(strings.TrimSuffix(definition, "\n"))
+		return 0
+	}
+
+	mode := os.FileMode(0o644)
+	if kind == service.OpenRC {
+		mode = 0o755
+	}
+	if err := os.WriteFile(path, []byte(definition), mode); err != nil {
+		c.ui.Error(fmt.Sprintf("Error: %v", err))
+		return 1
+	}
+	c.ui.Output(fmt.Sprintf("Wrote %s service definition to %s", kind, path))
+	if c.write {
+		c.ui.Output("Enable it with:")
+		for _, step := range service.NextSteps(kind, spec.Name, path) {
+			c.ui.Output("  " + step)
+		}
+	}
+	return 0
+}
+
+// serviceArgs returns the pull-watch flags set in args, without the install-service ones
+func serviceArgs(args []string) []string {
+	// Parse into a fresh command, list flags append to what they already hold
+	var parsed InstallServiceCommand
+	flags := flag.NewFlagSet("install-service", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	parsed.setupFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return nil
+	}
+
+	return flagArgs(flags, func(name string) bool {
+		return !serviceFlags[name]
+	})
+}
+
+func (c *InstallServiceCommand) Help() string {
+	return fmt.Sprintf(`
+Usage: pull-watch install-service [options] -- <command>
+
+ Generate a systemd unit, launchd property list, supervisord program or OpenRC
+ script that runs pull-watch with the given options and command from the
+ current directory. The systemd unit uses Type=notify: pull-watch reports when
+ it's ready, what it's running and that it's alive.
+
+Options:
+%s`, flagDefaults(c.setupFlags))
+}
+
+func (c *InstallServiceCommand) Synopsis() string {
+	return "Generate a service definition for systemd, launchd, supervisord or OpenRC"
+}
@@ -3,18 +3,163 @@ package config
 import (
 	"time"
 
+	"github.com/ship-digital/pull-watch/internal/events"
+	"github.com/ship-digital/pull-watch/internal/limits"
 	"github.com/ship-digital/pull-watch/internal/logger"
+	"github.com/ship-digital/pull-watch/internal/output"
 )
 
+// Submodule update modes
+const (
+	// SubmodulesOff leaves submodules alone, like git pull does
+	SubmodulesOff = "off"
+	// SubmodulesUpdate checks out the commits the superproject pins after every pull
+	SubmodulesUpdate = "update"
+	// SubmodulesRecursive does the same for the submodules of submodules
+	SubmodulesRecursive = "recursive"
+)
+
+// Remote commit detection strategies
+const (
+	// DetectLsRemote asks the remote for the upstream commit, fetching only when it's missing locally
+	DetectLsRemote = "ls-remote"
+	// DetectFetch fetches the upstream and reads the commit from the remote-tracking branch
+	DetectFetch = "fetch"
+)
+
+// Pull strategies, applied whatever the git config says
+const (
+	// PullFFOnly only fast-forwards, a local branch that has diverged is left alone
+	PullFFOnly = "ff-only"
+	// PullRebase rebases the local commits on top of the remote ones
+	PullRebase = "rebase"
+	// PullMerge merges the remote commits, fast-forwarding when it can
+	PullMerge = "merge"
+	// PullReset resets the local branch, and the working tree, to the remote one
+	PullReset = "reset"
+)
+
+// Deploy modes
+const (
+	// DeployInPlace pulls into the working tree the command runs in
+	DeployInPlace = "in-place"
+	// DeployWorktree checks every new commit out in a worktree of its own
+	DeployWorktree = "worktree"
+)
+
+// Change detection modes
+const (
+	// ModeRemote polls the upstream branch and pulls new commits
+	ModeRemote = "remote"
+	// ModeLocal watches the local HEAD and never contacts the remote
+	ModeLocal = "local"
+)
+
+// StopStep is a step of stopping the command: send Signal, then give the command
+// up to Timeout to exit before moving on to the next step
+type StopStep struct {
+	Signal  string
+	Timeout time.Duration
+}
+
+// GitAuth are the credentials git uses to reach the remote. The token itself is
+// read from TokenEnv or TokenFile for every git command and never stored here.
+type GitAuth struct {
+	SSHKey     string
+	KnownHosts string
+	TokenEnv   string
+	TokenFile  string
+	TokenUser  string
+}
+
+// GitTimeouts limit how long the git operations that talk to the remote may take,
+// zero means the default
+type GitTimeouts struct {
+	LsRemote time.Duration
+	Fetch    time.Duration
+	Pull     time.Duration
+	LFS      time.Duration
+}
+
+// Submodules is how submodules follow the superproject. With Remote, submodules
+// tracking a branch in .gitmodules are also moved to its remote head when it changes.
+type Submodules struct {
+	Mode   string
+	Remote bool
+}
+
+// Detection is how new remote commits are found. With DetectFetch fetches are
+// Depth commits deep and use the partial clone Filter, when they are set.
+type Detection struct {
+	Strategy string
+	Depth    int
+	Filter   string
+}
+
+// Deploy is how pulled commits go live. With DeployWorktree each one is checked
+// out in a worktree under Dir, built with Build and made live by pointing the
+// Dir/current symlink at it. The Keep most recent worktrees are kept for rollbacks.
+type Deploy struct {
+	Mode         string
+	Dir          string
+	Build        string
+	BuildTimeout time.Duration
+	Keep         int
+}
+
 type Config struct {
-	PollInterval  time.Duration
-	Command       []string
-	GitDir        string
-	LogLevel      logger.LogLevel
-	GracefulStop  bool
-	StopTimeout   time.Duration
-	Logger        *logger.Logger
-	RunOnStart    bool
-	ShowTimestamp bool
-	NoRestart     bool
+	PollInterval   time.Duration
+	Command        []string
+	Env            []string
+	GitDir         string
+	LogLevel       logger.LogLevel
+	GracefulStop   bool
+	StopTimeout    time.Duration
+	StopSignal     string
+	StopSequence   []StopStep
+	SignalLeader   bool
+	ReloadSignal   string
+	ReloadCheck    string
+	ReloadTimeout  time.Duration
+	Limits         limits.Limits
+	ExitWithChild  bool
+	Auth           GitAuth
+	GitTimeouts    GitTimeouts
+	GitRetries     int
+	RetryBackoff   time.Duration
+	MaxInterval    time.Duration
+	StaleLockAge   time.Duration
+	PullStrategy   string
+	Submodules     Submodules
+	LFS            bool
+	Sparse         []string
+	Detection      Detection
+	Deploy         Deploy
+	Logger         *logger.Logger
+	RunOnStart     bool
+	ShowTimestamp  bool
+	NoRestart      bool
+	Mode           string
+	WatchTree      bool
+	WatchInclude   []string
+	WatchExclude   []string
+	WatchDebounce  time.Duration
+	CheckOnly      bool
+	DryRun         bool
+	Events         events.Sink
+	History        bool
+	HistoryFile    string
+	HistoryMaxMB   int
+	HistoryKeep    int
+	NotifyURLs     []string
+	NotifyEvents   []string
+	NotifyTemplate string
+	StdoutFile     string
+	StderrFile     string
+	LogMaxMB       int
+	LogMaxAge      time.Duration
+	LogKeep        int
+	LogCompress    bool
+	OutputFormat   output.Format
+	ConfigFile     string
 }
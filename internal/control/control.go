@@ -0,0 +1,106 @@
+package control
+
+import (
+	"path/filepath"
+	"time"
+
+	"github.com/ship-digital/pull-watch/internal/events"
+)
+
+// Command is an action requested over the control socket
+type Command string
+
+const (
+	// CommandStatus reports what the runner is doing
+	CommandStatus Command = "status"
+	// CommandStop stops the command and pull-watch
+	CommandStop Command = "stop"
+	// CommandRestart restarts the command
+	CommandRestart Command = "restart"
+	// CommandReload checks for changes right away
+	CommandReload Command = "reload"
+)
+
+// Commands lists the supported commands
+var Commands = []Command{CommandStatus, CommandStop, CommandRestart, CommandReload}
+
+// DefaultSocketPath returns the control socket location for the repository whose .git directory is gitDir
+func DefaultSocketPath(gitDir string) string {
+	return filepath.Join(gitDir, "pull-watch", "daemon.sock")
+}
+
+// DefaultPidfilePath returns the pidfile location for the repository whose .git directory is gitDir
+func DefaultPidfilePath(gitDir string) string {
+	return filepath.Join(gitDir, "pull-watch", "daemon.pid")
+}
+
+// DefaultLogPath returns the daemon log location for the repository whose .git directory is gitDir
+func DefaultLogPath(gitDir string) string {
+	return filepath.Join(gitDir, "pull-watch", "daemon.log")
+}
+
+// Status is the state of a running pull-watch
+type Status struct {
+	PID          int           `json:"pid"`
+	StartedAt    time.Time     `json:"started_at"`
+	Mode         string        `json:"mode"`
+	GitDir       string        `json:"git_dir"`
+	Command      []string      `json:"command"`
+	PollInterval string        `json:"poll_interval"`
+	Commit       string        `json:"commit"`
+	LastCheck    time.Time     `json:"last_check"`
+	LastError    string        `json:"last_error,omitempty"`
+	LogFile      string        `json:"log_file,omitempty"`
+	Process      ProcessStatus `json:"process"`
+}
+
+// ProcessStatus is the state of the watched command
+type ProcessStatus struct {
+	Running   bool      `json:"running"`
+	PID       int       `json:"pid,omitempty"`
+	StartedAt time.Time `json:"started_at"`
+	BackoffMS int64     `json:"backoff_ms,omitempty"`
+	// LastExit is how the previous process ended
+	LastExit *events.ExitStatus `json:"last_exit,omitempty"`
+}
+
+// message is a request as sent over the socket
+type message struct {
+	Command Command `json:"command"`
+}
+
+// Response is the answer to a request
+type Response struct {
+	OK     bool    `json:"ok"`
+	Error  string  `json:"error,omitempty"`
+	Status *Status `json:"status,omitempty"`
+}
+
+// Request is a command received on the socket waiting for the runner to handle it
+type Request struct {
+	Command Command
+	reply   chan Response
+}
+
+// NewRequest creates a request for cmd
+func NewRequest(cmd Command) Request {
+	return Request{Command: cmd, reply: make(chan Response, 1)}
+}
+
+// Respond sends the answer to the client, only the first response is delivered
+func (r Request) Respond(resp Response) {
+	select {
+	case r.reply <- resp:
+	default:
+	}
+}
+
+// Wait returns the response, or false if none came within timeout
+func (r Request) Wait(timeout time.Duration) (Response, bool) {
+	select {
+	case resp := <-r.reply:
+		return resp, true
+	case <-time.After(timeout):
+		return Response{}, false
+	}
+}
@@ -0,0 +1,121 @@
+package control
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ship-digital/pull-watch/internal/logger"
+)
+
+func TestServerRoundTrip(t *testing.T) {
+	// Unix socket paths are limited to about 100 bytes, t.TempDir() can be longer
+	dir, err := os.MkdirTemp("", "pw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "daemon.sock")
+
+	server, err := Listen(path, "/var/log/pw.log", logger.New())
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+
+	// Stand-in for the runner loop
+	go func() {
+		for req := range server.Requests() {
+			switch req.Command {
+			case CommandStatus:
+				req.Respond(Response{OK: true, Status: &Status{PID: 42, Commit: "abc123"}})
+			case CommandRestart:
+				req.Respond(Response{Error: "failed to restart command: boom"})
+			default:
+				req.Respond(Response{OK: true})
+			}
+		}
+	}()
+
+	resp, err := Send(path, CommandStatus)
+	if err != nil {
+		t.Fatalf("Send(status) error = %v", err)
+	}
+	if resp.Status == nil || resp.Status.PID != 42 || resp.Status.Commit != "abc123" {
+		t.Errorf("status = %+v", resp.Status)
+	}
+	if resp.Status.LogFile != "/var/log/pw.log" {
+		t.Errorf("status log file = %q, want the server's", resp.Status.LogFile)
+	}
+
+	if _, err := Send(path, CommandRestart); err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Errorf("Send(restart) error = %v, want the runner's error", err)
+	}
+
+	if _, err := Send(path, Command("explode")); err == nil {
+		t.Error("Send() with an unknown command should fail")
+	}
+
+	if _, err := Listen(path, "", logger.New()); err == nil {
+		t.Error("Listen() on a socket in use should fail")
+	}
+
+	if err := server.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if Alive(path) {
+		t.Error("socket still answering after Close()")
+	}
+
+	// A socket file left behind by a crash is replaced
+	if err := os.WriteFile(path, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	server, err = Listen(path, "", logger.New())
+	if err != nil {
+		t.Fatalf("Listen() over a stale socket error = %v", err)
+	}
+	server.Close()
+}
+
+func TestPidfile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pull-watch", "daemon.pid")
+
+	if err := WritePidfile(path); err != nil {
+		t.Fatalf("WritePidfile() error = %v", err)
+	}
+	pid, err := ReadPidfile(path)
+	if err != nil || pid != os.Getpid() {
+		t.Fatalf("ReadPidfile() = %d, %v, want %d", pid, err, os.Getpid())
+	}
+
+	// PID 1 always exists, unless we can't see it from a sandbox
+	if err := os.WriteFile(path, []byte("1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if ProcessAlive(1) {
+		if err := WritePidfile(path); err == nil {
+			t.Error("WritePidfile() should refuse a pidfile of a running process")
+		}
+	}
+	if err := RemovePidfile(path); err != nil {
+		t.Fatalf("RemovePidfile() error = %v", err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Error("RemovePidfile() removed another process' pidfile")
+	}
+
+	// A pidfile of a process that's gone is taken over
+	if err := os.WriteFile(path, []byte("999999999\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := WritePidfile(path); err != nil {
+		t.Fatalf("WritePidfile() over a stale pidfile error = %v", err)
+	}
+	if err := RemovePidfile(path); err != nil {
+		t.Fatalf("RemovePidfile() error = %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("RemovePidfile() left our own pidfile behind")
+	}
+}
@@ -0,0 +1,46 @@
+package control
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// WritePidfile records the current process in the pidfile at path.
+// It fails if the pidfile belongs to another process that is still running.
+func WritePidfile(path string) error {
+	if pid, err := ReadPidfile(path); err == nil && pid != os.Getpid() && ProcessAlive(pid) {
+		return fmt.Errorf("pull-watch is already running with PID %d (pidfile %s)", pid, path)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create pidfile directory: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(strconv.Itoa(os.Getpid())+"\n"), 0o644); err != nil {
+		return fmt.Errorf("failed to write pidfile: %w", err)
+	}
+	return nil
+}
+
+// ReadPidfile returns the PID recorded in the pidfile at path
+func ReadPidfile(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || pid <= 0 {
+		return 0, fmt.Errorf("invalid pidfile %s", path)
+	}
+	return pid, nil
+}
+
+// RemovePidfile removes the pidfile at path if it still belongs to the current process
+func RemovePidfile(path string) error {
+	if pid, err := ReadPidfile(path); err != nil || pid != os.Getpid() {
+		return nil
+	}
+	return os.Remove(path)
+}
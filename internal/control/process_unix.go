@@ -0,0 +1,15 @@
+//go:build !windows
+
+package control
+
+import (
+	"errors"
+	"syscall"
+)
+
+// ProcessAlive reports whether a process with the given PID exists
+func ProcessAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	// EPERM means it exists but belongs to someone else
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
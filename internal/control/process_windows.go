@@ -0,0 +1,16 @@
+//go:build windows
+
+package control
+
+import "os"
+
+// ProcessAlive reports whether a process with the given PID exists
+func ProcessAlive(pid int) bool {
+	// On Windows FindProcess opens a handle, which fails for processes that don't exist
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	p.Release()
+	return true
+}
@@ -0,0 +1,162 @@
+package control
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/ship-digital/pull-watch/internal/logger"
+)
+
+// requestTimeout bounds how long a client waits for the runner to handle its request
+const requestTimeout = 30 * time.Second
+
+// Server accepts commands on a Unix domain socket and hands them to the runner.
+// Each connection carries one JSON request line answered by one JSON response line.
+type Server struct {
+	path     string
+	logFile  string
+	listener net.Listener
+	requests chan Request
+	logger   *logger.Logger
+	done     chan struct{}
+	wg       sync.WaitGroup
+}
+
+// Listen creates the socket at path, replacing a stale one left by a crashed daemon.
+// logFile is reported in status responses.
+func Listen(path, logFile string, log *logger.Logger) (*Server, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create socket directory: %w", err)
+	}
+
+	if _, err := os.Stat(path); err == nil {
+		if Alive(path) {
+			return nil, fmt.Errorf("another pull-watch is already listening on %s", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("failed to remove stale socket: %w", err)
+		}
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", path, err)
+	}
+	// Anyone who can talk to the socket can stop the command
+	if err := os.Chmod(path, 0o600); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to restrict socket permissions: %w", err)
+	}
+
+	s := &Server{
+		path:     path,
+		logFile:  logFile,
+		listener: listener,
+		requests: make(chan Request),
+		logger:   log,
+		done:     make(chan struct{}),
+	}
+	s.wg.Add(1)
+	go s.accept()
+	return s, nil
+}
+
+// Path returns the socket location
+func (s *Server) Path() string {
+	return s.path
+}
+
+// Requests returns the channel on which received commands are delivered
+func (s *Server) Requests() <-chan Request {
+	return s.requests
+}
+
+// Close stops accepting connections and removes the socket
+func (s *Server) Close() error {
+	close(s.done)
+	err := s.listener.Close()
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) accept() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			s.logger.Debug("Control socket accept failed: %v", err)
+			continue
+		}
+		s.wg.Add(1)
+		go s.handle(conn)
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer s.wg.Done()
+	defer conn.Close()
+
+	_ = conn.SetDeadline(time.Now().Add(requestTimeout + 5*time.Second))
+	resp := s.serve(conn)
+	if resp.Status != nil && s.logFile != "" {
+		resp.Status.LogFile = s.logFile
+	}
+
+	data, err := json.Marshal(resp)
+	if err != nil {
+		s.logger.Debug("Failed to encode control response: %v", err)
+		return
+	}
+	if _, err := conn.Write(append(data, '\n')); err != nil {
+		s.logger.Debug("Failed to send control response: %v", err)
+	}
+}
+
+func (s *Server) serve(conn net.Conn) Response {
+	line, err := bufio.NewReader(conn).ReadBytes('\n')
+	if err != nil {
+		return Response{Error: fmt.Sprintf("failed to read request: %v", err)}
+	}
+	var msg message
+	if err := json.Unmarshal(line, &msg); err != nil {
+		return Response{Error: fmt.Sprintf("invalid request: %v", err)}
+	}
+	if !isCommand(msg.Command) {
+		return Response{Error: fmt.Sprintf("unknown command %q", msg.Command)}
+	}
+
+	s.logger.Debug("Received %s over the control socket", msg.Command)
+	req := NewRequest(msg.Command)
+	select {
+	case s.requests <- req:
+	case <-s.done:
+		return Response{Error: "shutting down"}
+	case <-time.After(requestTimeout):
+		return Response{Error: "timed out waiting for pull-watch to accept the request"}
+	}
+
+	resp, ok := req.Wait(requestTimeout)
+	if !ok {
+		return Response{Error: "timed out waiting for pull-watch to handle the request"}
+	}
+	return resp
+}
+
+func isCommand(cmd Command) bool {
+	for _, known := range Commands {
+		if cmd == known {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,49 @@
+package errz
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CommandError is a command that failed, with the exit code and what it printed on stderr
+type CommandError struct {
+	Command  string
+	Args     []string
+	ExitCode int
+	Stderr   string
+	Err      error
+	// Kind is the class of the failure, like ErrAuth or ErrNetwork, nil when unknown
+	Kind error
+}
+
+func (e *CommandError) Error() string {
+	return fmt.Sprintf("command failed: %v\nstderr: %s", e.Err, e.Stderr)
+}
+
+func (e *CommandError) Unwrap() []error {
+	if e.Kind == nil {
+		return []error{e.Err}
+	}
+	return []error{e.Err, e.Kind}
+}
+
+// CommandLine returns the command with its arguments
+func (e *CommandError) CommandLine() string {
+	return strings.TrimSpace(e.Command + " " + strings.Join(e.Args, " "))
+}
+
+// Message returns the first fatal: or error: line of stderr, git prints progress
+// before them, else its first line, or the error's when the command printed nothing
+func (e *CommandError) Message() string {
+	msg := strings.TrimSpace(e.Stderr)
+	if msg == "" {
+		msg = fmt.Sprint(e.Err)
+	}
+	lines := strings.Split(msg, "\n")
+	for _, line := range lines {
+		if strings.HasPrefix(line, "fatal:") || strings.HasPrefix(line, "error:") {
+			return line
+		}
+	}
+	return lines[0]
+}
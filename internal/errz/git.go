@@ -1,6 +1,119 @@
 package errz
 
-import "fmt"
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
 
 // ErrNoUpstreamBranch is returned when there is no upstream branch configured
 var ErrNoUpstreamBranch = fmt.Errorf("no upstream branch configured")
+
+// Classes of git failures, a CommandError matches its class with errors.Is
+var (
+	ErrAuth          = fmt.Errorf("authentication failed")
+	ErrNetwork       = fmt.Errorf("remote unreachable")
+	ErrMissingRef    = fmt.Errorf("no such ref")
+	ErrMergeConflict = fmt.Errorf("merge conflict")
+	ErrDiverged      = fmt.Errorf("branches have diverged")
+	ErrDirtyTree     = fmt.Errorf("local changes in the way")
+	ErrLocked        = fmt.Errorf("repository locked")
+	ErrNotRepository = fmt.Errorf("not a git repository")
+	ErrIdentity      = fmt.Errorf("committer identity unknown")
+)
+
+// gitClasses are the messages git prints for each class of failure, checked in order
+var gitClasses = []struct {
+	class    error
+	patterns []string
+}{
+	{ErrLocked, []string{
+		".lock': file exists",
+		"another git process seems to be running",
+	}},
+	{ErrNoUpstreamBranch, []string{
+		"no upstream",
+		"no tracking information",
+	}},
+	{ErrNotRepository, []string{
+		"not a git repository",
+	}},
+	{ErrAuth, []string{
+		"authentication failed",
+		"permission denied (publickey",
+		"could not read username",
+		"could not read password",
+		"terminal prompts disabled",
+		"invalid username or password",
+		"host key verification failed",
+		"repository not found",
+		"returned error: 401",
+		"returned error: 403",
+	}},
+	{ErrNetwork, []string{
+		"could not resolve host",
+		"temporary failure in name resolution",
+		"name or service not known",
+		"connection reset",
+		"connection refused",
+		"connection timed out",
+		"operation timed out",
+		"failed to connect",
+		"network is unreachable",
+		"no route to host",
+		"the remote end hung up unexpectedly",
+		"early eof",
+		"rpc failed",
+		"tls connection was non-properly terminated",
+		"gnutls_handshake() failed",
+		"ssl_read",
+		"connection closed by remote host",
+		"kex_exchange_identification",
+	}},
+	{ErrDirtyTree, []string{
+		"would be overwritten by",
+		"please commit your changes or stash them",
+		"you have unstaged changes",
+		"your index contains uncommitted changes",
+	}},
+	{ErrMergeConflict, []string{
+		"automatic merge failed",
+		"conflict (",
+		"you have unmerged files",
+		"you have not concluded your merge",
+		"could not apply",
+	}},
+	{ErrIdentity, []string{
+		"please tell me who you are",
+		"identity unknown",
+	}},
+	{ErrDiverged, []string{
+		"not possible to fast-forward",
+		"divergent branches",
+	}},
+	{ErrMissingRef, []string{
+		"couldn't find remote ref",
+		"unknown revision",
+		"bad revision",
+		"not a valid object name",
+	}},
+}
+
+// serverError matches HTTP 5xx responses as git reports them
+var serverError = regexp.MustCompile(`(?i)(returned error|http|status)[: ]+5\d\d\b`)
+
+// ClassifyGit returns the class of failure git describes on stderr, nil when it isn't a known one
+func ClassifyGit(stderr string) error {
+	msg := strings.ToLower(stderr)
+	for _, c := range gitClasses {
+		for _, pattern := range c.patterns {
+			if strings.Contains(msg, pattern) {
+				return c.class
+			}
+		}
+	}
+	if serverError.MatchString(msg) {
+		return ErrNetwork
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package errz
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestClassifyGit(t *testing.T) {
+	tests := []struct {
+		stderr string
+		want   error
+	}{
+		{"fatal: Authentication failed for 'https://example.com/repo.git/'", ErrAuth},
+		{"git@example.com: Permission denied (publickey).\nfatal: Could not read from remote repository.", ErrAuth},
+		{"fatal: could not read Username for 'https://example.com': terminal prompts disabled", ErrAuth},
+		{"fatal: unable to access 'https://example.com/repo.git/': Could not resolve host: example.com", ErrNetwork},
+		{"fatal: unable to access 'https://example.com/repo.git/': The requested URL returned error: 502", ErrNetwork},
+		{"fatal: couldn't find remote ref refs/heads/gone", ErrMissingRef},
+		{"CONFLICT (content): Merge conflict in main.go\nAutomatic merge failed; fix conflicts and then commit the result.", ErrMergeConflict},
+		{"Rebasing (1/1)error: could not apply 21c0a8b... main.go\nhint: Resolve all conflicts manually", ErrMergeConflict},
+		{"fatal: Not possible to fast-forward, aborting.", ErrDiverged},
+		{"Committer identity unknown\n\n*** Please tell me who you are.", ErrIdentity},
+		{"error: Your local changes to the following files would be overwritten by merge:\n\tmain.go", ErrDirtyTree},
+		{"fatal: Unable to create '/srv/app/.git/index.lock': File exists.\n\nAnother git process seems to be running in this repository", ErrLocked},
+		{"fatal: not a git repository (or any of the parent directories): .git", ErrNotRepository},
+		{"fatal: no upstream configured for branch 'main'", ErrNoUpstreamBranch},
+		{"fatal: something new and strange", nil},
+	}
+
+	for _, tt := range tests {
+		if got := ClassifyGit(tt.stderr); got != tt.want {
+			t.Errorf("ClassifyGit(%q) = %v, want %v", tt.stderr, got, tt.want)
+		}
+	}
+}
+
+func TestCommandError(t *testing.T) {
+	err := error(&CommandError{Command: "git", Args: []string{"pull"}, ExitCode: 1, Stderr: "fatal: Not possible to fast-forward, aborting.\n", Err: errors.New("exit status 1"), Kind: ErrDiverged})
+	if !errors.Is(err, ErrDiverged) || errors.Is(err, ErrAuth) {
+		t.Errorf("errors.Is() doesn't follow the class of %v", err)
+	}
+	var cmdErr *CommandError
+	if !errors.As(err, &cmdErr) || cmdErr.Message() != "fatal: Not possible to fast-forward, aborting." {
+		t.Errorf("Message() = %q", cmdErr.Message())
+	}
+
+	progress := &CommandError{Stderr: "From /srv/lib\n   11af03e..654cd66  main -> origin/main\nfatal: git upload-pack: not our ref 0dd8332\n", Err: errors.New("exit status 128")}
+	if got := progress.Message(); got != "fatal: git upload-pack: not our ref 0dd8332" {
+		t.Errorf("Message() = %q, want the fatal line", got)
+	}
+}
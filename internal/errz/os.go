@@ -2,4 +2,5 @@ package errz
 
 import "fmt"
 
-var ErrInterrupt = fmt.Errorf("signal: interrupt")
+// ErrInterrupt marks a command stopped by an interrupt or by pull-watch shutting down
+var ErrInterrupt = fmt.Errorf("interrupted")
@@ -0,0 +1,131 @@
+package events
+
+import (
+	"context"
+	"time"
+
+	"github.com/ship-digital/pull-watch/internal/logger"
+)
+
+// Type identifies what happened
+type Type string
+
+const (
+	// Change is recorded when the remote or local HEAD moved
+	Change Type = "change"
+	// Pull is recorded after every pull attempt
+	Pull Type = "pull"
+	// Start is recorded when the command is started on startup
+	Start Type = "start"
+	// Restart is recorded when the command is restarted after a change
+	Restart Type = "restart"
+	// Reload is recorded when the command is signalled to reload itself after a change
+	Reload Type = "reload"
+	// Crash is recorded when the command fails or is killed without being asked to
+	Crash Type = "crash"
+	// Exit is recorded when the command exits with code 0 without being asked to
+	Exit Type = "exit"
+	// Rollback is recorded when the working tree is moved back to a previous commit
+	Rollback Type = "rollback"
+	// Error is recorded when an update check fails
+	Error Type = "error"
+)
+
+// Types lists every event type
+var Types = []Type{Change, Pull, Start, Restart, Reload, Crash, Exit, Rollback, Error}
+
+// Outcomes of an event
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+)
+
+// Event is a single entry in the deployment history
+type Event struct {
+	Time       time.Time `json:"time"`
+	Type       Type      `json:"type"`
+	Commit     string    `json:"commit,omitempty"`
+	Subject    string    `json:"subject,omitempty"`
+	PID        int       `json:"pid,omitempty"`
+	DurationMS int64     `json:"duration_ms,omitempty"`
+	Outcome    string    `json:"outcome,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	// Exit is how the command ended, for crash events
+	Exit *ExitStatus `json:"exit,omitempty"`
+}
+
+// Duration returns how long the recorded operation took
+func (e Event) Duration() time.Duration {
+	return time.Duration(e.DurationMS) * time.Millisecond
+}
+
+// WithDuration sets the duration of the event
+func (e Event) WithDuration(d time.Duration) Event {
+	e.DurationMS = d.Milliseconds()
+	return e
+}
+
+// WithError sets the outcome of the event from err
+func (e Event) WithError(err error) Event {
+	if err != nil {
+		e.Outcome = OutcomeFailure
+		e.Error = err.Error()
+	} else {
+		e.Outcome = OutcomeSuccess
+	}
+	return e
+}
+
+// Sink receives events
+type Sink interface {
+	Record(e Event) error
+}
+
+// Sinks fans events out to several sinks
+type Sinks []Sink
+
+// Record sends e to every sink and returns the first error
+func (s Sinks) Record(e Event) error {
+	var first error
+	for _, sink := range s {
+		if err := sink.Record(e); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// Record stamps e with the current time if needed and sends it to sink, a nil sink is a no-op
+func Record(sink Sink, e Event) error {
+	if sink == nil {
+		return nil
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	return sink.Record(e)
+}
+
+// RecordWithSubject adds the subject of the commit to e, when subject can look it up,
+// and records it to sink. Failures are only logged, history is never worth failing an update for.
+func RecordWithSubject(ctx context.Context, sink Sink, log *logger.Logger, e Event, subject func(ctx context.Context, commit string) (string, error)) {
+	if sink == nil {
+		return
+	}
+	if e.Commit != "" && e.Subject == "" {
+		if s, err := subject(ctx, e.Commit); err == nil {
+			e.Subject = s
+		}
+	}
+	if err := Record(sink, e); err != nil {
+		log.Debug("Failed to record %s event: %v", e.Type, err)
+	}
+}
+
+// ShortCommit abbreviates a commit hash for display
+func ShortCommit(commit string) string {
+	if len(commit) > 8 {
+		return commit[:8]
+	}
+	return commit
+}
@@ -0,0 +1,66 @@
+package events
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ExitStatus describes how the command ended and what it used
+type ExitStatus struct {
+	// Code is the exit code, or 128 plus the signal number like shells report it
+	Code int `json:"code"`
+	// Signal is the signal that terminated the command, if any
+	Signal     string `json:"signal,omitempty"`
+	CoreDumped bool   `json:"core_dumped,omitempty"`
+	// OOMKilled is set when the kernel killed the command for exceeding its cgroup memory limit
+	OOMKilled bool `json:"oom_killed,omitempty"`
+	// MaxRSSKB is the peak resident set size in kilobytes
+	MaxRSSKB    int64 `json:"max_rss_kb,omitempty"`
+	UserCPUMS   int64 `json:"user_cpu_ms,omitempty"`
+	SystemCPUMS int64 `json:"system_cpu_ms,omitempty"`
+}
+
+// CPUTime returns the user and system CPU time the command used
+func (s ExitStatus) CPUTime() time.Duration {
+	return time.Duration(s.UserCPUMS+s.SystemCPUMS) * time.Millisecond
+}
+
+// String describes how the command ended, like "exited with code 1" or "killed by SIGSEGV (core dumped)"
+func (s ExitStatus) String() string {
+	var desc string
+	switch {
+	case s.OOMKilled:
+		desc = "killed for exceeding its memory limit"
+	case s.Signal != "":
+		desc = "killed by " + s.Signal
+	default:
+		desc = fmt.Sprintf("exited with code %d", s.Code)
+	}
+	if s.CoreDumped {
+		desc += " (core dumped)"
+	}
+	return desc
+}
+
+// Usage summarizes the resources the command used, like "max RSS 42M, CPU 1.2s"
+func (s ExitStatus) Usage() string {
+	var parts []string
+	if s.MaxRSSKB > 0 {
+		parts = append(parts, "max RSS "+formatKB(s.MaxRSSKB))
+	}
+	if cpu := s.CPUTime(); cpu > 0 {
+		parts = append(parts, "CPU "+cpu.String())
+	}
+	return strings.Join(parts, ", ")
+}
+
+func formatKB(kb int64) string {
+	switch {
+	case kb >= 1<<20:
+		return fmt.Sprintf("%.1fG", float64(kb)/(1<<20))
+	case kb >= 1<<10:
+		return fmt.Sprintf("%dM", kb>>10)
+	}
+	return fmt.Sprintf("%dK", kb)
+}
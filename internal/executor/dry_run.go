@@ -0,0 +1,102 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/ship-digital/pull-watch/internal/config"
+	"github.com/ship-digital/pull-watch/internal/logger"
+)
+
+// readOnlyGitCommands are the git subcommands that never modify the repository.
+// fetch isn't one, it moves remote-tracking branches, FETCH_HEAD and the shallow boundary.
+var readOnlyGitCommands = map[string]bool{
+	"rev-parse":    true,
+	"ls-remote":    true,
+	"ls-files":     true,
+	"cat-file":     true,
+	"merge-base":   true,
+	"status":       true,
+	"check-ignore": true,
+	"log":          true,
+	"diff":         true,
+	"show":         true,
+	"rev-list":     true,
+	"symbolic-ref": true,
+	"for-each-ref": true,
+}
+
+// readOnlyGitQueries are the git subcommands that also write, with the options
+// that make them only read. One of them has to be given for the command to run.
+var readOnlyGitQueries = map[string][]string{
+	"config": {"--get", "--get-all", "--get-regexp", "--list", "-l"},
+	"remote": {"get-url"},
+}
+
+var _ CommandExecutor = &DryRunExecutor{}
+
+// DryRunExecutor runs read-only commands and only logs the ones that would change
+// something. Skipped commands succeed with no output.
+type DryRunExecutor struct {
+	cfg  *config.Config
+	next CommandExecutor
+}
+
+// NewDryRun wraps next so that mutating commands are logged instead of executed
+func NewDryRun(cfg *config.Config, next CommandExecutor) *DryRunExecutor {
+	return &DryRunExecutor{cfg: cfg, next: next}
+}
+
+func (e *DryRunExecutor) ExecuteCommand(ctx context.Context, name string, args ...string) (string, error) {
+	if name == "git" && readOnlyGit(args) {
+		return e.next.ExecuteCommand(ctx, name, args...)
+	}
+
+	e.cfg.Logger.MultiColor(logger.QuietLevel,
+		logger.HighlightSegment("[dry-run] "),
+		logger.InfoSegment("Skipped, would execute in "),
+		logger.HighlightSegment(e.cfg.GitDir),
+		logger.InfoSegment(": "),
+		logger.HighlightSegment(redact(fmt.Sprintf("%s %s", name, strings.Join(args, " ")), nil)),
+	)
+	return "", nil
+}
+
+func (e *DryRunExecutor) GetConfig() *config.Config {
+	return e.cfg
+}
+
+// readOnlyGit reports whether the git command in args never modifies the repository
+func readOnlyGit(args []string) bool {
+	i := gitSubcommand(args)
+	if i < 0 {
+		return false
+	}
+	if readOnlyGitCommands[args[i]] {
+		return true
+	}
+	for _, arg := range args[i+1:] {
+		if slices.Contains(readOnlyGitQueries[args[i]], arg) {
+			return true
+		}
+	}
+	return false
+}
+
+// gitSubcommand returns the index of the git subcommand in args, skipping global
+// options like -C <dir>, or -1 when there is none
+func gitSubcommand(args []string) int {
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; {
+		case arg == "-C" || arg == "-c" || arg == "--git-dir" || arg == "--work-tree":
+			i++ // Skip the option's value
+		case strings.HasPrefix(arg, "-"):
+			continue
+		default:
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,67 @@
+package executor
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/ship-digital/pull-watch/internal/config"
+	"github.com/ship-digital/pull-watch/internal/logger"
+)
+
+// recordingExecutor records the commands it is asked to execute
+type recordingExecutor struct {
+	cfg      *config.Config
+	executed []string
+}
+
+func (r *recordingExecutor) ExecuteCommand(ctx context.Context, name string, args ...string) (string, error) {
+	r.executed = append(r.executed, name+" "+strings.Join(args, " "))
+	return "output", nil
+}
+
+func (r *recordingExecutor) GetConfig() *config.Config {
+	return r.cfg
+}
+
+func TestDryRunExecutor(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		wantExec bool
+	}{
+		{name: "rev-parse runs", args: []string{"rev-parse", "HEAD"}, wantExec: true},
+		{name: "ls-remote runs", args: []string{"ls-remote", "origin", "HEAD"}, wantExec: true},
+		{name: "fetch is skipped", args: []string{"-C", "/repo", "fetch"}, wantExec: false},
+		{name: "shallow fetch is skipped", args: []string{"-C", "/repo", "fetch", "--deepen=50"}, wantExec: false},
+		{name: "merge-base with -C runs", args: []string{"-C", "/repo", "merge-base", "--is-ancestor", "a", "b"}, wantExec: true},
+		{name: "pull is skipped", args: []string{"pull"}, wantExec: false},
+		{name: "reset is skipped", args: []string{"-C", "/repo", "reset", "--hard", "origin/main"}, wantExec: false},
+		{name: "config --get runs", args: []string{"config", "--file", ".gitmodules", "--get-regexp", "branch$"}, wantExec: true},
+		{name: "config write is skipped", args: []string{"config", "pull.rebase", "true"}, wantExec: false},
+		{name: "remote get-url runs", args: []string{"remote", "get-url", "origin"}, wantExec: true},
+		{name: "remote set-url is skipped", args: []string{"remote", "set-url", "origin", "https://example.com/repo.git"}, wantExec: false},
+		{name: "config override doesn't hide pull", args: []string{"-c", "pull.ff=only", "pull"}, wantExec: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{GitDir: "/repo", Logger: logger.New()}
+			next := &recordingExecutor{cfg: cfg}
+			e := NewDryRun(cfg, next)
+
+			out, err := e.ExecuteCommand(context.Background(), "git", tt.args...)
+			if err != nil {
+				t.Fatalf("ExecuteCommand() error = %v", err)
+			}
+
+			executed := len(next.executed) == 1
+			if executed != tt.wantExec {
+				t.Errorf("executed = %v, want %v", executed, tt.wantExec)
+			}
+			if !tt.wantExec && out != "" {
+				t.Errorf("ExecuteCommand() output = %q, want empty for skipped command", out)
+			}
+		})
+	}
+}
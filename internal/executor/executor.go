@@ -3,11 +3,15 @@ package executor
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"os"
 	"os/exec"
 	"strings"
+	"syscall"
 
 	"github.com/ship-digital/pull-watch/internal/config"
+	"github.com/ship-digital/pull-watch/internal/errz"
 	"github.com/ship-digital/pull-watch/internal/logger"
 )
 
@@ -32,9 +36,19 @@ func (e *DefaultExecutor) ExecuteCommand(ctx context.Context, name string, args
 	cmd := exec.CommandContext(ctx, name, args...)
 	cmd.Dir = e.cfg.GitDir
 
+	var secrets []string
+	if name == "git" {
+		env, gitSecrets, err := gitEnv(e.cfg.Auth)
+		if err != nil {
+			return "", err
+		}
+		cmd.Env = append(os.Environ(), env...)
+		secrets = gitSecrets
+	}
+
 	e.cfg.Logger.MultiColor(logger.VerboseLevel,
 		logger.InfoSegment("Executing command: "),
-		logger.HighlightSegment(fmt.Sprintf("%s %s", name, strings.Join(args, " "))),
+		logger.HighlightSegment(redact(fmt.Sprintf("%s %s", name, strings.Join(args, " ")), secrets)),
 	)
 
 	var stdout, stderr bytes.Buffer
@@ -42,12 +56,42 @@ func (e *DefaultExecutor) ExecuteCommand(ctx context.Context, name string, args
 	cmd.Stderr = &stderr
 
 	if err := cmd.Run(); err != nil {
-		return "", fmt.Errorf("command failed: %w\nstderr: %s", err, stderr.String())
+		return "", commandError(ctx, name, args, err, redact(stderr.String(), secrets), secrets)
 	}
 
 	return strings.TrimSpace(stdout.String()), nil
 }
 
+// commandError describes a failed command and, for git, classifies the failure
+func commandError(ctx context.Context, name string, args []string, err error, stderr string, secrets []string) *errz.CommandError {
+	cmdErr := &errz.CommandError{Command: name, ExitCode: -1, Stderr: stderr, Err: err}
+	for _, arg := range args {
+		cmdErr.Args = append(cmdErr.Args, redact(arg, secrets))
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		cmdErr.ExitCode = exitErr.ExitCode()
+	}
+
+	switch {
+	case errors.Is(ctx.Err(), context.Canceled) || interrupted(exitErr):
+		cmdErr.Kind = errz.ErrInterrupt
+	case name == "git":
+		cmdErr.Kind = errz.ClassifyGit(stderr)
+	}
+	return cmdErr
+}
+
+// interrupted reports whether the command was stopped by SIGINT, as when Ctrl+C reaches the process group
+func interrupted(exitErr *exec.ExitError) bool {
+	if exitErr == nil {
+		return false
+	}
+	status, ok := exitErr.Sys().(syscall.WaitStatus)
+	return ok && status.Signaled() && status.Signal() == syscall.SIGINT
+}
+
 func (e *DefaultExecutor) GetConfig() *config.Config {
 	return e.cfg
 }
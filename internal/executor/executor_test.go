@@ -0,0 +1,45 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/ship-digital/pull-watch/internal/config"
+	"github.com/ship-digital/pull-watch/internal/errz"
+	"github.com/ship-digital/pull-watch/internal/logger"
+)
+
+func TestExecuteCommand_Error(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	t.Setenv("GIT_CEILING_DIRECTORIES", filepath.Dir(dir))
+
+	e := New(&config.Config{GitDir: dir, Logger: logger.New()})
+	_, err := e.ExecuteCommand(context.Background(), "git", "rev-parse", "HEAD")
+
+	var cmdErr *errz.CommandError
+	if !errors.As(err, &cmdErr) {
+		t.Fatalf("ExecuteCommand() error = %v, want a CommandError", err)
+	}
+	if cmdErr.CommandLine() != "git rev-parse HEAD" || cmdErr.ExitCode != 128 {
+		t.Errorf("CommandError = %q exit %d, want %q exit 128", cmdErr.CommandLine(), cmdErr.ExitCode, "git rev-parse HEAD")
+	}
+	if !errors.Is(err, errz.ErrNotRepository) {
+		t.Errorf("ExecuteCommand() error = %v, want ErrNotRepository", err)
+	}
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		t.Errorf("ExecuteCommand() error = %v, should still unwrap to *exec.ExitError", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := e.ExecuteCommand(ctx, "git", "status"); !errors.Is(err, errz.ErrInterrupt) {
+		t.Errorf("ExecuteCommand() with a canceled context error = %v, want ErrInterrupt", err)
+	}
+}
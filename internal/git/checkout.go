@@ -0,0 +1,166 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ship-digital/pull-watch/internal/config"
+	"github.com/ship-digital/pull-watch/internal/events"
+	"github.com/ship-digital/pull-watch/internal/logger"
+)
+
+// DefaultLFSTimeout limits fetching the LFS objects of a commit
+const DefaultLFSTimeout = 10 * time.Minute
+
+// PrepareCheckout applies the sparse checkout and checks out the LFS files of HEAD,
+// so the command starts from the same checkout it is later updated to
+func (r *GitRepository) PrepareCheckout(ctx context.Context) error {
+	if err := r.applySparse(ctx); err != nil {
+		return fmt.Errorf("failed to set up the sparse checkout: %w", err)
+	}
+	return r.checkoutLFS(ctx, "")
+}
+
+// applySparse switches the working tree to a cone mode sparse checkout of the
+// configured directories, unless it already is one
+func (r *GitRepository) applySparse(ctx context.Context) error {
+	if len(r.cfg.Sparse) == 0 {
+		return nil
+	}
+	// Fails when the working tree isn't sparse yet
+	if current, err := r.execGitCmd(ctx, "sparse-checkout", "list"); err == nil {
+		dirs := strings.Split(strings.TrimSpace(current), "\n")
+		want := slices.Clone(r.cfg.Sparse)
+		slices.Sort(dirs)
+		slices.Sort(want)
+		if slices.Equal(dirs, want) {
+			return nil
+		}
+	}
+
+	// Files come and go from the working tree, which takes a while in a monorepo
+	args := append([]string{"sparse-checkout", "set", "--cone"}, r.cfg.Sparse...)
+	if _, err := r.execWithTimeout(ctx, "sparse-checkout", timeout(r.cfg.GitTimeouts.Pull, DefaultPullTimeout), args...); err != nil {
+		return err
+	}
+	r.cfg.Logger.MultiColor(logger.DefaultLevel,
+		logger.InfoSegment("Sparse checkout set to "),
+		logger.HighlightSegment(strings.Join(r.cfg.Sparse, ", ")),
+	)
+	return nil
+}
+
+// inSparse reports whether file is checked out by the sparse checkout: in cone
+// mode that's everything under its directories, plus the files directly in
+// each of their parents, the top of the working tree included
+func (r *GitRepository) inSparse(file string) bool {
+	if len(r.cfg.Sparse) == 0 {
+		return true
+	}
+	dir := path.Dir(file)
+	for _, sparse := range r.cfg.Sparse {
+		if strings.HasPrefix(file, sparse+"/") || dir == "." || strings.HasPrefix(sparse+"/", dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// TouchesCheckout reports whether the changes between two commits touch the
+// files that are checked out, which is always the case without a sparse checkout
+func (r *GitRepository) TouchesCheckout(ctx context.Context, from, to string) (bool, error) {
+	if len(r.cfg.Sparse) == 0 {
+		return true, nil
+	}
+	output, err := r.execGitCmd(ctx, "diff", "--name-only", "--no-renames", from, to)
+	if err != nil {
+		return false, fmt.Errorf("failed to list the changed files: %w", err)
+	}
+	for _, file := range strings.Split(strings.TrimSpace(output), "\n") {
+		if file != "" && r.inSparse(file) {
+			return true, nil
+		}
+	}
+	r.cfg.Logger.MultiColor(logger.DefaultLevel,
+		logger.InfoSegment("Changes between "),
+		logger.HighlightSegment(events.ShortCommit(from)),
+		logger.InfoSegment(" and "),
+		logger.HighlightSegment(events.ShortCommit(to)),
+		logger.InfoSegment(" are all outside the sparse checkout"),
+	)
+	return false, nil
+}
+
+// checkoutLFS fetches the LFS objects of the checked out files and replaces
+// their pointers with the real content, in the worktree at dir when it's set
+func (r *GitRepository) checkoutLFS(ctx context.Context, dir string) error {
+	if !r.cfg.LFS {
+		return nil
+	}
+	include, err := r.lfsInclude(ctx, dir)
+	if err != nil || len(include) == 0 {
+		return err
+	}
+
+	start := time.Now()
+	// Local mode never contacts the remote, only the objects already fetched are checked out
+	if r.cfg.Mode != config.ModeLocal {
+		r.cfg.Logger.MultiColor(logger.DefaultLevel, logger.InfoSegment("Fetching LFS objects..."))
+		fetch := append(inDir(dir), "lfs", "fetch")
+		if len(r.cfg.Sparse) > 0 {
+			fetch = append(fetch, "--include", strings.Join(include, ","))
+		}
+		if _, err := r.execRemote(ctx, "lfs fetch", timeout(r.cfg.GitTimeouts.LFS, DefaultLFSTimeout), fetch...); err != nil {
+			return fmt.Errorf("failed to fetch LFS objects: %w", err)
+		}
+	}
+	checkout := append(append(inDir(dir), "lfs", "checkout", "--"), include...)
+	if _, err := r.execWithTimeout(ctx, "lfs checkout", timeout(r.cfg.GitTimeouts.LFS, DefaultLFSTimeout), checkout...); err != nil {
+		return fmt.Errorf("failed to check out LFS files: %w", err)
+	}
+	r.cfg.Logger.MultiColor(logger.DefaultLevel,
+		logger.InfoSegment("LFS files checked out in "),
+		logger.HighlightSegment(time.Since(start).Round(time.Millisecond).String()),
+	)
+	return nil
+}
+
+// lfsInclude returns what to fetch and check out: the sparse directories with LFS
+// files in them and the LFS files checked out outside them, or everything without
+// a sparse checkout. It's empty when there are no LFS files to check out.
+func (r *GitRepository) lfsInclude(ctx context.Context, dir string) ([]string, error) {
+	output, err := r.execGitCmd(ctx, append(inDir(dir), "lfs", "ls-files", "--name-only")...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list LFS files (is git-lfs installed?): %w", err)
+	}
+
+	var include []string
+	for _, file := range strings.Split(strings.TrimSpace(output), "\n") {
+		if file == "" || !r.inSparse(file) {
+			continue
+		}
+		if len(r.cfg.Sparse) == 0 {
+			return []string{"."}, nil
+		}
+		entry := file
+		if i := slices.IndexFunc(r.cfg.Sparse, func(dir string) bool { return strings.HasPrefix(file, dir+"/") }); i >= 0 {
+			entry = r.cfg.Sparse[i]
+		}
+		if !slices.Contains(include, entry) {
+			include = append(include, entry)
+		}
+	}
+	return include, nil
+}
+
+// inDir returns the arguments running git in dir, none for the repository itself
+func inDir(dir string) []string {
+	if dir == "" {
+		return nil
+	}
+	return []string{"-C", dir}
+}
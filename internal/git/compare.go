@@ -5,7 +5,11 @@ import (
 	"errors"
 	"fmt"
 	"os/exec"
+	"time"
 
+	"github.com/ship-digital/pull-watch/internal/config"
+	"github.com/ship-digital/pull-watch/internal/errz"
+	"github.com/ship-digital/pull-watch/internal/events"
 	"github.com/ship-digital/pull-watch/internal/logger"
 )
 
@@ -19,6 +23,22 @@ const (
 	CommitsDiverged               CommitComparisonResult = 2
 )
 
+// String returns the machine readable name of the result, from the local commit's point of view
+func (c CommitComparisonResult) String() string {
+	switch c {
+	case AIsAncestorOfB:
+		return "behind"
+	case CommitsEqual:
+		return "equal"
+	case BIsAncestorOfA:
+		return "ahead"
+	case CommitsDiverged:
+		return "diverged"
+	default:
+		return "unknown"
+	}
+}
+
 // commitExistsLocally checks if a commit exists in the local repository
 func (r *GitRepository) commitExistsLocally(ctx context.Context, commit string) bool {
 	_, err := r.executor.ExecuteCommand(ctx, "git", "cat-file", "-e", commit)
@@ -30,17 +50,23 @@ func (r *GitRepository) IsAncestor(ctx context.Context, commitA, commitB string)
 	// Check if commits exist locally
 	for _, commit := range []string{commitA, commitB} {
 		if !r.commitExistsLocally(ctx, commit) {
+			if r.cfg.DryRun {
+				return false, fmt.Errorf("commit %s isn't in the repository and a dry run doesn't fetch it: %w", commit, errz.ErrMissingRef)
+			}
 			// Commit not found locally, try fetching
 			if err := r.Fetch(ctx); err != nil {
 				return false, fmt.Errorf("failed to fetch after missing commit %s: %w", commit, err)
 			}
 			// Verify commit exists after fetch
 			if !r.commitExistsLocally(ctx, commit) {
-				return false, fmt.Errorf("commit %s not found even after fetch", commit)
+				return false, fmt.Errorf("commit %s not found even after fetch: %w", commit, errz.ErrMissingRef)
 			}
 			break // Only need to fetch once
 		}
 	}
+	if err := r.deepenUntilRelated(ctx, commitA, commitB); err != nil {
+		return false, err
+	}
 
 	_, err := r.executor.ExecuteCommand(ctx, "git", "-C", r.cfg.GitDir, "merge-base", "--is-ancestor", commitA, commitB)
 	if err != nil {
@@ -81,7 +107,8 @@ func (r *GitRepository) compareCommits(ctx context.Context, commitA, commitB str
 	return CommitsDiverged, nil
 }
 
-// HandleCommitComparison handles the commit comparison and decides whether to pull changes
+// HandleCommitComparison handles the commit comparison and decides whether to pull changes.
+// It returns AIsAncestorOfB whenever changes were pulled, whatever the pull strategy.
 func (repo *GitRepository) HandleCommitComparison(ctx context.Context, localCommit, remoteCommit string) (CommitComparisonResult, error) {
 	// Log commits if verbose
 	repo.cfg.Logger.MultiColor(logger.VerboseLevel,
@@ -102,6 +129,16 @@ func (repo *GitRepository) HandleCommitComparison(ctx context.Context, localComm
 	// Handle different comparison results
 	switch comparison {
 	case AIsAncestorOfB:
+		if repo.cfg.CheckOnly {
+			repo.cfg.Logger.MultiColor(logger.DefaultLevel,
+				logger.InfoSegment("Local commit is "),
+				logger.HighlightSegment("behind"),
+				logger.InfoSegment(" remote commit, "),
+				logger.HighlightSegment("not pulling (check only)."),
+			)
+			return AIsAncestorOfB, nil
+		}
+
 		repo.cfg.Logger.MultiColor(logger.DefaultLevel,
 			logger.InfoSegment("Local commit is "),
 			logger.HighlightSegment("behind"),
@@ -109,12 +146,24 @@ func (repo *GitRepository) HandleCommitComparison(ctx context.Context, localComm
 			logger.HighlightSegment("pulling changes..."),
 		)
 
-		if _, err := repo.Pull(ctx); err != nil {
-			return UnknownCommitComparisonResult, fmt.Errorf("failed to pull changes: %w", err)
+		if err := repo.pullChanges(ctx, localCommit, remoteCommit); err != nil {
+			return UnknownCommitComparisonResult, err
 		}
 		return AIsAncestorOfB, nil
 
 	case BIsAncestorOfA:
+		if repo.pullStrategy() == config.PullReset && !repo.cfg.CheckOnly {
+			repo.cfg.Logger.MultiColor(logger.DefaultLevel,
+				logger.InfoSegment("Local commit is "),
+				logger.HighlightSegment("ahead"),
+				logger.InfoSegment(" of remote commit, "),
+				logger.HighlightSegment("resetting to it..."),
+			)
+			if err := repo.pullChanges(ctx, localCommit, remoteCommit); err != nil {
+				return UnknownCommitComparisonResult, err
+			}
+			return AIsAncestorOfB, nil
+		}
 		repo.cfg.Logger.MultiColor(logger.VerboseLevel,
 			logger.InfoSegment("Local commit is "),
 			logger.HighlightSegment("ahead"),
@@ -124,13 +173,49 @@ func (repo *GitRepository) HandleCommitComparison(ctx context.Context, localComm
 		return BIsAncestorOfA, nil
 
 	case CommitsDiverged:
-		repo.cfg.Logger.MultiColor(logger.VerboseLevel,
+		strategy := repo.pullStrategy()
+		if repo.cfg.CheckOnly {
+			repo.cfg.Logger.MultiColor(logger.VerboseLevel,
+				logger.InfoSegment("Local commit and remote commit "),
+				logger.HighlightSegment("have diverged"),
+				logger.InfoSegment(": "),
+				logger.HighlightSegment("not pulling (check only)."),
+			)
+			return CommitsDiverged, nil
+		}
+		if strategy == config.PullFFOnly {
+			// Reported once per remote commit, it stays this way until someone steps in
+			level := logger.DefaultLevel
+			if repo.diverged == remoteCommit {
+				level = logger.VerboseLevel
+			}
+			repo.diverged = remoteCommit
+			repo.cfg.Logger.MultiColor(level,
+				logger.InfoSegment("Local commit and remote commit "),
+				logger.HighlightSegment("have diverged"),
+				logger.InfoSegment(", a fast-forward is impossible: "),
+				logger.HighlightSegment("not pulling"),
+				logger.InfoSegment(" (see "),
+				logger.HighlightSegment("-pull-strategy"),
+				logger.InfoSegment(")"),
+			)
+			return CommitsDiverged, nil
+		}
+
+		repo.cfg.Logger.MultiColor(logger.DefaultLevel,
 			logger.InfoSegment("Local commit and remote commit "),
 			logger.HighlightSegment("have diverged"),
-			logger.InfoSegment(": "),
-			logger.HighlightSegment("not pulling."),
+			logger.InfoSegment(", "),
+			logger.HighlightSegment("pulling changes"),
+			logger.InfoSegment(" with the "),
+			logger.HighlightSegment(strategy),
+			logger.InfoSegment(" pull strategy..."),
 		)
-		return CommitsDiverged, nil
+		if err := repo.pullChanges(ctx, localCommit, remoteCommit); err != nil {
+			return UnknownCommitComparisonResult, err
+		}
+		// The remote commit is in the local branch now, like after a fast-forward
+		return AIsAncestorOfB, nil
 
 	case CommitsEqual:
 		repo.cfg.Logger.MultiColor(logger.VerboseLevel,
@@ -146,3 +231,18 @@ func (repo *GitRepository) HandleCommitComparison(ctx context.Context, localComm
 		return UnknownCommitComparisonResult, fmt.Errorf("unknown commit comparison result: %v", comparison)
 	}
 }
+
+// pullChanges pulls the remote commit in with the pull strategy, then updates
+// the rest of the checkout, recording both
+func (repo *GitRepository) pullChanges(ctx context.Context, localCommit, remoteCommit string) error {
+	events.RecordWithSubject(ctx, repo.cfg.Events, repo.cfg.Logger, events.Event{Type: events.Change, Commit: remoteCommit}, repo.GetCommitSubject)
+	repo.repairBeforePull(ctx)
+
+	start := time.Now()
+	_, err := repo.Pull(ctx)
+	events.RecordWithSubject(ctx, repo.cfg.Events, repo.cfg.Logger, events.Event{Type: events.Pull, Commit: remoteCommit}.WithDuration(time.Since(start)).WithError(err), repo.GetCommitSubject)
+	if err != nil {
+		return fmt.Errorf("failed to pull changes: %w", err)
+	}
+	return repo.syncSubmodulesAfterPull(ctx, localCommit)
+}
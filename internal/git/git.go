@@ -2,7 +2,9 @@ package git
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"os/exec"
 	"strings"
 	"time"
 
@@ -19,7 +21,14 @@ type Repository interface {
 	Pull(ctx context.Context) (string, error)
 	GetCurrentBranch(ctx context.Context) (string, error)
 	IsClean(ctx context.Context) (bool, error)
+	GetGitDir(ctx context.Context) (string, error)
+	ListIgnored(ctx context.Context) ([]string, error)
+	IsIgnored(ctx context.Context, path string) (bool, error)
+	GetCommitSubject(ctx context.Context, commit string) (string, error)
 	HandleCommitComparison(ctx context.Context, localCommit, remoteCommit string) (CommitComparisonResult, error)
+	UpdateTrackedSubmodules(ctx context.Context) (bool, error)
+	PrepareCheckout(ctx context.Context) error
+	TouchesCheckout(ctx context.Context, from, to string) (bool, error)
 }
 
 var _ Repository = &GitRepository{}
@@ -28,6 +37,8 @@ var _ Repository = &GitRepository{}
 type GitRepository struct {
 	cfg      *config.Config
 	executor executor.CommandExecutor
+	// diverged is the remote commit last reported as impossible to fast-forward to
+	diverged string
 }
 
 // Option configures a GitRepository
@@ -53,8 +64,11 @@ func New(cfg *config.Config, opts ...Option) *GitRepository {
 	return r
 }
 
+// localTimeout limits git commands that don't talk to the remote
+const localTimeout = 30 * time.Second
+
 func (r *GitRepository) execGitCmd(ctx context.Context, args ...string) (string, error) {
-	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, localTimeout)
 	defer cancel()
 
 	return r.executor.ExecuteCommand(ctx, "git", args...)
@@ -69,19 +83,42 @@ func (r *GitRepository) GetLatestCommit(ctx context.Context) (string, error) {
 }
 
 func (r *GitRepository) Fetch(ctx context.Context) error {
-	_, err := r.executor.ExecuteCommand(ctx, "git", "-C", r.cfg.GitDir, "fetch")
+	args := r.withoutSubmodules(append([]string{"-C", r.cfg.GitDir, "fetch"}, r.fetchOptions()...)...)
+	_, err := r.execRemote(ctx, "fetch", timeout(r.cfg.GitTimeouts.Fetch, DefaultFetchTimeout), args...)
 	return err
 }
 
+// Pull brings the upstream commits in with the pull strategy. A rebase or merge
+// that can't be finished is aborted, leaving the branch as it was.
 func (r *GitRepository) Pull(ctx context.Context) (string, error) {
-	return r.execGitCmd(ctx, "pull")
+	strategy := r.pullStrategy()
+	if strategy == config.PullReset {
+		return r.resetToUpstream(ctx)
+	}
+	output, err := r.execRemote(ctx, "pull", timeout(r.cfg.GitTimeouts.Pull, DefaultPullTimeout), r.withoutSubmodules(r.pullArgs()...)...)
+	if err == nil || strategy == config.PullFFOnly {
+		return output, err
+	}
+	if abortErr := r.abortPull(ctx); abortErr != nil {
+		return output, fmt.Errorf("the %s pull strategy can't be applied: %w, then %v", strategy, err, abortErr)
+	}
+	return output, fmt.Errorf("the %s pull strategy can't be applied, aborted it: %w", strategy, err)
+}
+
+// withoutSubmodules keeps fetch and pull out of the submodules when they are
+// updated separately, so a submodule failure can't stop the superproject's pull
+func (r *GitRepository) withoutSubmodules(args ...string) []string {
+	if r.submodulesEnabled() {
+		return append(args, "--recurse-submodules=no")
+	}
+	return args
 }
 
 func (r *GitRepository) GetRemoteCommit(ctx context.Context) (string, error) {
 	// Get upstream branch (e.g. "origin/main")
 	remoteBranch, err := r.execGitCmd(ctx, "rev-parse", "--abbrev-ref", "--symbolic-full-name", "@{u}")
 	if err != nil {
-		if strings.Contains(err.Error(), "no upstream") {
+		if errors.Is(err, errz.ErrNoUpstreamBranch) {
 			return "", errz.ErrNoUpstreamBranch
 		}
 		return "", fmt.Errorf("failed to get tracking branch: %w", err)
@@ -95,15 +132,20 @@ func (r *GitRepository) GetRemoteCommit(ctx context.Context) (string, error) {
 	remote := parts[0]
 	branch := parts[1]
 
+	if r.detectByFetch() {
+		return r.fetchRemoteCommit(ctx, remote, branch)
+	}
+
 	// Try specific branch first
-	output, err := r.execGitCmd(ctx, "ls-remote", remote, fmt.Sprintf("refs/heads/%s", branch))
+	lsRemoteTimeout := timeout(r.cfg.GitTimeouts.LsRemote, DefaultLsRemoteTimeout)
+	output, err := r.execRemote(ctx, "ls-remote", lsRemoteTimeout, "ls-remote", remote, fmt.Sprintf("refs/heads/%s", branch))
 	if err != nil {
 		return "", err
 	}
 
 	// If no output, try HEAD as fallback (for default branches)
 	if strings.TrimSpace(output) == "" {
-		output, err = r.execGitCmd(ctx, "ls-remote", remote, "HEAD")
+		output, err = r.execRemote(ctx, "ls-remote", lsRemoteTimeout, "ls-remote", remote, "HEAD")
 		if err != nil {
 			return "", err
 		}
@@ -127,9 +169,59 @@ func (r *GitRepository) GetCurrentBranch(ctx context.Context) (string, error) {
 
 // IsClean returns true if the working directory is clean (no uncommitted changes)
 func (r *GitRepository) IsClean(ctx context.Context) (bool, error) {
-	output, err := r.executor.ExecuteCommand(ctx, "git", "status", "--porcelain")
+	args := []string{"status", "--porcelain"}
+	if r.submodulesEnabled() {
+		// pull-watch moves the submodules itself
+		args = append(args, "--ignore-submodules=all")
+	}
+	output, err := r.executor.ExecuteCommand(ctx, "git", args...)
 	if err != nil {
 		return false, err
 	}
 	return strings.TrimSpace(output) == "", nil
 }
+
+// GetGitDir returns the absolute path of the repository's .git directory
+func (r *GitRepository) GetGitDir(ctx context.Context) (string, error) {
+	output, err := r.execGitCmd(ctx, "rev-parse", "--absolute-git-dir")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(output), nil
+}
+
+// ListIgnored returns the untracked paths ignored by .gitignore, relative to the git directory.
+// Ignored directories are listed once with a trailing slash instead of file by file.
+func (r *GitRepository) ListIgnored(ctx context.Context) ([]string, error) {
+	output, err := r.execGitCmd(ctx, "ls-files", "--others", "--ignored", "--exclude-standard", "--directory")
+	if err != nil {
+		return nil, err
+	}
+	if output == "" {
+		return nil, nil
+	}
+	return strings.Split(output, "\n"), nil
+}
+
+// IsIgnored returns true if the path is ignored by .gitignore
+func (r *GitRepository) IsIgnored(ctx context.Context, path string) (bool, error) {
+	_, err := r.execGitCmd(ctx, "check-ignore", "-q", "--", path)
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+			// Exit code 1 means the path is not ignored
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to check ignore rules: %w", err)
+	}
+	return true, nil
+}
+
+// GetCommitSubject returns the first line of the commit message
+func (r *GitRepository) GetCommitSubject(ctx context.Context, commit string) (string, error) {
+	output, err := r.execGitCmd(ctx, "log", "-1", "--format=%s", commit, "--")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(output), nil
+}
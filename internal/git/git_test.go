@@ -2,12 +2,20 @@ package git
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/ship-digital/pull-watch/internal/config"
 	"github.com/ship-digital/pull-watch/internal/errz"
+	"github.com/ship-digital/pull-watch/internal/executor"
+	"github.com/ship-digital/pull-watch/internal/logger"
 )
 
 // MockExecutor implements CommandExecutor for testing
@@ -207,7 +215,7 @@ func TestGetRemoteCommit(t *testing.T) {
 			}{
 				"git rev-parse --abbrev-ref --symbolic-full-name @{u}": {
 					Output: "",
-					Error:  fmt.Errorf("fatal: no upstream configured for branch 'ls-remote'\nexit status 128"),
+					Error:  gitFailure("fatal: no upstream configured for branch 'ls-remote'"),
 				},
 			},
 			want:    "",
@@ -331,7 +339,7 @@ func TestPull(t *testing.T) {
 				Output string
 				Error  error
 			}{
-				"git pull": {
+				"git pull --no-rebase --ff-only": {
 					Output: "Updating abcdef0..123456\nFast-forward\n main.go | 2 +-\n 1 file changed",
 					Error:  nil,
 				},
@@ -345,7 +353,7 @@ func TestPull(t *testing.T) {
 				Output string
 				Error  error
 			}{
-				"git pull": {
+				"git pull --no-rebase --ff-only": {
 					Output: "",
 					Error:  fmt.Errorf("error: Your local changes would be overwritten by merge"),
 				},
@@ -359,7 +367,7 @@ func TestPull(t *testing.T) {
 				Output string
 				Error  error
 			}{
-				"git pull": {
+				"git pull --no-rebase --ff-only": {
 					Output: "Already up to date.",
 					Error:  nil,
 				},
@@ -373,7 +381,7 @@ func TestPull(t *testing.T) {
 				Output string
 				Error  error
 			}{
-				"git pull": {
+				"git pull --no-rebase --ff-only": {
 					Output: "",
 					Error:  fmt.Errorf("fatal: unable to access: Could not resolve host"),
 				},
@@ -387,7 +395,7 @@ func TestPull(t *testing.T) {
 				Output string
 				Error  error
 			}{
-				"git pull": {
+				"git pull --no-rebase --ff-only": {
 					Output: "",
 					Error:  fmt.Errorf("fatal: 'origin' does not appear to be a git repository"),
 				},
@@ -401,7 +409,7 @@ func TestPull(t *testing.T) {
 				Output string
 				Error  error
 			}{
-				"git pull": {
+				"git pull --no-rebase --ff-only": {
 					Output: "",
 					Error:  fmt.Errorf("fatal: No remote repository specified."),
 				},
@@ -427,3 +435,645 @@ func TestPull(t *testing.T) {
 		})
 	}
 }
+
+func TestHandleCommitComparison_CheckOnly(t *testing.T) {
+	mockExecutor := &MockExecutor{Responses: map[string]struct {
+		Output string
+		Error  error
+	}{
+		"git cat-file -e abc123":                                  {},
+		"git cat-file -e def456":                                  {},
+		"git -C /fake/dir merge-base --is-ancestor abc123 def456": {},
+		"git -C /fake/dir merge-base --is-ancestor def456 abc123": {
+			Error: fmt.Errorf("command failed: %w", exec.Command("false").Run()),
+		},
+		// No "git pull" response: calling it fails the test
+	}}
+
+	cfg := &config.Config{GitDir: "/fake/dir", Logger: logger.New(), CheckOnly: true}
+	repo := New(cfg, WithExecutor(mockExecutor))
+
+	got, err := repo.HandleCommitComparison(context.Background(), "abc123", "def456")
+	if err != nil {
+		t.Fatalf("HandleCommitComparison() error = %v", err)
+	}
+	if got != AIsAncestorOfB {
+		t.Errorf("HandleCommitComparison() = %v, want %v", got, AIsAncestorOfB)
+	}
+	if got.String() != "behind" {
+		t.Errorf("String() = %q, want %q", got.String(), "behind")
+	}
+}
+
+// gitFailure returns the error the executor reports when git fails with stderr
+func gitFailure(stderr string) error {
+	return &errz.CommandError{Command: "git", ExitCode: 128, Stderr: stderr, Err: fmt.Errorf("exit status 128"), Kind: errz.ClassifyGit(stderr)}
+}
+
+// flakyExecutor fails the first failures calls with err, then succeeds with output
+type flakyExecutor struct {
+	failures int
+	err      error
+	output   string
+	calls    int
+}
+
+func (f *flakyExecutor) GetConfig() *config.Config {
+	return nil
+}
+
+func (f *flakyExecutor) ExecuteCommand(ctx context.Context, name string, args ...string) (string, error) {
+	f.calls++
+	if f.err == context.DeadlineExceeded {
+		<-ctx.Done()
+		return "", fmt.Errorf("command failed: %w", ctx.Err())
+	}
+	if f.calls <= f.failures {
+		return "", f.err
+	}
+	return f.output, nil
+}
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{err: gitFailure("fatal: unable to access 'https://example.com/repo.git/': Could not resolve host: example.com"), want: true},
+		{err: gitFailure("ssh: connect to host example.com port 22: Connection refused"), want: true},
+		{err: gitFailure("error: RPC failed; curl 56 Recv failure: Connection reset by peer"), want: true},
+		{err: gitFailure("fatal: unable to access 'https://example.com/repo.git/': The requested URL returned error: 503"), want: true},
+		{err: fmt.Errorf("git ls-remote timed out after 30s: %w", context.DeadlineExceeded), want: true},
+		{err: gitFailure("fatal: unable to access 'https://example.com/repo.git/': The requested URL returned error: 403"), want: false},
+		{err: gitFailure("fatal: Authentication failed for 'https://example.com/repo.git/'"), want: false},
+		{err: errz.ErrNoUpstreamBranch, want: false},
+		{err: nil, want: false},
+	}
+
+	for _, tt := range tests {
+		if got := IsTransient(tt.err); got != tt.want {
+			t.Errorf("IsTransient(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestExecRemote_Retries(t *testing.T) {
+	transient := gitFailure("fatal: unable to access 'https://example.com/repo.git/': Failed to connect to example.com port 443")
+	tests := []struct {
+		name      string
+		exec      *flakyExecutor
+		retries   int
+		wantCalls int
+		wantErr   bool
+	}{
+		{name: "transient error is retried", exec: &flakyExecutor{failures: 2, err: transient, output: "ok"}, retries: 3, wantCalls: 3},
+		{name: "retries run out", exec: &flakyExecutor{failures: 5, err: transient}, retries: 2, wantCalls: 3, wantErr: true},
+		{name: "permanent error isn't retried", exec: &flakyExecutor{failures: 5, err: gitFailure("fatal: Authentication failed")}, retries: 3, wantCalls: 1, wantErr: true},
+		{name: "no retries by default", exec: &flakyExecutor{failures: 1, err: transient}, wantCalls: 1, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{GitDir: "/fake/dir", GitRetries: tt.retries, RetryBackoff: time.Millisecond, Logger: logger.New()}
+			repo := New(cfg, WithExecutor(tt.exec))
+
+			_, err := repo.Pull(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Pull() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.exec.calls != tt.wantCalls {
+				t.Errorf("git ran %d times, want %d", tt.exec.calls, tt.wantCalls)
+			}
+		})
+	}
+
+	t.Run("timeout", func(t *testing.T) {
+		cfg := &config.Config{GitDir: "/fake/dir", GitTimeouts: config.GitTimeouts{Fetch: 20 * time.Millisecond}}
+		err := New(cfg, WithExecutor(&flakyExecutor{err: context.DeadlineExceeded})).Fetch(context.Background())
+		if err == nil || !strings.Contains(err.Error(), "fetch timed out after 20ms") || !IsTransient(err) {
+			t.Errorf("Fetch() error = %v, want a transient timeout", err)
+		}
+	})
+}
+
+func TestRepairLeftovers(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"-c", "user.email=a@b", "-c", "user.name=a", "commit", "-q", "--allow-empty", "-m", "one"},
+	} {
+		if out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+
+	stale := time.Now().Add(-time.Hour)
+	for _, name := range []string{"index.lock", filepath.Join("refs", "heads", "main.lock")} {
+		path := filepath.Join(dir, ".git", name)
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, stale, stale); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, ".git", "HEAD.lock"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	repo := New(&config.Config{GitDir: dir, Logger: logger.New()})
+	leftovers, err := repo.FindLeftovers(context.Background())
+	if err != nil {
+		t.Fatalf("FindLeftovers() error = %v", err)
+	}
+	if len(leftovers) != 3 {
+		t.Fatalf("FindLeftovers() = %v, want 3 lock files", leftovers)
+	}
+
+	repaired, err := repo.RepairLeftovers(context.Background(), leftovers, 10*time.Minute)
+	if err != nil {
+		t.Fatalf("RepairLeftovers() error = %v", err)
+	}
+	if len(repaired) != 2 {
+		t.Errorf("RepairLeftovers() = %v, want the 2 stale locks", repaired)
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".git", "index.lock")); !os.IsNotExist(err) {
+		t.Error("stale index.lock was not removed")
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".git", "HEAD.lock")); err != nil {
+		t.Error("recent HEAD.lock should be left alone")
+	}
+}
+
+func TestSyncSubmodulesAfterPull(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	// Submodules are cloned over the file protocol, which git disallows by default
+	t.Setenv("GIT_CONFIG_COUNT", "1")
+	t.Setenv("GIT_CONFIG_KEY_0", "protocol.file.allow")
+	t.Setenv("GIT_CONFIG_VALUE_0", "always")
+
+	root := t.TempDir()
+	git := func(dir string, args ...string) string {
+		t.Helper()
+		args = append([]string{"-C", dir, "-c", "user.email=a@b", "-c", "user.name=a"}, args...)
+		out, err := exec.Command("git", args...).CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	lib, upstream, work := filepath.Join(root, "lib"), filepath.Join(root, "upstream"), filepath.Join(root, "work")
+	for _, dir := range []string{lib, upstream} {
+		git(root, "init", "-q", dir)
+		git(dir, "commit", "-q", "--allow-empty", "-m", "one")
+	}
+	git(upstream, "submodule", "add", "-q", lib, "lib")
+	git(upstream, "commit", "-q", "-m", "add lib")
+	git(root, "clone", "-q", "--recurse-submodules", upstream, work)
+	repo := New(&config.Config{GitDir: work, Logger: logger.New(), Submodules: config.Submodules{Mode: config.SubmodulesUpdate}})
+	ctx := context.Background()
+
+	pull := func() (string, error) {
+		t.Helper()
+		before := git(work, "rev-parse", "HEAD")
+		if _, err := repo.Pull(ctx); err != nil {
+			t.Fatalf("Pull() error = %v", err)
+		}
+		return before, repo.syncSubmodulesAfterPull(ctx, before)
+	}
+
+	// A commit pinning a new lib commit checks it out
+	git(lib, "commit", "-q", "--allow-empty", "-m", "two")
+	git(upstream+"/lib", "pull", "-q")
+	git(upstream, "commit", "-q", "-am", "bump lib")
+	if _, err := pull(); err != nil {
+		t.Fatalf("syncSubmodulesAfterPull() error = %v", err)
+	}
+	if got, want := git(work+"/lib", "rev-parse", "HEAD"), git(lib, "rev-parse", "HEAD"); got != want {
+		t.Errorf("lib at %s after the pull, want %s", got, want)
+	}
+
+	// A commit pinning a lib commit that was never pushed rolls back
+	git(upstream+"/lib", "commit", "-q", "--allow-empty", "-m", "unpushed")
+	git(upstream, "commit", "-q", "-am", "pin unpushed")
+	before, err := pull()
+	if err == nil {
+		t.Fatal("syncSubmodulesAfterPull() should fail when the pinned commit can't be fetched")
+	}
+	if got := git(work, "rev-parse", "HEAD"); got != before {
+		t.Errorf("HEAD at %s after the failed update, want it rolled back to %s", got, before)
+	}
+	if got, want := git(work+"/lib", "rev-parse", "HEAD"), git(lib, "rev-parse", "HEAD"); got != want {
+		t.Errorf("lib at %s after the rollback, want %s", got, want)
+	}
+}
+
+// countingExecutor runs commands with next and counts them by git subcommand
+type countingExecutor struct {
+	next  executor.CommandExecutor
+	count map[string]int
+}
+
+func (c *countingExecutor) GetConfig() *config.Config {
+	return c.next.GetConfig()
+}
+
+func (c *countingExecutor) ExecuteCommand(ctx context.Context, name string, args ...string) (string, error) {
+	for i, arg := range args {
+		if arg == "submodule" || arg == "ls-remote" {
+			c.count[strings.Join(args[i:min(i+2, len(args))], " ")]++
+			break
+		}
+	}
+	return c.next.ExecuteCommand(ctx, name, args...)
+}
+
+func TestTrackedSubmodules(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	t.Setenv("GIT_CONFIG_COUNT", "1")
+	t.Setenv("GIT_CONFIG_KEY_0", "protocol.file.allow")
+	t.Setenv("GIT_CONFIG_VALUE_0", "always")
+
+	root := t.TempDir()
+	git := func(dir string, args ...string) string {
+		t.Helper()
+		args = append([]string{"-C", dir, "-c", "user.email=a@b", "-c", "user.name=a"}, args...)
+		out, err := exec.Command("git", args...).CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	lib, pinned, upstream, work := filepath.Join(root, "lib"), filepath.Join(root, "pinned"), filepath.Join(root, "upstream"), filepath.Join(root, "work")
+	for _, dir := range []string{lib, pinned, upstream} {
+		git(root, "init", "-q", dir)
+		git(dir, "commit", "-q", "--allow-empty", "-m", "one")
+	}
+	git(upstream, "submodule", "add", "-q", "-b", git(lib, "rev-parse", "--abbrev-ref", "HEAD"), lib, "lib")
+	git(upstream, "submodule", "add", "-q", pinned, "pinned")
+	git(upstream, "commit", "-q", "-m", "add submodules")
+	git(root, "clone", "-q", "--recurse-submodules", upstream, work)
+
+	cfg := &config.Config{GitDir: work, Logger: logger.New(), Submodules: config.Submodules{Mode: config.SubmodulesUpdate, Remote: true}}
+	counter := &countingExecutor{next: executor.New(cfg), count: map[string]int{}}
+	repo := New(cfg, WithExecutor(counter))
+	ctx := context.Background()
+
+	// Nothing moved, so nothing is updated
+	if moved, err := repo.UpdateTrackedSubmodules(ctx); err != nil || moved {
+		t.Fatalf("UpdateTrackedSubmodules() = %v, %v, want nothing moved", moved, err)
+	}
+	if counter.count["submodule update"] != 0 || counter.count["ls-remote origin"] != 1 {
+		t.Errorf("commands run = %v, want only the remote head of lib looked up", counter.count)
+	}
+
+	git(lib, "commit", "-q", "--allow-empty", "-m", "two")
+	if moved, err := repo.UpdateTrackedSubmodules(ctx); err != nil || !moved {
+		t.Fatalf("UpdateTrackedSubmodules() = %v, %v, want lib moved", moved, err)
+	}
+	if got, want := git(work+"/lib", "rev-parse", "HEAD"), git(lib, "rev-parse", "HEAD"); got != want {
+		t.Errorf("lib at %s, want its branch head %s", got, want)
+	}
+
+	// A pull keeps lib on its branch head and pinned on the commit the superproject pins
+	git(lib, "commit", "-q", "--allow-empty", "-m", "three")
+	git(pinned, "commit", "-q", "--allow-empty", "-m", "two")
+	git(upstream+"/pinned", "pull", "-q")
+	git(upstream, "commit", "-q", "-am", "bump pinned")
+	before := git(work, "rev-parse", "HEAD")
+	if _, err := repo.Pull(ctx); err != nil {
+		t.Fatalf("Pull() error = %v", err)
+	}
+	if err := repo.syncSubmodulesAfterPull(ctx, before); err != nil {
+		t.Fatalf("syncSubmodulesAfterPull() error = %v", err)
+	}
+	if got, want := git(work+"/lib", "rev-parse", "HEAD"), git(lib, "rev-parse", "HEAD"); got != want {
+		t.Errorf("lib at %s after the pull, want its branch head %s", got, want)
+	}
+	if got, want := git(work+"/pinned", "rev-parse", "HEAD"), git(pinned, "rev-parse", "HEAD"); got != want {
+		t.Errorf("pinned at %s after the pull, want %s", got, want)
+	}
+	if moved, err := repo.UpdateTrackedSubmodules(ctx); err != nil || moved {
+		t.Errorf("UpdateTrackedSubmodules() after the pull = %v, %v, want nothing left to move", moved, err)
+	}
+}
+
+func TestSparseCheckout(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	root := t.TempDir()
+	upstream, work := filepath.Join(root, "upstream"), filepath.Join(root, "work")
+	git := func(dir string, args ...string) string {
+		t.Helper()
+		args = append([]string{"-C", dir, "-c", "user.email=a@b", "-c", "user.name=a"}, args...)
+		out, err := exec.Command("git", args...).CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	commit := func(file string) string {
+		t.Helper()
+		path := filepath.Join(upstream, filepath.FromSlash(file))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(time.Now().String()), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		git(upstream, "add", "-A")
+		git(upstream, "commit", "-q", "-m", file)
+		return git(upstream, "rev-parse", "HEAD")
+	}
+
+	git(root, "init", "-q", upstream)
+	for _, file := range []string{"README.md", "services/ml/model.py", "services/web/app.js"} {
+		commit(file)
+	}
+	git(root, "clone", "-q", upstream, work)
+
+	repo := New(&config.Config{GitDir: work, Logger: logger.New(), Sparse: []string{"services/ml"}})
+	ctx := context.Background()
+	if err := repo.PrepareCheckout(ctx); err != nil {
+		t.Fatalf("PrepareCheckout() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(work, "services", "web", "app.js")); !os.IsNotExist(err) {
+		t.Error("services/web is outside the sparse checkout and should be gone")
+	}
+	if _, err := os.Stat(filepath.Join(work, "services", "ml", "model.py")); err != nil {
+		t.Errorf("services/ml should be checked out: %v", err)
+	}
+	// Already set up, nothing to do the second time
+	if err := repo.PrepareCheckout(ctx); err != nil {
+		t.Fatalf("PrepareCheckout() again error = %v", err)
+	}
+
+	base := git(work, "rev-parse", "HEAD")
+	for _, tt := range []struct {
+		file string
+		want bool
+	}{
+		{"services/web/index.html", false},
+		{"services/ml/weights.txt", true},
+		{"services/shared.txt", true}, // Files next to a sparse directory come along in cone mode
+		{"Makefile", true},
+		{"docs/guide.md", false},
+	} {
+		head := commit(tt.file)
+		git(work, "fetch", "-q")
+		got, err := repo.TouchesCheckout(ctx, base, head)
+		if err != nil {
+			t.Fatalf("TouchesCheckout(%s) error = %v", tt.file, err)
+		}
+		if got != tt.want {
+			t.Errorf("TouchesCheckout() for a change to %s = %v, want %v", tt.file, got, tt.want)
+		}
+		base = head
+	}
+}
+
+func TestLFSInclude(t *testing.T) {
+	mock := &MockExecutor{Responses: map[string]struct {
+		Output string
+		Error  error
+	}{
+		"git lfs ls-files --name-only": {Output: "logo.png\nservices/ml/model.bin\nservices/ml/data/set.parquet\nservices/web/hero.mp4\n"},
+	}}
+
+	repo := New(&config.Config{Logger: logger.New(), LFS: true}, WithExecutor(mock))
+	if got, err := repo.lfsInclude(context.Background(), ""); err != nil || !slices.Equal(got, []string{"."}) {
+		t.Errorf("lfsInclude() = %v, %v, want everything without a sparse checkout", got, err)
+	}
+
+	repo = New(&config.Config{Logger: logger.New(), LFS: true, Sparse: []string{"services/ml"}}, WithExecutor(mock))
+	want := []string{"logo.png", "services/ml"}
+	if got, err := repo.lfsInclude(context.Background(), ""); err != nil || !slices.Equal(got, want) {
+		t.Errorf("lfsInclude() = %v, %v, want %v", got, err, want)
+	}
+
+	repo = New(&config.Config{Logger: logger.New(), LFS: true, Sparse: []string{"docs"}}, WithExecutor(&MockExecutor{Responses: map[string]struct {
+		Output string
+		Error  error
+	}{
+		"git lfs ls-files --name-only": {Output: "services/web/hero.mp4\n"},
+	}}))
+	if got, err := repo.lfsInclude(context.Background(), ""); err != nil || got != nil {
+		t.Errorf("lfsInclude() = %v, %v, want nothing to check out", got, err)
+	}
+
+	// Local mode checks out what's there without fetching, any other command fails
+	repo = New(&config.Config{Logger: logger.New(), LFS: true, Mode: config.ModeLocal}, WithExecutor(&MockExecutor{Responses: map[string]struct {
+		Output string
+		Error  error
+	}{
+		"git lfs ls-files --name-only": {Output: "logo.png\n"},
+		"git lfs checkout -- .":        {},
+	}}))
+	if err := repo.checkoutLFS(context.Background(), ""); err != nil {
+		t.Errorf("checkoutLFS() in local mode error = %v", err)
+	}
+}
+
+func TestFetchDetectionShallow(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	root := t.TempDir()
+	upstream, work := filepath.Join(root, "upstream"), filepath.Join(root, "work")
+	git := func(dir string, args ...string) string {
+		t.Helper()
+		args = append([]string{"-C", dir, "-c", "user.email=a@b", "-c", "user.name=a"}, args...)
+		out, err := exec.Command("git", args...).CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	commits := func(n int) {
+		for i := 0; i < n; i++ {
+			git(upstream, "commit", "-q", "--allow-empty", "-m", fmt.Sprint(i))
+		}
+	}
+
+	git(root, "init", "-q", upstream)
+	commits(10)
+	// Shallow clones need a URL, local paths are hard linked in full
+	git(root, "clone", "-q", "--depth", "2", "file://"+filepath.ToSlash(upstream), work)
+	commits(10)
+
+	repo := New(&config.Config{GitDir: work, Logger: logger.New(), Detection: config.Detection{Strategy: config.DetectFetch, Depth: 2}})
+	ctx := context.Background()
+	remote, err := repo.GetRemoteCommit(ctx)
+	if err != nil {
+		t.Fatalf("GetRemoteCommit() error = %v", err)
+	}
+	if want := git(upstream, "rev-parse", "HEAD"); remote != want {
+		t.Errorf("GetRemoteCommit() = %s, want %s", remote, want)
+	}
+
+	// The fetch left a gap between the local and the remote commits
+	local := git(work, "rev-parse", "HEAD")
+	got, err := repo.compareCommits(ctx, local, remote)
+	if err != nil {
+		t.Fatalf("compareCommits() error = %v", err)
+	}
+	if got != AIsAncestorOfB {
+		t.Errorf("compareCommits() = %v, want behind once the clone was deepened", got)
+	}
+	if git(work, "rev-parse", "--is-shallow-repository") != "true" {
+		t.Error("the clone should still be shallow, deepened only as far as needed")
+	}
+}
+
+func TestFetchDetectionDryRun(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	root := t.TempDir()
+	upstream, work := filepath.Join(root, "upstream"), filepath.Join(root, "work")
+	git := func(dir string, args ...string) string {
+		t.Helper()
+		args = append([]string{"-C", dir, "-c", "user.email=a@b", "-c", "user.name=a"}, args...)
+		out, err := exec.Command("git", args...).CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+
+	git(root, "init", "-q", upstream)
+	git(upstream, "commit", "-q", "--allow-empty", "-m", "first")
+	git(root, "clone", "-q", upstream, work)
+	git(upstream, "commit", "-q", "--allow-empty", "-m", "second")
+	tracking := git(work, "rev-parse", "@{u}")
+
+	cfg := &config.Config{GitDir: work, Logger: logger.New(), DryRun: true, Detection: config.Detection{Strategy: config.DetectFetch}}
+	repo := New(cfg, WithExecutor(executor.NewDryRun(cfg, executor.New(cfg))))
+	ctx := context.Background()
+	remote, err := repo.GetRemoteCommit(ctx)
+	if err != nil {
+		t.Fatalf("GetRemoteCommit() error = %v", err)
+	}
+	if want := git(upstream, "rev-parse", "HEAD"); remote != want {
+		t.Errorf("GetRemoteCommit() = %s, want %s", remote, want)
+	}
+	if _, err := repo.compareCommits(ctx, git(work, "rev-parse", "HEAD"), remote); !errors.Is(err, errz.ErrMissingRef) {
+		t.Errorf("compareCommits() error = %v, want %v", err, errz.ErrMissingRef)
+	}
+	if got := git(work, "rev-parse", "@{u}"); got != tracking {
+		t.Errorf("a dry run moved the remote-tracking branch from %s to %s", tracking, got)
+	}
+}
+
+func TestPullStrategies(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	for _, name := range []string{"GIT_AUTHOR_NAME", "GIT_COMMITTER_NAME"} {
+		t.Setenv(name, "a")
+	}
+	for _, email := range []string{"GIT_AUTHOR_EMAIL", "GIT_COMMITTER_EMAIL"} {
+		t.Setenv(email, "a@b")
+	}
+	git := func(dir string, args ...string) string {
+		t.Helper()
+		out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	commit := func(dir, file, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, file), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		git(dir, "add", file)
+		git(dir, "commit", "-q", "-m", file)
+	}
+	// diverged returns a clone with a local commit the upstream doesn't have and
+	// the upstream with one the clone doesn't have
+	diverged := func(localFile string) (work, local, remote string) {
+		root := t.TempDir()
+		upstream := filepath.Join(root, "upstream")
+		work = filepath.Join(root, "work")
+		git(root, "init", "-q", upstream)
+		commit(upstream, "README", "one")
+		git(root, "clone", "-q", upstream, work)
+		// None of these may change what the strategy does
+		git(work, "config", "pull.rebase", "true")
+		git(work, "config", "pull.ff", "only")
+		git(work, "config", "merge.ff", "only")
+		commit(upstream, "remote.txt", "remote")
+		commit(work, localFile, "local")
+		return work, git(work, "rev-parse", "HEAD"), git(upstream, "rev-parse", "HEAD")
+	}
+
+	tests := []struct {
+		strategy string
+		want     CommitComparisonResult
+		check    func(t *testing.T, work, local, remote string)
+	}{
+		{config.PullFFOnly, CommitsDiverged, func(t *testing.T, work, local, remote string) {
+			if head := git(work, "rev-parse", "HEAD"); head != local {
+				t.Errorf("HEAD = %s, want the local commit %s left alone", head, local)
+			}
+		}},
+		{config.PullRebase, AIsAncestorOfB, func(t *testing.T, work, local, remote string) {
+			if parent := git(work, "rev-parse", "HEAD~1"); parent != remote {
+				t.Errorf("HEAD~1 = %s, want the local commit rebased onto %s", parent, remote)
+			}
+		}},
+		{config.PullMerge, AIsAncestorOfB, func(t *testing.T, work, local, remote string) {
+			if parents := git(work, "rev-parse", "HEAD^1", "HEAD^2"); parents != local+"\n"+remote {
+				t.Errorf("HEAD parents = %q, want a merge of %s and %s", parents, local, remote)
+			}
+		}},
+		{config.PullReset, AIsAncestorOfB, func(t *testing.T, work, local, remote string) {
+			if head := git(work, "rev-parse", "HEAD"); head != remote {
+				t.Errorf("HEAD = %s, want the remote commit %s", head, remote)
+			}
+			if _, err := os.Stat(filepath.Join(work, "local.txt")); !os.IsNotExist(err) {
+				t.Error("the local commit's file should be gone after a reset")
+			}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.strategy, func(t *testing.T) {
+			work, local, remote := diverged("local.txt")
+			repo := New(&config.Config{GitDir: work, Logger: logger.New(), PullStrategy: tt.strategy})
+			got, err := repo.HandleCommitComparison(context.Background(), local, remote)
+			if err != nil {
+				t.Fatalf("HandleCommitComparison() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("HandleCommitComparison() = %v, want %v", got, tt.want)
+			}
+			tt.check(t, work, local, remote)
+		})
+	}
+
+	t.Run("conflict", func(t *testing.T) {
+		work, local, remote := diverged("remote.txt")
+		repo := New(&config.Config{GitDir: work, Logger: logger.New(), PullStrategy: config.PullRebase})
+		_, err := repo.HandleCommitComparison(context.Background(), local, remote)
+		if !errors.Is(err, errz.ErrMergeConflict) {
+			t.Fatalf("HandleCommitComparison() error = %v, want a merge conflict", err)
+		}
+		if head := git(work, "rev-parse", "HEAD"); head != local {
+			t.Errorf("HEAD = %s, want %s once the rebase is aborted", head, local)
+		}
+		if leftovers, err := repo.FindLeftovers(context.Background()); err != nil || len(leftovers) != 0 {
+			t.Errorf("FindLeftovers() = %v, %v, want the rebase aborted", leftovers, err)
+		}
+	})
+}
@@ -0,0 +1,60 @@
+package git
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ship-digital/pull-watch/internal/config"
+)
+
+// pullStrategy returns the configured pull strategy, fast-forward only by default
+func (r *GitRepository) pullStrategy() string {
+	if r.cfg.PullStrategy == "" {
+		return config.PullFFOnly
+	}
+	return r.cfg.PullStrategy
+}
+
+// pullArgs are the arguments of git pull for the pull strategy. Every choice is
+// made on the command line, so pull.rebase, pull.ff, merge.ff and
+// rebase.autoStash in the git config don't matter.
+func (r *GitRepository) pullArgs() []string {
+	switch r.pullStrategy() {
+	case config.PullRebase:
+		return []string{"pull", "--rebase", "--no-autostash"}
+	case config.PullMerge:
+		return []string{"pull", "--no-rebase", "--ff", "--no-edit", "--no-autostash"}
+	default:
+		return []string{"pull", "--no-rebase", "--ff-only"}
+	}
+}
+
+// resetToUpstream fetches the upstream branch and moves the local branch, the
+// index and the working tree to it, local commits and changes included
+func (r *GitRepository) resetToUpstream(ctx context.Context) (string, error) {
+	if err := r.Fetch(ctx); err != nil {
+		return "", err
+	}
+	return r.execWithTimeout(ctx, "reset", timeout(r.cfg.GitTimeouts.Pull, DefaultPullTimeout), "reset", "--hard", "@{u}")
+}
+
+// abortPull aborts the rebase or merge a failed pull stopped in the middle of,
+// so the branch is back where it was before the pull
+func (r *GitRepository) abortPull(ctx context.Context) error {
+	leftovers, err := r.FindLeftovers(ctx)
+	if err != nil {
+		return err
+	}
+	for _, l := range leftovers {
+		for _, op := range operations {
+			if op.kind != l.Kind || (op.kind != "rebase" && op.kind != "merge") {
+				continue
+			}
+			if _, err := r.execGitCmd(ctx, op.abort...); err != nil {
+				return fmt.Errorf("failed to abort the %s: %w", l.Kind, err)
+			}
+			break
+		}
+	}
+	return nil
+}
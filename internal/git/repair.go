@@ -0,0 +1,222 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ship-digital/pull-watch/internal/logger"
+)
+
+// DefaultStaleLockAge is how old a lock file or an interrupted operation has to be before it's repaired
+const DefaultStaleLockAge = 10 * time.Minute
+
+// LeftoverLock is the kind of a lock file left behind by a git process
+const LeftoverLock = "lock"
+
+// Leftover is something an interrupted git operation left behind in the repository
+type Leftover struct {
+	// Kind is LeftoverLock or the interrupted operation, like "merge" or "rebase"
+	Kind string
+	// Path is the lock file, or the file marking the operation as in progress
+	Path string
+	// Age is the time since the file was last modified
+	Age time.Duration
+}
+
+func (l Leftover) String() string {
+	age := l.Age.Round(time.Second)
+	if l.Kind == LeftoverLock {
+		return fmt.Sprintf("lock file %s (%s old)", l.Path, age)
+	}
+	return fmt.Sprintf("interrupted %s (%s old)", l.Kind, age)
+}
+
+// operations are the files marking a git operation in progress and the command that aborts it
+var operations = []struct {
+	kind   string
+	marker string
+	abort  []string
+}{
+	{"am", filepath.Join("rebase-apply", "applying"), []string{"am", "--abort"}},
+	{"rebase", "rebase-apply", []string{"rebase", "--abort"}},
+	{"rebase", "rebase-merge", []string{"rebase", "--abort"}},
+	{"merge", "MERGE_HEAD", []string{"merge", "--abort"}},
+	{"cherry-pick", "CHERRY_PICK_HEAD", []string{"cherry-pick", "--abort"}},
+	{"revert", "REVERT_HEAD", []string{"revert", "--abort"}},
+}
+
+// lockFiles are the lock files git takes in a repository, refs are looked up separately
+var lockFiles = []string{"index.lock", "HEAD.lock", "ORIG_HEAD.lock", "FETCH_HEAD.lock", "config.lock", "packed-refs.lock", "shallow.lock"}
+
+// FindLeftovers returns the lock files and unfinished operations in the repository
+func (r *GitRepository) FindLeftovers(ctx context.Context) ([]Leftover, error) {
+	gitDir, err := r.GetGitDir(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	var leftovers []Leftover
+
+	// Linked worktrees share refs and config with the main repository
+	dirs := []string{gitDir}
+	if common, err := os.ReadFile(filepath.Join(gitDir, "commondir")); err == nil {
+		commonDir := strings.TrimSpace(string(common))
+		if !filepath.IsAbs(commonDir) {
+			commonDir = filepath.Join(gitDir, commonDir)
+		}
+		dirs = append(dirs, filepath.Clean(commonDir))
+	}
+
+	for _, dir := range dirs {
+		for _, name := range lockFiles {
+			if info, err := os.Stat(filepath.Join(dir, name)); err == nil {
+				leftovers = append(leftovers, Leftover{Kind: LeftoverLock, Path: filepath.Join(dir, name), Age: now.Sub(info.ModTime())})
+			}
+		}
+		err := filepath.WalkDir(filepath.Join(dir, "refs"), func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() || !strings.HasSuffix(path, ".lock") {
+				return nil
+			}
+			if info, err := d.Info(); err == nil {
+				leftovers = append(leftovers, Leftover{Kind: LeftoverLock, Path: path, Age: now.Sub(info.ModTime())})
+			}
+			return nil
+		})
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("failed to look for lock files: %w", err)
+		}
+	}
+
+	// rebase-apply is shared by rebase and am, only the first match counts
+	seen := map[string]bool{}
+	for _, op := range operations {
+		stateDir := strings.SplitN(op.marker, string(filepath.Separator), 2)[0]
+		info, err := os.Stat(filepath.Join(gitDir, op.marker))
+		if err != nil || seen[stateDir] {
+			continue
+		}
+		seen[stateDir] = true
+		leftovers = append(leftovers, Leftover{Kind: op.kind, Path: filepath.Join(gitDir, op.marker), Age: now.Sub(info.ModTime())})
+	}
+	return leftovers, nil
+}
+
+// RepairLeftovers removes the lock files and aborts the operations older than
+// staleAfter, unless a git process is still working in the repository. It
+// returns the leftovers it repaired.
+func (r *GitRepository) RepairLeftovers(ctx context.Context, leftovers []Leftover, staleAfter time.Duration) ([]Leftover, error) {
+	var stale []Leftover
+	for _, l := range leftovers {
+		if l.Age >= staleAfter {
+			stale = append(stale, l)
+		}
+	}
+	if len(stale) == 0 {
+		return nil, nil
+	}
+
+	gitDir, err := r.GetGitDir(ctx)
+	if err != nil {
+		return nil, err
+	}
+	root, err := r.execGitCmd(ctx, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, err
+	}
+	running, err := gitRunning([]string{root, gitDir})
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for running git processes: %w", err)
+	}
+	if running {
+		return nil, fmt.Errorf("a git process is still running in %s, leaving its files alone", root)
+	}
+
+	// Locks go first, aborting an operation needs the index
+	var repaired []Leftover
+	for _, l := range stale {
+		if l.Kind != LeftoverLock {
+			continue
+		}
+		if !r.cfg.DryRun {
+			if err := os.Remove(l.Path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return repaired, fmt.Errorf("failed to remove %s: %w", l.Path, err)
+			}
+		}
+		repaired = append(repaired, l)
+	}
+	for _, l := range stale {
+		if l.Kind == LeftoverLock {
+			continue
+		}
+		for _, op := range operations {
+			if op.kind != l.Kind {
+				continue
+			}
+			if _, err := r.execGitCmd(ctx, op.abort...); err != nil {
+				return repaired, fmt.Errorf("failed to abort the interrupted %s: %w", l.Kind, err)
+			}
+			repaired = append(repaired, l)
+			break
+		}
+	}
+	return repaired, nil
+}
+
+// repairBeforePull clears what an interrupted pull left behind, so that it
+// doesn't fail every pull from now on. Problems are logged, the pull goes ahead
+// regardless and reports its own error.
+func (r *GitRepository) repairBeforePull(ctx context.Context) {
+	if r.cfg.StaleLockAge <= 0 {
+		return
+	}
+	leftovers, err := r.FindLeftovers(ctx)
+	if err == nil {
+		leftovers, err = r.RepairLeftovers(ctx, leftovers, r.cfg.StaleLockAge)
+	}
+	for _, l := range leftovers {
+		action := "Removed stale "
+		if l.Kind != LeftoverLock {
+			action = "Aborted "
+		}
+		if r.cfg.DryRun {
+			action = "[dry-run] Would have " + strings.ToLower(action)
+		}
+		r.cfg.Logger.MultiColor(logger.QuietLevel,
+			logger.InfoSegment(action),
+			logger.HighlightSegment(l.String()),
+			logger.InfoSegment(" before pulling"),
+		)
+	}
+	if err != nil {
+		r.cfg.Logger.MultiColor(logger.DefaultLevel,
+			logger.ErrorSegment("Failed to repair the repository before pulling: "),
+			logger.HighlightSegment(err.Error()),
+		)
+	}
+}
+
+// within reports whether path is one of dirs or inside one of them
+func within(path string, dirs []string) bool {
+	if !filepath.IsAbs(path) {
+		return false
+	}
+	for _, dir := range dirs {
+		rel, err := filepath.Rel(dir, path)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// isGit reports whether a process name is git or one of its helpers, like git-remote-https
+func isGit(name string) bool {
+	name = strings.TrimSuffix(name, ".exe")
+	return name == "git" || strings.HasPrefix(name, "git-")
+}
@@ -0,0 +1,41 @@
+package git
+
+import (
+	"errors"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// gitRunning reports whether a git process works in one of dirs, by its
+// working directory or its arguments
+func gitRunning(dirs []string) (bool, error) {
+	// -c matches the git-* helpers too, lsof exits with 1 when nothing matches
+	out, err := exec.Command("lsof", "-a", "-c", "git", "-d", "cwd", "-Fn").Output()
+	var exitErr *exec.ExitError
+	if err != nil && !(errors.As(err, &exitErr) && exitErr.ExitCode() == 1) {
+		return false, err
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		if path, ok := strings.CutPrefix(line, "n"); ok && within(path, dirs) {
+			return true, nil
+		}
+	}
+
+	out, err = exec.Command("ps", "-A", "-ww", "-o", "args=").Output()
+	if err != nil {
+		return false, err
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		args := strings.Fields(line)
+		if len(args) == 0 || !isGit(filepath.Base(args[0])) {
+			continue
+		}
+		for _, arg := range args[1:] {
+			if within(arg, dirs) {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,43 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// gitRunning reports whether a git process works in one of dirs, by its
+// working directory or its arguments. A git process that can't be inspected
+// counts as running.
+func gitRunning(dirs []string) (bool, error) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return false, err
+	}
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil || pid == os.Getpid() {
+			continue
+		}
+		proc := filepath.Join("/proc", entry.Name())
+		comm, err := os.ReadFile(filepath.Join(proc, "comm"))
+		if err != nil || !isGit(strings.TrimSpace(string(comm))) {
+			continue
+		}
+		cwd, err := os.Readlink(filepath.Join(proc, "cwd"))
+		if err != nil {
+			return true, nil
+		}
+		paths := []string{cwd}
+		if cmdline, err := os.ReadFile(filepath.Join(proc, "cmdline")); err == nil {
+			paths = append(paths, strings.Split(string(cmdline), "\x00")...)
+		}
+		for _, path := range paths {
+			if within(path, dirs) {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,90 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/ship-digital/pull-watch/internal/errz"
+	"github.com/ship-digital/pull-watch/internal/logger"
+)
+
+// Timeouts of the operations that talk to the remote, when none are configured
+const (
+	DefaultLsRemoteTimeout = 30 * time.Second
+	DefaultFetchTimeout    = 2 * time.Minute
+	DefaultPullTimeout     = 5 * time.Minute
+)
+
+// defaultRetryBackoff is the wait before the first retry, it doubles with every attempt
+const defaultRetryBackoff = time.Second
+
+// IsTransient reports whether err is a network or server failure that may go away on its own
+func IsTransient(err error) bool {
+	return errors.Is(err, context.DeadlineExceeded) || errors.Is(err, errz.ErrNetwork)
+}
+
+// execRemote runs a git command that talks to the remote. Each attempt gets
+// timeout, transient failures are retried with jittered exponential backoff.
+func (r *GitRepository) execRemote(ctx context.Context, op string, timeout time.Duration, args ...string) (string, error) {
+	backoff := r.cfg.RetryBackoff
+	if backoff <= 0 {
+		backoff = defaultRetryBackoff
+	}
+
+	for attempt := 0; ; attempt++ {
+		output, err := r.execWithTimeout(ctx, op, timeout, args...)
+		if err == nil || attempt >= r.cfg.GitRetries || !IsTransient(err) || ctx.Err() != nil {
+			return output, err
+		}
+
+		// Jitter keeps a fleet of watchers from retrying in lockstep
+		base := backoff << attempt
+		wait := base/2 + time.Duration(rand.Int63n(int64(base/2)+1))
+		if r.cfg.Logger != nil {
+			r.cfg.Logger.MultiColor(logger.VerboseLevel,
+				logger.InfoSegment("git "+op+" failed, retrying in "),
+				logger.HighlightSegment(wait.Round(time.Millisecond).String()),
+				logger.InfoSegment(": "+gitMessage(err)),
+			)
+		}
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// execWithTimeout runs a git command, the timeout is reported as a deadline error
+func (r *GitRepository) execWithTimeout(ctx context.Context, op string, timeout time.Duration, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	output, err := r.executor.ExecuteCommand(ctx, "git", args...)
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		return "", fmt.Errorf("git %s timed out after %s: %w", op, timeout, context.DeadlineExceeded)
+	}
+	return output, err
+}
+
+// gitMessage returns what git said about err, the first line of its stderr if there is one
+func gitMessage(err error) string {
+	var cmdErr *errz.CommandError
+	if errors.As(err, &cmdErr) {
+		return cmdErr.Message()
+	}
+	line, _, _ := strings.Cut(err.Error(), "\n")
+	return line
+}
+
+// timeout returns the configured timeout, or def when none is
+func timeout(configured, def time.Duration) time.Duration {
+	if configured > 0 {
+		return configured
+	}
+	return def
+}
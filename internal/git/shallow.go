@@ -0,0 +1,98 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/ship-digital/pull-watch/internal/config"
+	"github.com/ship-digital/pull-watch/internal/events"
+	"github.com/ship-digital/pull-watch/internal/logger"
+)
+
+// defaultDeepen is how many commits a shallow clone is first deepened by, when
+// no fetch depth is set, to find how two commits are related
+const defaultDeepen = 50
+
+// detectByFetch reports whether new commits are found by fetching. A dry run
+// doesn't fetch, it asks the remote with ls-remote whatever the strategy.
+func (r *GitRepository) detectByFetch() bool {
+	return r.cfg.Detection.Strategy == config.DetectFetch && !r.cfg.DryRun
+}
+
+// fetchOptions are the depth and partial clone filter of the fetch strategy
+func (r *GitRepository) fetchOptions() []string {
+	var options []string
+	if r.cfg.Detection.Depth > 0 {
+		options = append(options, "--depth="+strconv.Itoa(r.cfg.Detection.Depth))
+	}
+	if r.cfg.Detection.Filter != "" {
+		options = append(options, "--filter="+r.cfg.Detection.Filter)
+	}
+	return options
+}
+
+// fetchRemoteCommit fetches only the upstream branch and returns where its
+// remote-tracking branch points to now
+func (r *GitRepository) fetchRemoteCommit(ctx context.Context, remote, branch string) (string, error) {
+	args := r.withoutSubmodules(append([]string{"-C", r.cfg.GitDir, "fetch"}, r.fetchOptions()...)...)
+	if _, err := r.execRemote(ctx, "fetch", timeout(r.cfg.GitTimeouts.Fetch, DefaultFetchTimeout), append(args, remote, branch)...); err != nil {
+		return "", err
+	}
+	output, err := r.execGitCmd(ctx, "rev-parse", "@{u}")
+	if err != nil {
+		return "", fmt.Errorf("failed to read the remote-tracking branch: %w", err)
+	}
+	return strings.TrimSpace(output), nil
+}
+
+// deepenUntilRelated deepens a shallow clone until the history of commitA and
+// commitB reaches a common ancestor, or there is nothing left to fetch. Without
+// it the shallow boundary makes related commits look unrelated.
+func (r *GitRepository) deepenUntilRelated(ctx context.Context, commitA, commitB string) error {
+	if !r.detectByFetch() {
+		return nil
+	}
+	step := r.cfg.Detection.Depth
+	if step <= 0 {
+		step = defaultDeepen
+	}
+
+	for {
+		_, err := r.execGitCmd(ctx, "merge-base", commitA, commitB)
+		if err == nil {
+			return nil
+		}
+		// Exit code 1 means no common ancestor was found
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) || exitErr.ExitCode() != 1 {
+			return fmt.Errorf("failed to find a common ancestor: %w", err)
+		}
+
+		shallow, err := r.execGitCmd(ctx, "rev-parse", "--is-shallow-repository")
+		if err != nil {
+			return err
+		}
+		if strings.TrimSpace(shallow) != "true" {
+			// The whole history is here, the commits really are unrelated
+			return nil
+		}
+
+		r.cfg.Logger.MultiColor(logger.VerboseLevel,
+			logger.InfoSegment("Deepening the shallow clone by "),
+			logger.HighlightSegment(strconv.Itoa(step)),
+			logger.InfoSegment(" commits to relate "),
+			logger.HighlightSegment(events.ShortCommit(commitA)),
+			logger.InfoSegment(" and "),
+			logger.HighlightSegment(events.ShortCommit(commitB)),
+		)
+		args := r.withoutSubmodules("-C", r.cfg.GitDir, "fetch", "--deepen="+strconv.Itoa(step))
+		if _, err := r.execRemote(ctx, "fetch", timeout(r.cfg.GitTimeouts.Fetch, DefaultFetchTimeout), args...); err != nil {
+			return fmt.Errorf("failed to deepen the shallow clone: %w", err)
+		}
+		step *= 2
+	}
+}
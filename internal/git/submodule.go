@@ -0,0 +1,305 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/ship-digital/pull-watch/internal/config"
+	"github.com/ship-digital/pull-watch/internal/events"
+	"github.com/ship-digital/pull-watch/internal/logger"
+)
+
+// submodulesEnabled reports whether submodules are updated along with the superproject
+func (r *GitRepository) submodulesEnabled() bool {
+	return r.cfg.Submodules.Mode != "" && r.cfg.Submodules.Mode != config.SubmodulesOff
+}
+
+// submoduleCmd builds a git submodule command run from the top of the working
+// tree, recursive when configured. paths limit it to some submodules.
+func (r *GitRepository) submoduleCmd(ctx context.Context, args []string, paths ...string) ([]string, error) {
+	top, err := r.execGitCmd(ctx, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, err
+	}
+	cmd := append([]string{"-C", top, "submodule"}, args...)
+	if r.cfg.Submodules.Mode == config.SubmodulesRecursive {
+		cmd = append(cmd, "--recursive")
+	}
+	if len(paths) > 0 {
+		cmd = append(append(cmd, "--"), paths...)
+	}
+	return cmd, nil
+}
+
+// updateSubmodules points the submodules at the URLs in .gitmodules and checks
+// out the commits the superproject pins, cloning the new ones, when enabled
+func (r *GitRepository) updateSubmodules(ctx context.Context) error {
+	if !r.submodulesEnabled() {
+		return nil
+	}
+	sync, err := r.submoduleCmd(ctx, []string{"sync", "--quiet"})
+	if err != nil {
+		return err
+	}
+	if _, err := r.execGitCmd(ctx, sync...); err != nil {
+		return fmt.Errorf("failed to sync submodules: %w", err)
+	}
+	if err := r.checkoutSubmodules(ctx); err != nil {
+		return fmt.Errorf("failed to update submodules: %w", err)
+	}
+	return nil
+}
+
+// checkoutSubmodules checks out the commits the superproject pins in its
+// submodules. With Submodules.Remote the ones tracking a branch are moved to its
+// remote head instead, where UpdateTrackedSubmodules keeps them.
+func (r *GitRepository) checkoutSubmodules(ctx context.Context) error {
+	updateTimeout := timeout(r.cfg.GitTimeouts.Pull, DefaultPullTimeout)
+	var pinned []string
+	if r.cfg.Submodules.Remote {
+		top, err := r.execGitCmd(ctx, "rev-parse", "--show-toplevel")
+		if err != nil {
+			return err
+		}
+		tracked, err := r.trackedSubmodules(ctx, top)
+		if err != nil {
+			return err
+		}
+		if len(tracked) > 0 {
+			update, err := r.submoduleCmd(ctx, []string{"update", "--init", "--remote"}, submodulePaths(tracked)...)
+			if err != nil {
+				return err
+			}
+			if _, err := r.execRemote(ctx, "submodule update", updateTimeout, update...); err != nil {
+				return err
+			}
+			all, err := r.execGitCmd(ctx, "config", "--file", filepath.Join(top, ".gitmodules"), "--get-regexp", `^submodule\..*\.path$`)
+			if err != nil {
+				return fmt.Errorf("failed to list submodules: %w", err)
+			}
+			for _, line := range strings.Split(strings.TrimSpace(all), "\n") {
+				_, path, _ := strings.Cut(line, " ")
+				if !slices.Contains(submodulePaths(tracked), path) {
+					pinned = append(pinned, path)
+				}
+			}
+			if len(pinned) == 0 {
+				return nil
+			}
+		}
+	}
+
+	update, err := r.submoduleCmd(ctx, []string{"update", "--init"}, pinned...)
+	if err != nil {
+		return err
+	}
+	_, err = r.execRemote(ctx, "submodule update", updateTimeout, update...)
+	return err
+}
+
+// syncSubmodulesAfterPull updates the submodules, and the LFS files, to what the
+// pulled commit pins. When that fails the superproject and its submodules are
+// moved back to previous, so the command never runs with code from two different commits.
+func (r *GitRepository) syncSubmodulesAfterPull(ctx context.Context, previous string) error {
+	if !r.submodulesEnabled() && !r.cfg.LFS {
+		return nil
+	}
+	err := r.updateSubmodules(ctx)
+	if err == nil {
+		if r.submodulesEnabled() {
+			r.cfg.Logger.MultiColor(logger.VerboseLevel, logger.InfoSegment("Submodules updated"))
+		}
+		err = r.checkoutLFS(ctx, "")
+	}
+	if err == nil {
+		return nil
+	}
+
+	r.cfg.Logger.MultiColor(logger.QuietLevel,
+		logger.ErrorSegment("Submodule or LFS update failed, rolling back to "),
+		logger.HighlightSegment(events.ShortCommit(previous)),
+		logger.ErrorSegment(": "),
+		logger.HighlightSegment(gitMessage(err)),
+	)
+	rollbackErr := r.rollback(ctx, previous)
+	events.RecordWithSubject(ctx, r.cfg.Events, r.cfg.Logger, events.Event{Type: events.Rollback, Commit: previous}.WithError(rollbackErr), r.GetCommitSubject)
+	if rollbackErr != nil {
+		return fmt.Errorf("%w, then failed to roll back to %s: %v", err, events.ShortCommit(previous), rollbackErr)
+	}
+	return fmt.Errorf("rolled back to %s: %w", events.ShortCommit(previous), err)
+}
+
+// rollback moves the superproject back to commit, keeping local changes, and
+// its submodules and LFS files to what it pins there
+func (r *GitRepository) rollback(ctx context.Context, commit string) error {
+	if _, err := r.execGitCmd(ctx, "reset", "--keep", commit); err != nil {
+		return err
+	}
+	if r.submodulesEnabled() {
+		if err := r.checkoutSubmodules(ctx); err != nil {
+			return err
+		}
+	}
+	return r.checkoutLFS(ctx, "")
+}
+
+// UpdateTrackedSubmodules moves the submodules that track a branch, set with
+// submodule.<name>.branch in .gitmodules, to the branch's remote head. It
+// reports whether any of them moved. The remote heads are looked up first, only
+// the submodules behind theirs are updated. Submodules that were moved are put
+// back where they were when another one fails.
+func (r *GitRepository) UpdateTrackedSubmodules(ctx context.Context) (bool, error) {
+	if !r.submodulesEnabled() || !r.cfg.Submodules.Remote {
+		return false, nil
+	}
+	top, err := r.execGitCmd(ctx, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return false, err
+	}
+	tracked, err := r.trackedSubmodules(ctx, top)
+	if err != nil || len(tracked) == 0 {
+		return false, err
+	}
+
+	before, err := r.submoduleHeads(ctx, top, submodulePaths(tracked))
+	if err != nil {
+		return false, err
+	}
+	paths, err := r.behindSubmodules(ctx, top, tracked, before)
+	if err != nil || len(paths) == 0 {
+		return false, err
+	}
+	update, err := r.submoduleCmd(ctx, []string{"update", "--init", "--remote"}, paths...)
+	if err != nil {
+		return false, err
+	}
+	_, updateErr := r.execRemote(ctx, "submodule update", timeout(r.cfg.GitTimeouts.Pull, DefaultPullTimeout), update...)
+	after, err := r.submoduleHeads(ctx, top, paths)
+	if err != nil {
+		return false, err
+	}
+
+	var moved []string
+	for _, path := range paths {
+		if before[path] != after[path] {
+			moved = append(moved, path)
+		}
+	}
+	if updateErr != nil {
+		for _, path := range moved {
+			if before[path] == "" {
+				continue
+			}
+			if _, err := r.execGitCmd(ctx, "-C", filepath.Join(top, path), "checkout", "--quiet", "--detach", before[path]); err != nil {
+				return false, fmt.Errorf("failed to update tracked submodules: %w, then failed to put %s back: %v", updateErr, path, err)
+			}
+		}
+		return false, fmt.Errorf("failed to update tracked submodules: %w", updateErr)
+	}
+
+	for _, path := range moved {
+		r.cfg.Logger.MultiColor(logger.DefaultLevel,
+			logger.InfoSegment("Submodule "),
+			logger.HighlightSegment(path),
+			logger.InfoSegment(" moved to "),
+			logger.HighlightSegment(events.ShortCommit(after[path])),
+			logger.InfoSegment(" on its tracked branch"),
+		)
+	}
+	return len(moved) > 0, nil
+}
+
+// trackedSubmodule is a submodule following a branch instead of a pinned commit
+type trackedSubmodule struct {
+	path   string
+	branch string
+}
+
+// trackedSubmodules returns the submodules with a branch set in .gitmodules, by path from top
+func (r *GitRepository) trackedSubmodules(ctx context.Context, top string) ([]trackedSubmodule, error) {
+	gitmodules := filepath.Join(top, ".gitmodules")
+	output, err := r.execGitCmd(ctx, "config", "--file", gitmodules, "--get-regexp", `^submodule\..*\.branch$`)
+	if err != nil {
+		// git config exits with 1 when nothing matches, or there's no .gitmodules
+		return nil, nil
+	}
+
+	var tracked []trackedSubmodule
+	for _, line := range strings.Split(output, "\n") {
+		key, branch, _ := strings.Cut(strings.TrimSpace(line), " ")
+		name := strings.TrimSuffix(strings.TrimPrefix(key, "submodule."), ".branch")
+		if name == "" {
+			continue
+		}
+		path, err := r.execGitCmd(ctx, "config", "--file", gitmodules, "--get", "submodule."+name+".path")
+		if err != nil {
+			return nil, fmt.Errorf("submodule %s has a branch but no path in .gitmodules: %w", name, err)
+		}
+		tracked = append(tracked, trackedSubmodule{path: strings.TrimSpace(path), branch: branch})
+	}
+	return tracked, nil
+}
+
+// behindSubmodules returns the paths of the tracked submodules whose branch has a
+// remote head other than the commit checked out, per heads, or that aren't cloned yet
+func (r *GitRepository) behindSubmodules(ctx context.Context, top string, tracked []trackedSubmodule, heads map[string]string) ([]string, error) {
+	var behind []string
+	for _, sub := range tracked {
+		if heads[sub.path] == "" {
+			behind = append(behind, sub.path)
+			continue
+		}
+		branch := sub.branch
+		if branch == "." {
+			// Follows the superproject's branch
+			current, err := r.GetCurrentBranch(ctx)
+			if err != nil {
+				return nil, err
+			}
+			branch = current
+		}
+		output, err := r.execRemote(ctx, "ls-remote", timeout(r.cfg.GitTimeouts.LsRemote, DefaultLsRemoteTimeout),
+			"-C", filepath.Join(top, sub.path), "ls-remote", "origin", "refs/heads/"+branch)
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up the remote head of submodule %s: %w", sub.path, err)
+		}
+		if remote, _, _ := strings.Cut(strings.TrimSpace(output), "\t"); remote != heads[sub.path] {
+			behind = append(behind, sub.path)
+		}
+	}
+	return behind, nil
+}
+
+// submodulePaths returns the paths of the tracked submodules
+func submodulePaths(tracked []trackedSubmodule) []string {
+	paths := make([]string, 0, len(tracked))
+	for _, sub := range tracked {
+		paths = append(paths, sub.path)
+	}
+	return paths
+}
+
+// submoduleHeads returns the commit checked out in each submodule, by path from top.
+// Submodules not cloned yet are left out.
+func (r *GitRepository) submoduleHeads(ctx context.Context, top string, paths []string) (map[string]string, error) {
+	output, err := r.execGitCmd(ctx, append([]string{"-C", top, "submodule", "status", "--"}, paths...)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get submodule status: %w", err)
+	}
+
+	heads := map[string]string{}
+	for _, line := range strings.Split(output, "\n") {
+		// " <commit> <path> (<describe>)", prefixed with - when not initialized
+		fields := strings.Fields(strings.TrimLeft(line, " +-U"))
+		if len(fields) < 2 {
+			continue
+		}
+		if !strings.HasPrefix(line, "-") {
+			heads[fields[1]] = fields[0]
+		}
+	}
+	return heads, nil
+}
@@ -0,0 +1,51 @@
+package git
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ship-digital/pull-watch/internal/config"
+)
+
+// AddWorktree checks commit out, detached, in a new worktree at dir, with the
+// same sparse checkout, submodules and LFS files as the repository's own
+func (r *GitRepository) AddWorktree(ctx context.Context, dir, commit string) error {
+	// Checking out a large tree takes longer than the usual local command
+	checkoutTimeout := timeout(r.cfg.GitTimeouts.Pull, DefaultPullTimeout)
+	if len(r.cfg.Sparse) == 0 {
+		if _, err := r.execWithTimeout(ctx, "worktree add", checkoutTimeout, "worktree", "add", "--detach", dir, commit); err != nil {
+			return fmt.Errorf("failed to add worktree: %w", err)
+		}
+	} else {
+		// The files only come out once the sparse checkout is set
+		if _, err := r.execGitCmd(ctx, "worktree", "add", "--detach", "--no-checkout", dir, commit); err != nil {
+			return fmt.Errorf("failed to add worktree: %w", err)
+		}
+		args := append([]string{"-C", dir, "sparse-checkout", "set", "--cone"}, r.cfg.Sparse...)
+		if _, err := r.execGitCmd(ctx, args...); err != nil {
+			return fmt.Errorf("failed to set up the sparse checkout of the worktree: %w", err)
+		}
+		if _, err := r.execWithTimeout(ctx, "reset", checkoutTimeout, "-C", dir, "reset", "--quiet", "--hard"); err != nil {
+			return fmt.Errorf("failed to check out the worktree: %w", err)
+		}
+	}
+
+	if r.submodulesEnabled() {
+		args := []string{"-C", dir, "submodule", "update", "--init"}
+		if r.cfg.Submodules.Mode == config.SubmodulesRecursive {
+			args = append(args, "--recursive")
+		}
+		if _, err := r.execRemote(ctx, "submodule update", checkoutTimeout, args...); err != nil {
+			return fmt.Errorf("failed to update the submodules of the worktree: %w", err)
+		}
+	}
+	return r.checkoutLFS(ctx, dir)
+}
+
+// RemoveWorktree removes the worktree at dir along with its files, changes included
+func (r *GitRepository) RemoveWorktree(ctx context.Context, dir string) error {
+	if _, err := r.execGitCmd(ctx, "worktree", "remove", "--force", dir); err != nil {
+		return fmt.Errorf("failed to remove worktree %s: %w", dir, err)
+	}
+	return nil
+}
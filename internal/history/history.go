@@ -0,0 +1,135 @@
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ship-digital/pull-watch/internal/events"
+	"github.com/ship-digital/pull-watch/internal/logfile"
+)
+
+const (
+	// DefaultMaxSize is the journal size in bytes that triggers a rotation
+	DefaultMaxSize = logfile.DefaultMaxSize
+	// DefaultMaxFiles is the number of rotated journals kept next to the active one
+	DefaultMaxFiles = logfile.DefaultMaxFiles
+)
+
+// DefaultPath returns the journal location for the repository whose .git directory is gitDir
+func DefaultPath(gitDir string) string {
+	return filepath.Join(gitDir, "pull-watch", "history.jsonl")
+}
+
+var _ events.Sink = &Journal{}
+
+// Journal is an append-only JSON lines event log with size based rotation.
+// Rotated files are named <path>.1 (newest) to <path>.<maxFiles> (oldest).
+type Journal struct {
+	log *logfile.Writer
+}
+
+// Open opens or creates the journal at path
+func Open(path string, maxSize int64, maxFiles int) (*Journal, error) {
+	log, err := logfile.Open(path, logfile.Options{MaxSize: maxSize, MaxFiles: maxFiles})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history: %w", err)
+	}
+	return &Journal{log: log}, nil
+}
+
+// Path returns the location of the active journal file
+func (j *Journal) Path() string {
+	return j.log.Path()
+}
+
+// Record appends e to the journal, rotating it first if it grew too large
+func (j *Journal) Record(e events.Event) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+	line = append(line, '\n')
+
+	// A single write, so concurrent events never interleave or straddle a rotation
+	if _, err := j.log.Write(line); err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	return nil
+}
+
+// Close closes the journal
+func (j *Journal) Close() error {
+	return j.log.Close()
+}
+
+// Filter selects events when reading the journal, zero values match everything
+type Filter struct {
+	Since time.Time
+	Until time.Time
+	Types []events.Type
+}
+
+func (f Filter) matches(e events.Event) bool {
+	if !f.Since.IsZero() && e.Time.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && e.Time.After(f.Until) {
+		return false
+	}
+	if len(f.Types) == 0 {
+		return true
+	}
+	for _, t := range f.Types {
+		if e.Type == t {
+			return true
+		}
+	}
+	return false
+}
+
+// Read returns the events matching filter from the journal at path and its rotated files, oldest first.
+// Lines that can't be decoded, like one cut short by a crash, are skipped.
+func Read(path string, filter Filter) ([]events.Event, error) {
+	var paths []string
+	for i := 1; ; i++ {
+		p := logfile.RotatedPath(path, i)
+		if _, err := os.Stat(p); err != nil {
+			break
+		}
+		paths = append([]string{p}, paths...)
+	}
+	paths = append(paths, path)
+
+	var result []events.Event
+	for _, p := range paths {
+		f, err := os.Open(p)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to open history file: %w", err)
+		}
+
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			var e events.Event
+			if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+				continue
+			}
+			if filter.matches(e) {
+				result = append(result, e)
+			}
+		}
+		err = scanner.Err()
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read history file %s: %w", p, err)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,112 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ship-digital/pull-watch/internal/events"
+	"github.com/ship-digital/pull-watch/internal/logfile"
+)
+
+func TestJournalRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pull-watch", "history.jsonl")
+
+	// Small enough that every few events trigger a rotation
+	j, err := Open(path, 300, 2)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer j.Close()
+
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 20; i++ {
+		e := events.Event{
+			Time:   base.Add(time.Duration(i) * time.Minute),
+			Type:   events.Restart,
+			Commit: "abcdef0123456789",
+		}
+		if err := j.Record(e); err != nil {
+			t.Fatalf("Record() error = %v", err)
+		}
+	}
+
+	if _, err := os.Stat(logfile.RotatedPath(path, 2)); err != nil {
+		t.Errorf("expected rotated file %s: %v", logfile.RotatedPath(path, 2), err)
+	}
+	if _, err := os.Stat(logfile.RotatedPath(path, 3)); !os.IsNotExist(err) {
+		t.Errorf("expected only 2 rotated files to be kept, got %s", logfile.RotatedPath(path, 3))
+	}
+
+	got, err := Read(path, Filter{})
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if len(got) == 0 || len(got) >= 20 {
+		t.Fatalf("Read() returned %d events, want some but not all of 20 after rotation", len(got))
+	}
+	for i := 1; i < len(got); i++ {
+		if !got[i].Time.After(got[i-1].Time) {
+			t.Fatalf("Read() events not in chronological order at %d: %v then %v", i, got[i-1].Time, got[i].Time)
+		}
+	}
+	if last := got[len(got)-1]; !last.Time.Equal(base.Add(19 * time.Minute)) {
+		t.Errorf("last event time = %v, want the most recent one", last.Time)
+	}
+}
+
+func TestRead_Filter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	j, err := Open(path, 0, 0)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+
+	base := time.Date(2024, 1, 1, 3, 0, 0, 0, time.UTC)
+	recorded := []events.Event{
+		{Time: base, Type: events.Change, Commit: "aaa"},
+		{Time: base.Add(time.Second), Type: events.Pull, Commit: "aaa", Outcome: events.OutcomeSuccess},
+		{Time: base.Add(2 * time.Second), Type: events.Restart, Commit: "aaa", Outcome: events.OutcomeSuccess},
+		{Time: base.Add(time.Hour), Type: events.Crash, Commit: "aaa"},
+		{Time: base.Add(2 * time.Hour), Type: events.Error, Error: "network error"},
+	}
+	for _, e := range recorded {
+		if err := j.Record(e); err != nil {
+			t.Fatalf("Record() error = %v", err)
+		}
+	}
+	j.Close()
+
+	// A line cut short by a crash must not break reading
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"time":"2024-01-01T06:00:00Z","ty`)
+	f.Close()
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   int
+	}{
+		{name: "everything", filter: Filter{}, want: 5},
+		{name: "by type", filter: Filter{Types: []events.Type{events.Pull, events.Crash}}, want: 2},
+		{name: "since", filter: Filter{Since: base.Add(30 * time.Minute)}, want: 2},
+		{name: "until", filter: Filter{Until: base.Add(30 * time.Minute)}, want: 3},
+		{name: "window and type", filter: Filter{Since: base.Add(time.Second), Until: base.Add(90 * time.Minute), Types: []events.Type{events.Restart, events.Crash}}, want: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Read(path, tt.filter)
+			if err != nil {
+				t.Fatalf("Read() error = %v", err)
+			}
+			if len(got) != tt.want {
+				t.Errorf("Read() returned %d events, want %d", len(got), tt.want)
+			}
+		})
+	}
+}
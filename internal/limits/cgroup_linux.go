@@ -0,0 +1,221 @@
+package limits
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// cpuPeriod is the cpu.max period in microseconds
+const cpuPeriod = 100000
+
+// Cgroup is a cgroup v2 the command is started in
+type Cgroup struct {
+	path string
+	dir  *os.File
+	// base is the cgroup pull-watch started in, supervisor the leaf it moved to
+	// and enabled the controllers it turned on for base's children, all undone by Close
+	base       string
+	supervisor string
+	enabled    []string
+}
+
+// NewCgroup creates the cgroup the command is started in, below the one of pull-watch.
+// cgroup v2 only allows processes in leaf cgroups once controllers are enabled,
+// so pull-watch moves itself to a "supervisor" leaf next to the "command" one.
+// This needs root, or a delegated cgroup (systemd's Delegate=yes).
+func NewCgroup() (*Cgroup, error) {
+	mount, err := cgroup2Mount()
+	if err != nil {
+		return nil, err
+	}
+	own, err := ownCgroup()
+	if err != nil {
+		return nil, err
+	}
+	c := &Cgroup{base: filepath.Join(mount, own)}
+
+	if own != "/" {
+		supervisor := filepath.Join(c.base, "supervisor")
+		if err := os.Mkdir(supervisor, 0o755); err != nil && !os.IsExist(err) {
+			return nil, cgroupError(err)
+		}
+		if err := writeFile(filepath.Join(supervisor, "cgroup.procs"), strconv.Itoa(os.Getpid())); err != nil {
+			os.Remove(supervisor)
+			return nil, delegationError(own, err)
+		}
+		c.supervisor = supervisor
+	}
+
+	current, err := os.ReadFile(filepath.Join(c.base, "cgroup.subtree_control"))
+	if err != nil {
+		return nil, c.undo(cgroupError(err))
+	}
+	for _, controller := range []string{"memory", "cpu"} {
+		if !slices.Contains(strings.Fields(string(current)), controller) {
+			c.enabled = append(c.enabled, controller)
+		}
+	}
+	if err := c.control("+"); err != nil {
+		return nil, c.undo(delegationError(own, err))
+	}
+
+	c.path = filepath.Join(c.base, fmt.Sprintf("command-%d", os.Getpid()))
+	if err := os.Mkdir(c.path, 0o755); err != nil && !os.IsExist(err) {
+		return nil, c.undo(cgroupError(err))
+	}
+	if c.dir, err = os.Open(c.path); err != nil {
+		return nil, c.undo(cgroupError(err))
+	}
+	return c, nil
+}
+
+// control turns the controllers NewCgroup enabled on ("+") or off ("-") for the children of base
+func (c *Cgroup) control(op string) error {
+	if len(c.enabled) == 0 {
+		return nil
+	}
+	value := op + strings.Join(c.enabled, " "+op)
+	return writeFile(filepath.Join(c.base, "cgroup.subtree_control"), value)
+}
+
+// undo moves pull-watch back to the cgroup it started in and removes the supervisor
+// leaf, after disabling the controllers that kept processes out of base. It returns
+// err, joined with whatever failed along the way.
+func (c *Cgroup) undo(err error) error {
+	errs := []error{err}
+	if c.path != "" {
+		if c.dir != nil {
+			c.dir.Close()
+		}
+		if err := os.Remove(c.path); err != nil && !os.IsNotExist(err) {
+			errs = append(errs, err)
+		}
+	}
+	if err := c.control("-"); err != nil {
+		errs = append(errs, fmt.Errorf("failed to disable cgroup controllers: %w", err))
+	}
+	if c.supervisor != "" {
+		if err := writeFile(filepath.Join(c.base, "cgroup.procs"), strconv.Itoa(os.Getpid())); err != nil {
+			errs = append(errs, fmt.Errorf("failed to move pull-watch back to its cgroup: %w", err))
+		} else if err := os.Remove(c.supervisor); err != nil && !os.IsNotExist(err) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Set writes the memory and CPU limits of the cgroup
+func (c *Cgroup) Set(l Limits) error {
+	memory, cpu := "max", "max"
+	if l.CgroupMemoryMax > 0 {
+		memory = strconv.FormatInt(l.CgroupMemoryMax, 10)
+	}
+	if l.CgroupCPUMax > 0 {
+		cpu = fmt.Sprintf("%d %d", int64(l.CgroupCPUMax*cpuPeriod), cpuPeriod)
+	}
+	if err := writeFile(filepath.Join(c.path, "memory.max"), memory); err != nil {
+		return cgroupError(err)
+	}
+	if err := writeFile(filepath.Join(c.path, "cpu.max"), cpu); err != nil {
+		return cgroupError(err)
+	}
+	return nil
+}
+
+// Attach makes cmd start inside the cgroup
+func (c *Cgroup) Attach(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.UseCgroupFD = true
+	cmd.SysProcAttr.CgroupFD = int(c.dir.Fd())
+}
+
+// OOMKills returns how many processes of the cgroup were killed for running out of memory
+func (c *Cgroup) OOMKills() int {
+	f, err := os.Open(filepath.Join(c.path, "memory.events"))
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if count, ok := strings.CutPrefix(scanner.Text(), "oom_kill "); ok {
+			n, _ := strconv.Atoi(count)
+			return n
+		}
+	}
+	return 0
+}
+
+// Close removes the cgroup, which only works once the command has exited,
+// and moves pull-watch back to the cgroup it started in
+func (c *Cgroup) Close() error {
+	return c.undo(nil)
+}
+
+// cgroup2Mount returns where the cgroup v2 hierarchy is mounted
+func cgroup2Mount() (string, error) {
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// Fields after " - " are the filesystem type and source
+		before, after, ok := strings.Cut(scanner.Text(), " - ")
+		if !ok || !strings.HasPrefix(after, "cgroup2 ") {
+			continue
+		}
+		if fields := strings.Fields(before); len(fields) >= 5 {
+			return fields[4], nil
+		}
+	}
+	return "", errors.New("cgroup v2 isn't mounted")
+}
+
+// ownCgroup returns the cgroup v2 path of pull-watch
+func ownCgroup() (string, error) {
+	data, err := os.ReadFile("/proc/self/cgroup")
+	if err != nil {
+		return "", err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if path, ok := strings.CutPrefix(line, "0::"); ok {
+			return path, nil
+		}
+	}
+	return "", errors.New("pull-watch isn't in a cgroup v2")
+}
+
+func writeFile(path, value string) error {
+	return os.WriteFile(path, []byte(value), 0o644)
+}
+
+// delegationError explains a failure to move pull-watch or to enable controllers
+// below its cgroup: the kernel refuses with EBUSY while other processes share the
+// cgroup, and with EACCES when it wasn't delegated to pull-watch's user
+func delegationError(own string, err error) error {
+	if errors.Is(err, syscall.EBUSY) || errors.Is(err, syscall.EACCES) {
+		return fmt.Errorf("failed to set up cgroup, %s isn't delegated to pull-watch: run it as a service with Delegate=yes, or under systemd-run --user -p Delegate=yes: %w", own, err)
+	}
+	return cgroupError(err)
+}
+
+func cgroupError(err error) error {
+	if errors.Is(err, os.ErrPermission) || errors.Is(err, syscall.EROFS) {
+		return fmt.Errorf("failed to set up cgroup, run as root or with a delegated cgroup (systemd Delegate=yes): %w", err)
+	}
+	return fmt.Errorf("failed to set up cgroup: %w", err)
+}
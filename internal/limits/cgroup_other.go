@@ -0,0 +1,28 @@
+//go:build !linux
+
+package limits
+
+import (
+	"errors"
+	"os/exec"
+)
+
+// Cgroup is a cgroup v2 the command is started in
+type Cgroup struct{}
+
+// NewCgroup creates the cgroup the command is started in
+func NewCgroup() (*Cgroup, error) {
+	return nil, errors.New("cgroups are only supported on Linux")
+}
+
+// Set writes the memory and CPU limits of the cgroup
+func (c *Cgroup) Set(l Limits) error { return nil }
+
+// Attach makes cmd start inside the cgroup
+func (c *Cgroup) Attach(cmd *exec.Cmd) {}
+
+// OOMKills returns how many processes of the cgroup were killed for running out of memory
+func (c *Cgroup) OOMKills() int { return 0 }
+
+// Close removes the cgroup
+func (c *Cgroup) Close() error { return nil }
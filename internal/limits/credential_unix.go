@@ -0,0 +1,100 @@
+//go:build !windows
+
+package limits
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// Prepare makes cmd run as l.RunAs with the limits of l from its first instruction,
+// it must be called before cmd is started
+func Prepare(cmd *exec.Cmd, l Limits) error {
+	if l.RunAs != "" {
+		if err := setCredential(cmd, l.RunAs); err != nil {
+			return err
+		}
+	}
+	if l.perProcess() {
+		return wrap(cmd, l)
+	}
+	return nil
+}
+
+// setCredential makes cmd run as runAs, a user with an optional :group
+func setCredential(cmd *exec.Cmd, runAs string) error {
+	name, groupName, hasGroup := strings.Cut(runAs, ":")
+	u, err := lookupUser(name)
+	if err != nil {
+		return err
+	}
+	uid, err := strconv.ParseUint(u.Uid, 10, 32)
+	if err != nil {
+		return fmt.Errorf("user %s has no numeric uid", name)
+	}
+	gidString := u.Gid
+	if hasGroup {
+		g, err := lookupGroup(groupName)
+		if err != nil {
+			return err
+		}
+		gidString = g.Gid
+	}
+	gid, err := strconv.ParseUint(gidString, 10, 32)
+	if err != nil {
+		return fmt.Errorf("group of %s has no numeric gid", runAs)
+	}
+
+	// Supplementary groups of the user, the ones of pull-watch would leak otherwise
+	var groups []uint32
+	if ids, err := u.GroupIds(); err == nil {
+		for _, id := range ids {
+			if n, err := strconv.ParseUint(id, 10, 32); err == nil {
+				groups = append(groups, uint32(n))
+			}
+		}
+	}
+
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Credential = &syscall.Credential{Uid: uint32(uid), Gid: uint32(gid), Groups: groups}
+
+	env := cmd.Env
+	if env == nil {
+		env = os.Environ()
+	}
+	cmd.Env = append(env, "HOME="+u.HomeDir, "USER="+u.Username, "LOGNAME="+u.Username)
+	return nil
+}
+
+func lookupUser(name string) (*user.User, error) {
+	u, err := user.Lookup(name)
+	if err != nil {
+		if _, numErr := strconv.Atoi(name); numErr == nil {
+			u, err = user.LookupId(name)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unknown user %q: %w", name, err)
+	}
+	return u, nil
+}
+
+func lookupGroup(name string) (*user.Group, error) {
+	g, err := user.LookupGroup(name)
+	if err != nil {
+		if _, numErr := strconv.Atoi(name); numErr == nil {
+			g, err = user.LookupGroupId(name)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unknown group %q: %w", name, err)
+	}
+	return g, nil
+}
@@ -0,0 +1,13 @@
+//go:build windows
+
+package limits
+
+import (
+	"os/exec"
+)
+
+// Prepare makes cmd run as l.RunAs, it must be called before cmd is started
+func Prepare(cmd *exec.Cmd, l Limits) error {
+	// supported rejects RunAs on Windows
+	return nil
+}
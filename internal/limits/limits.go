@@ -0,0 +1,124 @@
+package limits
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// I/O scheduling classes accepted by IOClass
+const (
+	IOClassRealtime   = "realtime"
+	IOClassBestEffort = "best-effort"
+	IOClassIdle       = "idle"
+)
+
+// Limits restricts the resources of the command. Zero values mean no limit.
+type Limits struct {
+	// Memory caps the address space (RLIMIT_AS) in bytes
+	Memory int64
+	// OpenFiles caps the number of open file descriptors (RLIMIT_NOFILE)
+	OpenFiles uint64
+	// CPUTime caps the CPU time (RLIMIT_CPU), the command gets SIGXCPU and then SIGKILL
+	CPUTime time.Duration
+	// RunAs is the user[:group] the command runs as
+	RunAs string
+	// Nice is the scheduling priority, from -20 (highest) to 19 (lowest)
+	Nice int
+	// IOClass and IOLevel set the I/O scheduling class and priority (0-7, lower is higher)
+	IOClass string
+	IOLevel int
+	// CgroupMemoryMax and CgroupCPUMax start the command in its own cgroup with
+	// memory.max in bytes and cpu.max in CPUs
+	CgroupMemoryMax int64
+	CgroupCPUMax    float64
+}
+
+// IsZero reports whether no limit is set
+func (l Limits) IsZero() bool {
+	return l == Limits{}
+}
+
+// NeedsCgroup reports whether the command needs a cgroup of its own
+func (l Limits) NeedsCgroup() bool {
+	return l.CgroupMemoryMax > 0 || l.CgroupCPUMax > 0
+}
+
+// perProcess reports whether a limit is set that the command sets on itself before exec
+func (l Limits) perProcess() bool {
+	return l.Memory > 0 || l.OpenFiles > 0 || l.CPUTime > 0 || l.Nice != 0 || l.IOClass != ""
+}
+
+// Validate checks the limits can be applied on this platform
+func (l Limits) Validate() error {
+	if l.Nice < -20 || l.Nice > 19 {
+		return fmt.Errorf("nice value %d out of range (-20 to 19)", l.Nice)
+	}
+	switch l.IOClass {
+	case "", IOClassRealtime, IOClassBestEffort, IOClassIdle:
+	default:
+		return fmt.Errorf("unknown I/O class %q (expected %q, %q or %q)", l.IOClass, IOClassRealtime, IOClassBestEffort, IOClassIdle)
+	}
+	if l.IOLevel < 0 || l.IOLevel > 7 {
+		return fmt.Errorf("I/O priority %d out of range (0 to 7)", l.IOLevel)
+	}
+	if l.CgroupCPUMax < 0 {
+		return fmt.Errorf("invalid CPU limit %g", l.CgroupCPUMax)
+	}
+	return supported(l)
+}
+
+// ParseSize parses a byte size like "512M", "2G" or "1048576"
+func ParseSize(s string) (int64, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	s = strings.TrimSuffix(strings.TrimSuffix(s, "B"), "I")
+	multiplier := int64(1)
+	if s != "" {
+		switch s[len(s)-1] {
+		case 'K':
+			multiplier = 1 << 10
+		case 'M':
+			multiplier = 1 << 20
+		case 'G':
+			multiplier = 1 << 30
+		case 'T':
+			multiplier = 1 << 40
+		}
+		if multiplier > 1 {
+			s = s[:len(s)-1]
+		}
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q (e.g. 512M or 2G)", s)
+	}
+	return int64(n * float64(multiplier)), nil
+}
+
+// ParseIONice parses an I/O scheduling class with an optional priority, like "best-effort:7"
+func ParseIONice(s string) (class string, level int, err error) {
+	class, rawLevel, hasLevel := strings.Cut(strings.ToLower(strings.TrimSpace(s)), ":")
+	if hasLevel {
+		if level, err = strconv.Atoi(rawLevel); err != nil {
+			return "", 0, fmt.Errorf("invalid I/O priority %q", rawLevel)
+		}
+	} else if class == IOClassBestEffort || class == IOClassRealtime {
+		// The kernel's default for processes without an explicit priority
+		level = 4
+	}
+	return class, level, nil
+}
+
+// FormatSize renders a byte size the way ParseSize reads it
+func FormatSize(n int64) string {
+	for _, unit := range []struct {
+		suffix string
+		size   int64
+	}{{"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10}} {
+		if n >= unit.size && n%unit.size == 0 {
+			return fmt.Sprintf("%d%s", n/unit.size, unit.suffix)
+		}
+	}
+	return strconv.FormatInt(n, 10)
+}
@@ -0,0 +1,67 @@
+package limits
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// ioprio_set(2) constants, not exported by x/sys
+const (
+	ioprioWhoProcess = 1
+	ioprioClassShift = 13
+)
+
+var ioClasses = map[string]int{
+	IOClassRealtime:   1,
+	IOClassBestEffort: 2,
+	IOClassIdle:       3,
+}
+
+func supported(l Limits) error {
+	return nil
+}
+
+// apply sets the limits of the process pid, the shim calls it on itself
+// before it becomes the command
+func apply(pid int, l Limits) error {
+	rlimits := []struct {
+		name     string
+		resource int
+		value    uint64
+	}{
+		{"memory", unix.RLIMIT_AS, uint64(l.Memory)},
+		{"open files", unix.RLIMIT_NOFILE, l.OpenFiles},
+		// RLIMIT_CPU counts whole seconds, round up so a short limit isn't dropped
+		{"CPU time", unix.RLIMIT_CPU, uint64(math.Ceil(l.CPUTime.Seconds()))},
+	}
+	for _, r := range rlimits {
+		if r.value == 0 {
+			continue
+		}
+		limit := unix.Rlimit{Cur: r.value, Max: r.value}
+		if err := unix.Prlimit(pid, r.resource, &limit, nil); err != nil {
+			if errors.Is(err, syscall.EPERM) {
+				return fmt.Errorf("failed to limit %s, raising it above pull-watch's own limit takes CAP_SYS_RESOURCE: %w", r.name, err)
+			}
+			return fmt.Errorf("failed to limit %s: %w", r.name, err)
+		}
+	}
+
+	if l.Nice != 0 {
+		if err := syscall.Setpriority(syscall.PRIO_PROCESS, pid, l.Nice); err != nil {
+			return fmt.Errorf("failed to set nice value: %w", err)
+		}
+	}
+
+	if l.IOClass != "" {
+		prio := ioClasses[l.IOClass]<<ioprioClassShift | l.IOLevel
+		if _, _, errno := unix.Syscall(unix.SYS_IOPRIO_SET, ioprioWhoProcess, uintptr(pid), uintptr(prio)); errno != 0 {
+			return fmt.Errorf("failed to set I/O priority: %w", errno)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package limits
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func startSleep(t *testing.T, configure func(cmd *exec.Cmd)) *exec.Cmd {
+	t.Helper()
+	cmd := exec.Command("sleep", "10")
+	if configure != nil {
+		configure(cmd)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("failed to start sleep: %v", err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+	return cmd
+}
+
+func TestMain(m *testing.M) {
+	// Prepare re-executes the test binary as the shim
+	RunShim(os.Args)
+	os.Exit(m.Run())
+}
+
+func TestPrepare(t *testing.T) {
+	cmd := startSleep(t, func(cmd *exec.Cmd) {
+		if err := Prepare(cmd, Limits{OpenFiles: 64, Nice: 5, IOClass: IOClassIdle}); err != nil {
+			t.Fatalf("Prepare() error = %v", err)
+		}
+	})
+	pid := cmd.Process.Pid
+
+	// The shim execs sleep once the limits are set, never the other way around
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		comm, _ := os.ReadFile(fmt.Sprintf("/proc/%d/comm", pid))
+		if strings.TrimSpace(string(comm)) == "sleep" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("the shim didn't exec sleep, comm = %q", comm)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/limits", pid))
+	if err != nil {
+		t.Fatalf("failed to read limits: %v", err)
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(line, "Max open files") {
+			if fields := strings.Fields(line); fields[3] != "64" || fields[4] != "64" {
+				t.Errorf("open files limit = %v, want 64", fields[3:5])
+			}
+		}
+	}
+
+	// Getpriority returns 20 - nice to stay positive
+	prio, err := syscall.Getpriority(syscall.PRIO_PROCESS, pid)
+	if err != nil {
+		t.Fatalf("Getpriority() error = %v", err)
+	}
+	if nice := 20 - prio; nice != 5 {
+		t.Errorf("nice = %d, want 5", nice)
+	}
+}
+
+func TestCgroup(t *testing.T) {
+	own, err := ownCgroup()
+	if err != nil {
+		t.Skipf("no cgroup v2 to test with: %v", err)
+	}
+	cgroup, err := NewCgroup()
+	if err != nil {
+		t.Skipf("no cgroup v2 to test with: %v", err)
+	}
+
+	if err := cgroup.Set(Limits{CgroupMemoryMax: 64 << 20, CgroupCPUMax: 0.5}); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	for file, want := range map[string]string{"memory.max": "67108864", "cpu.max": "50000 100000"} {
+		data, _ := os.ReadFile(cgroup.path + "/" + file)
+		if got := strings.TrimSpace(string(data)); got != want {
+			t.Errorf("%s = %q, want %q", file, got, want)
+		}
+	}
+
+	cmd := startSleep(t, cgroup.Attach)
+	procs, _ := os.ReadFile(cgroup.path + "/cgroup.procs")
+	if !strings.Contains(string(procs), fmt.Sprint(cmd.Process.Pid)) {
+		t.Errorf("sleep isn't in the cgroup, cgroup.procs = %q", procs)
+	}
+	if n := cgroup.OOMKills(); n != 0 {
+		t.Errorf("OOMKills() = %d, want 0", n)
+	}
+
+	cmd.Process.Kill()
+	cmd.Wait()
+	if err := cgroup.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+	if got, _ := ownCgroup(); got != own {
+		t.Errorf("pull-watch is in cgroup %s after Close(), want %s", got, own)
+	}
+}
+
+func TestDelegationError(t *testing.T) {
+	for _, errno := range []syscall.Errno{syscall.EBUSY, syscall.EACCES} {
+		err := delegationError("/user.slice/session-1.scope", &os.PathError{Op: "write", Path: "cgroup.procs", Err: errno})
+		if !errors.Is(err, errno) {
+			t.Errorf("delegationError(%v) = %v, doesn't wrap it", errno, err)
+		}
+		for _, want := range []string{"/user.slice/session-1.scope", "Delegate=yes", "systemd-run --user -p Delegate=yes"} {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("delegationError(%v) = %q, want it to mention %q", errno, err, want)
+			}
+		}
+	}
+}
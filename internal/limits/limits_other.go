@@ -0,0 +1,24 @@
+//go:build !linux && !windows
+
+package limits
+
+import (
+	"errors"
+	"syscall"
+)
+
+func supported(l Limits) error {
+	if l.Memory > 0 || l.OpenFiles > 0 || l.CPUTime > 0 || l.IOClass != "" || l.NeedsCgroup() {
+		return errors.New("only -nice and -run-as are supported on this platform, the other limits need Linux")
+	}
+	return nil
+}
+
+// apply sets the limits of the process pid, the shim calls it on itself
+// before it becomes the command
+func apply(pid int, l Limits) error {
+	if l.Nice != 0 {
+		return syscall.Setpriority(syscall.PRIO_PROCESS, pid, l.Nice)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package limits
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int64
+		wantErr bool
+	}{
+		{in: "1048576", want: 1 << 20},
+		{in: "512M", want: 512 << 20},
+		{in: "512mb", want: 512 << 20},
+		{in: "2Gi", want: 2 << 30},
+		{in: "1.5G", want: 3 << 29},
+		{in: "64k", want: 64 << 10},
+		{in: "", wantErr: true},
+		{in: "lots", wantErr: true},
+		{in: "-1M", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseSize(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSize() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseSize() = %d, want %d", got, tt.want)
+			}
+			if !tt.wantErr {
+				if back, _ := ParseSize(FormatSize(got)); back != got {
+					t.Errorf("FormatSize(%d) = %q doesn't parse back", got, FormatSize(got))
+				}
+			}
+		})
+	}
+}
+
+func TestParseIONice(t *testing.T) {
+	tests := []struct {
+		in        string
+		wantClass string
+		wantLevel int
+		wantErr   bool
+	}{
+		{in: "idle", wantClass: IOClassIdle},
+		{in: "best-effort", wantClass: IOClassBestEffort, wantLevel: 4},
+		{in: "Realtime:2", wantClass: IOClassRealtime, wantLevel: 2},
+		{in: "best-effort:high", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			class, level, err := ParseIONice(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseIONice() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if class != tt.wantClass || level != tt.wantLevel {
+				t.Errorf("ParseIONice() = %s:%d, want %s:%d", class, level, tt.wantClass, tt.wantLevel)
+			}
+		})
+	}
+}
+
+func TestLimits_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		limits  Limits
+		wantErr bool
+	}{
+		{name: "none", limits: Limits{}},
+		{name: "nice too low", limits: Limits{Nice: -21}, wantErr: true},
+		{name: "nice too high", limits: Limits{Nice: 20}, wantErr: true},
+		{name: "unknown I/O class", limits: Limits{IOClass: "fast"}, wantErr: true},
+		{name: "I/O level out of range", limits: Limits{IOClass: IOClassBestEffort, IOLevel: 8}, wantErr: true},
+		{name: "negative CPUs", limits: Limits{CgroupCPUMax: -1}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.limits.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if !(Limits{CgroupMemoryMax: 1 << 20}).NeedsCgroup() || (Limits{Memory: 1 << 20, CPUTime: time.Second}).NeedsCgroup() {
+		t.Error("NeedsCgroup() should only hold for cgroup limits")
+	}
+}
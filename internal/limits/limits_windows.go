@@ -0,0 +1,12 @@
+//go:build windows
+
+package limits
+
+import "errors"
+
+func supported(l Limits) error {
+	if !l.IsZero() {
+		return errors.New("resource limits aren't supported on Windows")
+	}
+	return nil
+}
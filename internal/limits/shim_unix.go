@@ -0,0 +1,103 @@
+//go:build !windows
+
+package limits
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// shimArg is the first argument of pull-watch re-executed as the shim, which sets
+// the limits on itself and then execs the command, so it never runs without them
+const shimArg = "__apply-limits"
+
+// shimEnv passes the limits and credential to the shim
+const shimEnv = "PULL_WATCH_LIMITS"
+
+// shimSpec is what the shim applies before it execs the command
+type shimSpec struct {
+	Limits     Limits
+	Credential *syscall.Credential `json:",omitempty"`
+}
+
+// wrap makes cmd start as the shim with l. The credential moves to the shim, which
+// drops it after the limits are set: raising a limit or the priority takes pull-watch's privileges.
+func wrap(cmd *exec.Cmd, l Limits) error {
+	if cmd.Err != nil {
+		// Start reports it
+		return nil
+	}
+	self, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to locate pull-watch to apply the limits: %w", err)
+	}
+
+	spec := shimSpec{Limits: l}
+	if cmd.SysProcAttr != nil {
+		spec.Credential = cmd.SysProcAttr.Credential
+		cmd.SysProcAttr.Credential = nil
+	}
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return fmt.Errorf("failed to encode limits: %w", err)
+	}
+
+	env := cmd.Env
+	if env == nil {
+		env = os.Environ()
+	}
+	cmd.Env = append(env, shimEnv+"="+string(data))
+	cmd.Args = append([]string{self, shimArg, cmd.Path}, cmd.Args...)
+	cmd.Path = self
+	return nil
+}
+
+// RunShim becomes the command when args are the shim's, and returns otherwise.
+// It must run before anything else in main. Failures exit with status 127.
+func RunShim(args []string) {
+	if len(args) < 4 || args[1] != shimArg {
+		return
+	}
+	if err := runShim(args[2], args[3:]); err != nil {
+		fmt.Fprintf(os.Stderr, "pull-watch: %v\n", err)
+		os.Exit(127)
+	}
+}
+
+func runShim(path string, argv []string) error {
+	var spec shimSpec
+	if err := json.Unmarshal([]byte(os.Getenv(shimEnv)), &spec); err != nil {
+		return fmt.Errorf("invalid limits: %w", err)
+	}
+	os.Unsetenv(shimEnv)
+
+	if err := apply(os.Getpid(), spec.Limits); err != nil {
+		return err
+	}
+
+	if c := spec.Credential; c != nil {
+		if !c.NoSetGroups {
+			groups := make([]int, len(c.Groups))
+			for i, g := range c.Groups {
+				groups[i] = int(g)
+			}
+			if err := syscall.Setgroups(groups); err != nil {
+				return fmt.Errorf("failed to set groups: %w", err)
+			}
+		}
+		if err := syscall.Setgid(int(c.Gid)); err != nil {
+			return fmt.Errorf("failed to set group: %w", err)
+		}
+		if err := syscall.Setuid(int(c.Uid)); err != nil {
+			return fmt.Errorf("failed to set user: %w", err)
+		}
+	}
+
+	if err := syscall.Exec(path, argv, os.Environ()); err != nil {
+		return fmt.Errorf("failed to run %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,7 @@
+//go:build windows
+
+package limits
+
+// RunShim becomes the command when args are the shim's, and returns otherwise.
+// Limits aren't supported on Windows, so it always returns.
+func RunShim(args []string) {}
@@ -0,0 +1,214 @@
+package logfile
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultMaxSize is the log size in bytes that triggers a rotation
+	DefaultMaxSize = 10 * 1024 * 1024
+	// DefaultMaxFiles is the number of rotated logs kept next to the active one
+	DefaultMaxFiles = 5
+)
+
+// Options configures rotation of a Writer
+type Options struct {
+	// MaxSize rotates the log once it would grow larger than this many bytes
+	MaxSize int64
+	// MaxAge rotates the log once it has been written to for this long, zero disables it
+	MaxAge time.Duration
+	// MaxFiles is the number of rotated logs to keep
+	MaxFiles int
+	// Compress gzips rotated logs
+	Compress bool
+}
+
+var _ io.WriteCloser = &Writer{}
+
+// Writer is an append-only log file with size and age based rotation.
+// Rotated files are named <path>.1 (newest) to <path>.<MaxFiles> (oldest),
+// with a .gz suffix when compressed.
+type Writer struct {
+	mu      sync.Mutex
+	path    string
+	opts    Options
+	file    *os.File
+	size    int64
+	created time.Time
+	now     func() time.Time
+}
+
+// Open opens or creates the log at path
+func Open(path string, opts Options) (*Writer, error) {
+	if opts.MaxSize <= 0 {
+		opts.MaxSize = DefaultMaxSize
+	}
+	if opts.MaxFiles < 0 {
+		opts.MaxFiles = 0
+	}
+
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create log directory: %w", err)
+		}
+	}
+
+	w := &Writer{path: path, opts: opts, now: time.Now}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *Writer) open() error {
+	f, err := os.OpenFile(w.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("failed to stat log file: %w", err)
+	}
+	w.file = f
+	w.size = info.Size()
+	// There's no portable creation time, an existing log counts from its last write
+	w.created = w.now()
+	if w.size > 0 {
+		w.created = info.ModTime()
+	}
+	return nil
+}
+
+// Path returns the location of the active log file
+func (w *Writer) Path() string {
+	return w.path
+}
+
+// Write appends p to the log, rotating it first if it grew too large or too old
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return 0, fmt.Errorf("log file %s is closed", w.path)
+	}
+
+	if w.size > 0 && (w.size+int64(len(p)) > w.opts.MaxSize ||
+		(w.opts.MaxAge > 0 && w.now().Sub(w.created) >= w.opts.MaxAge)) {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// Rotate starts a new log file regardless of size and age
+func (w *Writer) Rotate() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return fmt.Errorf("log file %s is closed", w.path)
+	}
+	return w.rotate()
+}
+
+// rotate shifts <path>.N to <path>.N+1, drops the oldest and starts a new file
+func (w *Writer) rotate() error {
+	if err := w.file.Close(); err != nil {
+		return fmt.Errorf("failed to close log file: %w", err)
+	}
+	w.file = nil
+
+	if w.opts.MaxFiles == 0 {
+		if err := os.Remove(w.path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove log file: %w", err)
+		}
+		return w.open()
+	}
+
+	// Both suffixes are shifted so changing Compress between runs keeps old logs in order
+	for _, suffix := range []string{"", ".gz"} {
+		_ = os.Remove(RotatedPath(w.path, w.opts.MaxFiles) + suffix)
+		for i := w.opts.MaxFiles - 1; i >= 1; i-- {
+			if err := os.Rename(RotatedPath(w.path, i)+suffix, RotatedPath(w.path, i+1)+suffix); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("failed to rotate log file: %w", err)
+			}
+		}
+	}
+
+	rotated := RotatedPath(w.path, 1)
+	if err := os.Rename(w.path, rotated); err != nil {
+		return fmt.Errorf("failed to rotate log file: %w", err)
+	}
+	if err := w.open(); err != nil {
+		return err
+	}
+
+	if w.opts.Compress {
+		if err := compress(rotated); err != nil {
+			return fmt.Errorf("failed to compress rotated log: %w", err)
+		}
+	}
+	return nil
+}
+
+// Close closes the log
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
+
+// compress replaces path with a gzipped path.gz
+func compress(path string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(out)
+	if _, err := io.Copy(gz, in); err != nil {
+		gz.Close()
+		out.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		out.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(path + ".gz")
+		return err
+	}
+	return os.Remove(path)
+}
+
+// RotatedPath returns the name of the nth rotated file of the log at path, 1 being the newest
+func RotatedPath(path string, n int) string {
+	return fmt.Sprintf("%s.%d", path, n)
+}
@@ -0,0 +1,130 @@
+package logfile
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriterRotatesBySize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "app.log")
+
+	w, err := Open(path, Options{MaxSize: 100, MaxFiles: 2, Compress: true})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer w.Close()
+
+	line := strings.Repeat("x", 39) + "\n"
+	for i := 0; i < 10; i++ {
+		if _, err := w.Write([]byte(line)); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+
+	for _, n := range []int{1, 2} {
+		rotated := RotatedPath(path, n) + ".gz"
+		if got := readGzip(t, rotated); got != line+line {
+			t.Errorf("%s contains %q, want two lines", rotated, got)
+		}
+		if _, err := os.Stat(RotatedPath(path, n)); !os.IsNotExist(err) {
+			t.Errorf("uncompressed %s left behind", RotatedPath(path, n))
+		}
+	}
+	if _, err := os.Stat(RotatedPath(path, 3) + ".gz"); !os.IsNotExist(err) {
+		t.Errorf("expected only 2 rotated files to be kept")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	if string(data) != line+line {
+		t.Errorf("active log contains %q, want two lines", data)
+	}
+}
+
+func TestWriterRotatesByAge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+
+	w, err := Open(path, Options{MaxAge: time.Hour, MaxFiles: 1})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer w.Close()
+
+	now := time.Now()
+	w.now = func() time.Time { return now }
+	w.created = now
+
+	if _, err := w.Write([]byte("old\n")); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	now = now.Add(30 * time.Minute)
+	if _, err := w.Write([]byte("still fresh\n")); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	now = now.Add(time.Hour)
+	if _, err := w.Write([]byte("new\n")); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	rotated, err := os.ReadFile(RotatedPath(path, 1))
+	if err != nil {
+		t.Fatalf("expected an uncompressed rotated log: %v", err)
+	}
+	if string(rotated) != "old\nstill fresh\n" {
+		t.Errorf("rotated log contains %q", rotated)
+	}
+	active, _ := os.ReadFile(path)
+	if string(active) != "new\n" {
+		t.Errorf("active log contains %q, want %q", active, "new\n")
+	}
+}
+
+func TestWriterAppendsToExistingLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	if err := os.WriteFile(path, []byte("previous run\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := Open(path, Options{})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if _, err := w.Write([]byte("this run\n")); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	w.Close()
+
+	if _, err := w.Write([]byte("too late\n")); err == nil {
+		t.Error("Write() after Close() should fail")
+	}
+
+	data, _ := os.ReadFile(path)
+	if string(data) != "previous run\nthis run\n" {
+		t.Errorf("log contains %q", data)
+	}
+}
+
+func readGzip(t *testing.T, path string) string {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Open(%s) error = %v", path, err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("gzip.NewReader(%s) error = %v", path, err)
+	}
+	data, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatalf("reading %s: %v", path, err)
+	}
+	return string(data)
+}
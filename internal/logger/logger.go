@@ -4,6 +4,7 @@ import (
 	"log"
 	"os"
 	"strings"
+	"sync/atomic"
 
 	"github.com/fatih/color"
 )
@@ -16,6 +17,17 @@ const (
 	VerboseLevel
 )
 
+func (l LogLevel) String() string {
+	switch l {
+	case QuietLevel:
+		return "quiet"
+	case VerboseLevel:
+		return "verbose"
+	default:
+		return "default"
+	}
+}
+
 var (
 	prefix         = color.New(color.FgCyan).Sprint("[pull-watch] ")
 	errorColor     = color.New(color.FgRed).SprintFunc()
@@ -27,7 +39,8 @@ var (
 // Logger wraps the standard logger with custom formatting
 type Logger struct {
 	*log.Logger
-	level LogLevel
+	// level can be changed while other goroutines log
+	level atomic.Int32
 }
 
 // Option is a functional option for configuring the logger
@@ -43,7 +56,7 @@ func WithTimestamp() Option {
 // WithLogLevel sets the logging level
 func WithLogLevel(level LogLevel) Option {
 	return func(l *Logger) {
-		l.level = level
+		l.SetLevel(level)
 	}
 }
 
@@ -51,45 +64,55 @@ func WithLogLevel(level LogLevel) Option {
 func New(opts ...Option) *Logger {
 	l := &Logger{
 		Logger: log.New(os.Stderr, prefix, 0),
-		level:  DefaultLevel, // Set default level
 	}
+	l.SetLevel(DefaultLevel)
 	for _, opt := range opts {
 		opt(l)
 	}
 	return l
 }
 
+// SetLevel changes the logging level
+func (l *Logger) SetLevel(level LogLevel) {
+	l.level.Store(int32(level))
+}
+
+// Level returns the current logging level
+func (l *Logger) Level() LogLevel {
+	return LogLevel(l.level.Load())
+}
+
 // Warn logs a warning message with yellow color
 func (l *Logger) Warn(format string, v ...interface{}) {
-	if l.level >= QuietLevel {
+	if l.Level() >= QuietLevel {
 		l.Printf(warnColor("WARNING: "+format), v...)
 	}
 }
 
 // Error logs an error message with red color
 func (l *Logger) Error(format string, v ...interface{}) {
-	if l.level >= QuietLevel {
+	if l.Level() >= QuietLevel {
 		l.Printf(errorColor("ERROR: "+format), v...)
 	}
 }
 
 // Info logs an info message with green color
 func (l *Logger) Info(format string, v ...interface{}) {
-	if l.level >= DefaultLevel {
+	if l.Level() >= DefaultLevel {
 		l.Printf(infoColor(format), v...)
 	}
 }
 
 // Debug logs a debug message (only in verbose mode)
 func (l *Logger) Debug(format string, v ...interface{}) {
-	if l.level >= VerboseLevel {
+	if l.Level() >= VerboseLevel {
 		l.Printf(format, v...)
 	}
 }
 
 // MultiColor logs a message with multiple color segments
 func (l *Logger) MultiColor(level LogLevel, segments ...ColoredSegment) {
-	if l.level >= level {
+	if l.Level() >= level {
 		var parts []string
 		for _, seg := range segments {
 			parts = append(parts, seg.Color(seg.Text))
@@ -0,0 +1,386 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/ship-digital/pull-watch/internal/events"
+	"github.com/ship-digital/pull-watch/internal/logger"
+)
+
+// Format selects the payload shape sent to a webhook
+type Format string
+
+const (
+	// FormatJSON posts the event as a generic JSON object
+	FormatJSON Format = "json"
+	// FormatSlack posts a Slack incoming webhook message
+	FormatSlack Format = "slack"
+	// FormatDiscord posts a Discord webhook message
+	FormatDiscord Format = "discord"
+	// FormatTeams posts a Microsoft Teams incoming webhook message card
+	FormatTeams Format = "teams"
+)
+
+// DefaultTemplate renders the message text of chat notifications
+const DefaultTemplate = `[pull-watch] {{.Host}} {{.Repository}}: {{.Summary}}`
+
+// DefaultEvents are the event types notified when none are configured
+var DefaultEvents = []events.Type{events.Pull, events.Crash, events.Rollback, events.Error}
+
+const (
+	defaultQueueSize = 100
+	defaultRetries   = 3
+	defaultBackoff   = time.Second
+	defaultTimeout   = 10 * time.Second
+	// closeTimeout bounds how long Close waits for queued notifications
+	closeTimeout = 5 * time.Second
+)
+
+// Target is a webhook endpoint and the payload format it expects
+type Target struct {
+	Format Format
+	URL    string
+}
+
+// ParseTarget parses "[format=]url", the format defaults to json
+func ParseTarget(s string) (Target, error) {
+	t := Target{Format: FormatJSON, URL: s}
+	if i := strings.Index(s, "="); i > 0 && !strings.Contains(s[:i], "/") {
+		t.Format = Format(strings.ToLower(s[:i]))
+		t.URL = s[i+1:]
+	}
+
+	switch t.Format {
+	case FormatJSON, FormatSlack, FormatDiscord, FormatTeams:
+	default:
+		return Target{}, fmt.Errorf("unknown notification format %q (expected json, slack, discord or teams)", t.Format)
+	}
+
+	u, err := url.Parse(t.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return Target{}, fmt.Errorf("invalid notification URL %q", redactURL(t.URL))
+	}
+	return t, nil
+}
+
+// ParseEvents parses event type names, accepting "update" as an alias of "pull"
+func ParseEvents(names []string) ([]events.Type, error) {
+	var types []events.Type
+	for _, name := range names {
+		t := events.Type(strings.ToLower(strings.TrimSpace(name)))
+		if t == "update" {
+			t = events.Pull
+		}
+		known := false
+		for _, k := range events.Types {
+			if t == k {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown notification event %q", name)
+		}
+		types = append(types, t)
+	}
+	return types, nil
+}
+
+// Options configures a Notifier
+type Options struct {
+	Targets    []Target
+	Events     []events.Type
+	Template   string
+	Host       string
+	Repository string
+	QueueSize  int
+	Retries    int
+	Backoff    time.Duration
+	Timeout    time.Duration
+}
+
+var _ events.Sink = &Notifier{}
+
+// Notifier delivers events to webhooks from a background queue so a slow
+// endpoint never holds up the caller
+type Notifier struct {
+	opts   Options
+	tmpl   *template.Template
+	events map[events.Type]bool
+	client *http.Client
+	logger *logger.Logger
+	queue  chan events.Event
+	done   chan struct{}
+	ctx    context.Context
+	cancel context.CancelFunc
+	mu     sync.Mutex
+	closed bool
+}
+
+// New creates a Notifier and starts its delivery worker
+func New(opts Options, log *logger.Logger) (*Notifier, error) {
+	if opts.Template == "" {
+		opts.Template = DefaultTemplate
+	}
+	if len(opts.Events) == 0 {
+		opts.Events = DefaultEvents
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = defaultQueueSize
+	}
+	if opts.Retries <= 0 {
+		opts.Retries = defaultRetries
+	}
+	if opts.Backoff <= 0 {
+		opts.Backoff = defaultBackoff
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultTimeout
+	}
+
+	tmpl, err := template.New("notification").Parse(opts.Template)
+	if err != nil {
+		return nil, fmt.Errorf("invalid notification template: %w", err)
+	}
+
+	n := &Notifier{
+		opts:   opts,
+		tmpl:   tmpl,
+		events: make(map[events.Type]bool),
+		client: &http.Client{Timeout: opts.Timeout},
+		logger: log,
+		queue:  make(chan events.Event, opts.QueueSize),
+		done:   make(chan struct{}),
+	}
+	for _, t := range opts.Events {
+		n.events[t] = true
+	}
+	n.ctx, n.cancel = context.WithCancel(context.Background())
+
+	go n.loop()
+	return n, nil
+}
+
+// Record queues e for delivery if its type is notified, it never blocks
+func (n *Notifier) Record(e events.Event) error {
+	if !n.events[e.Type] {
+		return nil
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.closed {
+		return fmt.Errorf("notifier closed, dropping %s event", e.Type)
+	}
+	select {
+	case n.queue <- e:
+		return nil
+	default:
+		return fmt.Errorf("notification queue full, dropping %s event", e.Type)
+	}
+}
+
+// Close stops accepting events and waits a bounded time for queued ones to be delivered
+func (n *Notifier) Close() error {
+	n.mu.Lock()
+	if n.closed {
+		n.mu.Unlock()
+		return nil
+	}
+	n.closed = true
+	close(n.queue)
+	n.mu.Unlock()
+
+	select {
+	case <-n.done:
+	case <-time.After(closeTimeout):
+		n.logger.Warn("Gave up delivering %d queued notifications", len(n.queue))
+		n.cancel()
+		<-n.done
+	}
+	n.cancel()
+	return nil
+}
+
+func (n *Notifier) loop() {
+	defer close(n.done)
+	for e := range n.queue {
+		for _, target := range n.opts.Targets {
+			if err := n.deliver(target, e); err != nil {
+				n.logger.Warn("Failed to send %s notification to %s: %v", e.Type, redactURL(target.URL), err)
+			}
+		}
+	}
+}
+
+// deliver posts e to target, retrying transient failures with exponential backoff
+func (n *Notifier) deliver(target Target, e events.Event) error {
+	body, err := n.payload(target.Format, e)
+	if err != nil {
+		return err
+	}
+
+	backoff := n.opts.Backoff
+	for attempt := 1; ; attempt++ {
+		retry, err := n.post(target.URL, body)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= n.opts.Retries {
+			return err
+		}
+
+		n.logger.Debug("Notification attempt %d to %s failed, retrying in %s: %v", attempt, redactURL(target.URL), backoff, err)
+		select {
+		case <-time.After(backoff):
+		case <-n.ctx.Done():
+			return err
+		}
+		backoff *= 2
+	}
+}
+
+// post sends body and reports whether a failure is worth retrying
+func (n *Notifier) post(endpoint string, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(n.ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "pull-watch")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		// Network errors are usually transient, unless we're shutting down
+		return n.ctx.Err() == nil, fmt.Errorf("request failed: %s", strings.ReplaceAll(err.Error(), endpoint, redactURL(endpoint)))
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retry, fmt.Errorf("unexpected response status %s", resp.Status)
+}
+
+// templateData is what notification templates can refer to
+type templateData struct {
+	events.Event
+	Host        string
+	Repository  string
+	ShortCommit string
+	Summary     string
+}
+
+func (n *Notifier) payload(format Format, e events.Event) ([]byte, error) {
+	data := templateData{
+		Event:       e,
+		Host:        n.opts.Host,
+		Repository:  n.opts.Repository,
+		ShortCommit: events.ShortCommit(e.Commit),
+		Summary:     summary(e),
+	}
+
+	var text bytes.Buffer
+	if err := n.tmpl.Execute(&text, data); err != nil {
+		return nil, fmt.Errorf("failed to render notification: %w", err)
+	}
+	message := text.String()
+
+	switch format {
+	case FormatSlack:
+		return json.Marshal(map[string]string{"text": message})
+	case FormatDiscord:
+		return json.Marshal(map[string]string{"content": message})
+	case FormatTeams:
+		return json.Marshal(map[string]string{
+			"@type":    "MessageCard",
+			"@context": "https://schema.org/extensions",
+			"summary":  fmt.Sprintf("pull-watch %s", e.Type),
+			"text":     message,
+		})
+	default:
+		return json.Marshal(struct {
+			events.Event
+			Host       string `json:"host,omitempty"`
+			Repository string `json:"repository,omitempty"`
+			Message    string `json:"message"`
+		}{
+			Event:      e,
+			Host:       n.opts.Host,
+			Repository: n.opts.Repository,
+			Message:    message,
+		})
+	}
+}
+
+// summary describes e in a short human readable sentence
+func summary(e events.Event) string {
+	commit := events.ShortCommit(e.Commit)
+	if e.Subject != "" {
+		commit = fmt.Sprintf("%s (%s)", commit, e.Subject)
+	}
+
+	failed := e.Outcome == events.OutcomeFailure
+	switch e.Type {
+	case events.Change:
+		return fmt.Sprintf("new commit %s detected", commit)
+	case events.Pull:
+		if failed {
+			return fmt.Sprintf("failed to update to %s: %s", commit, e.Error)
+		}
+		return fmt.Sprintf("updated to %s", commit)
+	case events.Start:
+		if failed {
+			return fmt.Sprintf("failed to start command at %s: %s", commit, e.Error)
+		}
+		return fmt.Sprintf("started command at %s", commit)
+	case events.Restart:
+		if failed {
+			return fmt.Sprintf("failed to restart command at %s: %s", commit, e.Error)
+		}
+		return fmt.Sprintf("restarted command at %s", commit)
+	case events.Reload:
+		if failed {
+			return fmt.Sprintf("failed to reload command at %s, restarting it: %s", commit, e.Error)
+		}
+		return fmt.Sprintf("reloaded command at %s", commit)
+	case events.Crash:
+		outcome := e.Outcome
+		if e.Exit != nil {
+			outcome = e.Exit.String()
+		}
+		return fmt.Sprintf("command with PID %d %s after %s at %s", e.PID, outcome, e.Duration(), commit)
+	case events.Exit:
+		return fmt.Sprintf("command with PID %d exited after %s at %s", e.PID, e.Duration(), commit)
+	case events.Rollback:
+		if failed {
+			return fmt.Sprintf("failed to roll back to %s: %s", commit, e.Error)
+		}
+		return fmt.Sprintf("rolled back to %s", commit)
+	case events.Error:
+		return fmt.Sprintf("update check failed: %s", e.Error)
+	default:
+		return string(e.Type)
+	}
+}
+
+// redactURL hides webhook paths and credentials, which are secrets for most chat services
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return "<invalid URL>"
+	}
+	return fmt.Sprintf("%s://%s/...", u.Scheme, u.Host)
+}
@@ -0,0 +1,178 @@
+package notify
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ship-digital/pull-watch/internal/events"
+	"github.com/ship-digital/pull-watch/internal/logger"
+)
+
+func TestParseTarget(t *testing.T) {
+	tests := []struct {
+		in         string
+		wantFormat Format
+		wantURL    string
+		wantErr    bool
+	}{
+		{in: "https://example.com/hook", wantFormat: FormatJSON, wantURL: "https://example.com/hook"},
+		{in: "slack=https://hooks.slack.com/services/T/B/X", wantFormat: FormatSlack, wantURL: "https://hooks.slack.com/services/T/B/X"},
+		{in: "Discord=https://discord.com/api/webhooks/1/x", wantFormat: FormatDiscord, wantURL: "https://discord.com/api/webhooks/1/x"},
+		{in: "https://example.com/hook?token=a=b", wantFormat: FormatJSON, wantURL: "https://example.com/hook?token=a=b"},
+		{in: "irc=https://example.com/hook", wantErr: true},
+		{in: "slack=not-a-url", wantErr: true},
+		{in: "ftp://example.com/hook", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseTarget(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseTarget() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && (got.Format != tt.wantFormat || got.URL != tt.wantURL) {
+				t.Errorf("ParseTarget() = %+v, want %s %s", got, tt.wantFormat, tt.wantURL)
+			}
+			if err != nil && strings.Contains(err.Error(), "/services/") {
+				t.Errorf("ParseTarget() error leaks the webhook path: %v", err)
+			}
+		})
+	}
+}
+
+func TestNotifier_Formats(t *testing.T) {
+	tests := []struct {
+		format Format
+		field  string
+	}{
+		{format: FormatJSON, field: "message"},
+		{format: FormatSlack, field: "text"},
+		{format: FormatDiscord, field: "content"},
+		{format: FormatTeams, field: "text"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.format), func(t *testing.T) {
+			bodies := make(chan map[string]interface{}, 1)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var body map[string]interface{}
+				data, _ := io.ReadAll(r.Body)
+				if err := json.Unmarshal(data, &body); err != nil {
+					t.Errorf("invalid JSON payload %q: %v", data, err)
+				}
+				bodies <- body
+			}))
+			defer server.Close()
+
+			n, err := New(Options{
+				Targets:    []Target{{Format: tt.format, URL: server.URL}},
+				Host:       "box-1",
+				Repository: "shop",
+			}, logger.New())
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			defer n.Close()
+
+			if err := n.Record(events.Event{Type: events.Pull, Commit: "abcdef0123456789", Subject: "Fix checkout", Outcome: events.OutcomeSuccess}); err != nil {
+				t.Fatalf("Record() error = %v", err)
+			}
+
+			select {
+			case body := <-bodies:
+				want := "[pull-watch] box-1 shop: updated to abcdef01 (Fix checkout)"
+				if body[tt.field] != want {
+					t.Errorf("payload %q = %v, want %q", tt.field, body[tt.field], want)
+				}
+			case <-time.After(2 * time.Second):
+				t.Fatal("notification not delivered")
+			}
+		})
+	}
+}
+
+func TestNotifier_FiltersEvents(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+	}))
+	defer server.Close()
+
+	n, err := New(Options{
+		Targets: []Target{{Format: FormatJSON, URL: server.URL}},
+		Events:  []events.Type{events.Crash},
+	}, logger.New())
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	_ = n.Record(events.Event{Type: events.Restart})
+	_ = n.Record(events.Event{Type: events.Crash})
+	n.Close()
+
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Errorf("webhook called %d times, want 1", got)
+	}
+}
+
+func TestNotifier_RetriesServerErrors(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer server.Close()
+
+	n, err := New(Options{
+		Targets: []Target{{Format: FormatSlack, URL: server.URL}},
+		Backoff: 10 * time.Millisecond,
+	}, logger.New())
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	_ = n.Record(events.Event{Type: events.Error, Error: "network error"})
+	n.Close()
+
+	if got := atomic.LoadInt32(&calls); got != 3 {
+		t.Errorf("webhook called %d times, want 3 (two failures then success)", got)
+	}
+}
+
+func TestNotifier_NeverBlocks(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	n, err := New(Options{
+		Targets:   []Target{{Format: FormatJSON, URL: server.URL}},
+		QueueSize: 2,
+	}, logger.New())
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	start := time.Now()
+	var dropped int
+	for i := 0; i < 10; i++ {
+		if err := n.Record(events.Event{Type: events.Crash}); err != nil {
+			dropped++
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("Record() blocked for %s with a hanging endpoint", elapsed)
+	}
+	if dropped == 0 {
+		t.Error("expected events to be dropped once the queue is full")
+	}
+}
@@ -0,0 +1,159 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Format selects how command output lines are written
+type Format string
+
+const (
+	// FormatRaw passes output through untouched
+	FormatRaw Format = "raw"
+	// FormatPrefixed prefixes each line with the commit, PID, stream and time
+	FormatPrefixed Format = "prefixed"
+	// FormatJSON wraps each line in a JSON record shaped like pull-watch's history events
+	FormatJSON Format = "json"
+)
+
+// Formats lists the supported formats
+var Formats = []Format{FormatRaw, FormatPrefixed, FormatJSON}
+
+// maxLineLength bounds how much of a line without a newline is buffered before it is written anyway
+const maxLineLength = 64 * 1024
+
+// Fields describes where a line came from
+type Fields struct {
+	Commit string
+	PID    int
+	Stream string
+}
+
+// Record is a line of output in the JSON format, its field names follow events.Event
+type Record struct {
+	Time   time.Time `json:"time"`
+	Type   string    `json:"type"`
+	Commit string    `json:"commit,omitempty"`
+	PID    int       `json:"pid,omitempty"`
+	Stream string    `json:"stream"`
+	// Line holds text output, lines that aren't valid UTF-8 are kept intact in Data instead
+	Line    string `json:"line"`
+	Data    []byte `json:"data,omitempty"`
+	Partial bool   `json:"partial,omitempty"`
+}
+
+var _ io.WriteCloser = &Writer{}
+
+// Writer annotates complete lines written to it and passes them on to the
+// underlying writer, one Write per line so lines from concurrent streams
+// never interleave. Call Close to flush a trailing line without a newline.
+type Writer struct {
+	mu     sync.Mutex
+	w      io.Writer
+	format Format
+	fields Fields
+	buf    []byte
+	now    func() time.Time
+}
+
+// NewWriter creates a Writer annotating lines with fields
+func NewWriter(w io.Writer, format Format, fields Fields) *Writer {
+	return &Writer{w: w, format: format, fields: fields, now: time.Now}
+}
+
+// Write buffers p and writes out every line it completes
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.format == FormatRaw || w.format == "" {
+		return w.w.Write(p)
+	}
+
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		if err := w.writeLine(w.buf[:i], false); err != nil {
+			return len(p), err
+		}
+		w.buf = w.buf[i+1:]
+	}
+
+	// A line this long is most likely binary data, don't hold on to it forever
+	for len(w.buf) >= maxLineLength {
+		if err := w.writeLine(w.buf[:maxLineLength], true); err != nil {
+			return len(p), err
+		}
+		w.buf = w.buf[maxLineLength:]
+	}
+
+	// Don't keep the capacity of a large write around
+	if len(w.buf) == 0 {
+		w.buf = nil
+	}
+	return len(p), nil
+}
+
+// Close writes any buffered partial line
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.buf) == 0 {
+		return nil
+	}
+	err := w.writeLine(w.buf, true)
+	w.buf = nil
+	return err
+}
+
+func (w *Writer) writeLine(line []byte, partial bool) error {
+	line = bytes.TrimSuffix(line, []byte("\r"))
+	now := w.now()
+
+	var out []byte
+	switch w.format {
+	case FormatJSON:
+		r := Record{
+			Time:    now,
+			Type:    "output",
+			Commit:  w.fields.Commit,
+			PID:     w.fields.PID,
+			Stream:  w.fields.Stream,
+			Partial: partial,
+		}
+		if utf8.Valid(line) {
+			r.Line = string(line)
+		} else {
+			r.Data = line
+		}
+		data, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		out = append(data, '\n')
+	default:
+		out = append([]byte(w.prefix(now)), line...)
+		out = append(out, '\n')
+	}
+
+	_, err := w.w.Write(out)
+	return err
+}
+
+func (w *Writer) prefix(now time.Time) string {
+	commit := w.fields.Commit
+	if commit == "" {
+		commit = "-"
+	}
+	return fmt.Sprintf("[%s %d %s %s] ", commit, w.fields.PID, w.fields.Stream, now.Format("2006-01-02T15:04:05.000Z07:00"))
+}
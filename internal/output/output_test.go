@@ -0,0 +1,100 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func fixedWriter(buf *bytes.Buffer, format Format) *Writer {
+	w := NewWriter(buf, format, Fields{Commit: "abc1234", PID: 42, Stream: "stdout"})
+	w.now = func() time.Time { return time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC) }
+	return w
+}
+
+func TestWriter_Prefixed(t *testing.T) {
+	var buf bytes.Buffer
+	w := fixedWriter(&buf, FormatPrefixed)
+
+	// Lines split across writes are only written once complete
+	for _, chunk := range []string{"hel", "lo\nwor", "ld\r\n", "no newline"} {
+		if _, err := w.Write([]byte(chunk)); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if strings.Contains(buf.String(), "no newline") {
+		t.Error("partial line written before Close()")
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	prefix := "[abc1234 42 stdout 2024-01-02T03:04:05.000Z] "
+	want := prefix + "hello\n" + prefix + "world\n" + prefix + "no newline\n"
+	if buf.String() != want {
+		t.Errorf("output = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestWriter_JSON(t *testing.T) {
+	var buf bytes.Buffer
+	w := fixedWriter(&buf, FormatJSON)
+
+	if _, err := w.Write([]byte("text line\n\xff\xfe\x00\n")); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d records, want 2: %q", len(lines), buf.String())
+	}
+
+	var text, binary Record
+	if err := json.Unmarshal([]byte(lines[0]), &text); err != nil {
+		t.Fatalf("invalid record %q: %v", lines[0], err)
+	}
+	if text.Line != "text line" || text.Commit != "abc1234" || text.PID != 42 || text.Stream != "stdout" || text.Type != "output" {
+		t.Errorf("text record = %+v", text)
+	}
+
+	if err := json.Unmarshal([]byte(lines[1]), &binary); err != nil {
+		t.Fatalf("invalid record %q: %v", lines[1], err)
+	}
+	if !bytes.Equal(binary.Data, []byte("\xff\xfe\x00")) || binary.Line != "" {
+		t.Errorf("binary record = %+v, want the bytes kept in Data", binary)
+	}
+}
+
+func TestWriter_LongLines(t *testing.T) {
+	var buf bytes.Buffer
+	w := fixedWriter(&buf, FormatJSON)
+
+	if _, err := w.Write(bytes.Repeat([]byte("x"), maxLineLength+10)); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if got := strings.Count(buf.String(), "\n"); got != 1 {
+		t.Fatalf("got %d records for an overlong line, want the first chunk written", got)
+	}
+
+	var r Record
+	if err := json.Unmarshal(buf.Bytes(), &r); err != nil {
+		t.Fatalf("invalid record: %v", err)
+	}
+	if !r.Partial || len(r.Line) != maxLineLength {
+		t.Errorf("record partial = %v with %d bytes, want a partial %d byte chunk", r.Partial, len(r.Line), maxLineLength)
+	}
+}
+
+func TestWriter_Raw(t *testing.T) {
+	var buf bytes.Buffer
+	w := fixedWriter(&buf, FormatRaw)
+
+	if _, err := w.Write([]byte("as\x00is")); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if buf.String() != "as\x00is" {
+		t.Errorf("raw output = %q", buf.String())
+	}
+}
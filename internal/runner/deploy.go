@@ -0,0 +1,314 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ship-digital/pull-watch/internal/config"
+	"github.com/ship-digital/pull-watch/internal/events"
+	"github.com/ship-digital/pull-watch/internal/git"
+	"github.com/ship-digital/pull-watch/internal/logger"
+)
+
+// currentLink is the symlink in the deploy directory pointing at the live worktree
+const currentLink = "current"
+
+// deployJournal is the file in the deploy directory recording when each worktree went live
+const deployJournal = "deploys.log"
+
+// failedDeployRetry is how long a commit whose deploy failed waits before it is tried again,
+// a new commit is tried right away
+var failedDeployRetry = 15 * time.Minute
+
+// failedDeploy remembers the commit whose deploy failed last, so a build that
+// fails every time runs and is reported once per commit instead of on every tick
+type failedDeploy struct {
+	commit string
+	at     time.Time
+}
+
+// due reports whether commit may be deployed: it didn't fail last, or failedDeployRetry passed since
+func (f *failedDeploy) due(commit string) bool {
+	return f.commit != commit || time.Since(f.at) >= failedDeployRetry
+}
+
+// fail records that the deploy of commit failed with err. It returns err the
+// first time for a commit, later failures are only logged.
+func (f *failedDeploy) fail(cfg *config.Config, commit string, err error) error {
+	first := f.commit != commit
+	f.commit, f.at = commit, time.Now()
+	if first {
+		return err
+	}
+	cfg.Logger.MultiColor(logger.VerboseLevel,
+		logger.InfoSegment("Deploy of "),
+		logger.HighlightSegment(events.ShortCommit(commit)),
+		logger.InfoSegment(" still fails, next try in "),
+		logger.HighlightSegment(failedDeployRetry.String()),
+		logger.InfoSegment(": "),
+		logger.HighlightSegment(err.Error()),
+	)
+	return nil
+}
+
+// worktreeDeployer is implemented by repositories that can check commits out in worktrees of their own
+type worktreeDeployer interface {
+	AddWorktree(ctx context.Context, dir, commit string) error
+	RemoveWorktree(ctx context.Context, dir string) error
+}
+
+// deployDir returns the directory holding the worktrees and the current symlink
+func deployDir(ctx context.Context, cfg *config.Config, repo git.Repository) (string, error) {
+	if cfg.Deploy.Dir != "" {
+		return cfg.Deploy.Dir, nil
+	}
+	gitDir, err := repo.GetGitDir(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to locate git directory: %w", err)
+	}
+	return filepath.Join(gitDir, "pull-watch", "deploys"), nil
+}
+
+// deployCommit checks commit out in a worktree of its own, builds it and makes it
+// the live one. Nothing changes when the build fails, the command keeps running
+// the worktree it has. Without worktree deploys it does nothing.
+func deployCommit(ctx context.Context, cfg *config.Config, repo git.Repository, commit string) error {
+	if cfg.Deploy.Mode != config.DeployWorktree {
+		return nil
+	}
+	deployer, ok := repo.(worktreeDeployer)
+	if !ok {
+		return errors.New("the repository doesn't support worktree deploys")
+	}
+	base, err := deployDir(ctx, cfg, repo)
+	if err != nil {
+		return err
+	}
+	name := events.ShortCommit(commit)
+	dir := filepath.Join(base, name)
+	link := filepath.Join(base, currentLink)
+	if live, err := os.Readlink(link); err == nil && live == name {
+		return nil
+	}
+
+	if cfg.DryRun {
+		cfg.Logger.MultiColor(logger.QuietLevel,
+			logger.HighlightSegment("[dry-run] "),
+			logger.InfoSegment("Would deploy "),
+			logger.HighlightSegment(name),
+			logger.InfoSegment(" to "),
+			logger.HighlightSegment(dir),
+		)
+		return nil
+	}
+
+	cfg.Logger.MultiColor(logger.DefaultLevel,
+		logger.InfoSegment("Deploying "),
+		logger.HighlightSegment(name),
+		logger.InfoSegment(" to "),
+		logger.HighlightSegment(dir),
+	)
+	if err := os.MkdirAll(base, 0o755); err != nil {
+		return fmt.Errorf("failed to create deploy directory: %w", err)
+	}
+	if _, err := os.Stat(dir); err == nil {
+		// Left behind by a failed build, or kept from an earlier deploy: start over
+		if err := deployer.RemoveWorktree(ctx, dir); err != nil {
+			return err
+		}
+	}
+	if err := deployer.AddWorktree(ctx, dir, commit); err != nil {
+		_ = deployer.RemoveWorktree(ctx, dir)
+		return err
+	}
+	if err := build(ctx, cfg, dir); err != nil {
+		_ = deployer.RemoveWorktree(ctx, dir)
+		return fmt.Errorf("build of %s failed, keeping the live worktree: %w", name, err)
+	}
+
+	// A symlink renamed over the old one swaps them atomically
+	next := link + ".next"
+	_ = os.Remove(next)
+	if err := os.Symlink(name, next); err != nil {
+		return fmt.Errorf("failed to link the new worktree: %w", err)
+	}
+	if err := os.Rename(next, link); err != nil {
+		return fmt.Errorf("failed to switch to the new worktree: %w", err)
+	}
+	cfg.Logger.MultiColor(logger.DefaultLevel,
+		logger.HighlightSegment(name),
+		logger.InfoSegment(" is live"),
+	)
+
+	journal, err := os.OpenFile(filepath.Join(base, deployJournal), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err == nil {
+		_, err = fmt.Fprintf(journal, "%s %s\n", time.Now().UTC().Format(time.RFC3339Nano), name)
+		journal.Close()
+	}
+	if err != nil {
+		cfg.Logger.Warn("Failed to record the deploy of %s, it may be pruned out of order: %v", name, err)
+	}
+	return nil
+}
+
+// build runs the build command in dir, with the command's environment
+func build(ctx context.Context, cfg *config.Config, dir string) error {
+	if cfg.Deploy.Build == "" {
+		return nil
+	}
+	if cfg.Deploy.BuildTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.Deploy.BuildTimeout)
+		defer cancel()
+	}
+
+	cfg.Logger.MultiColor(logger.DefaultLevel,
+		logger.InfoSegment("Building: "),
+		logger.HighlightSegment(cfg.Deploy.Build),
+	)
+	start := time.Now()
+	cmd := shellCommand(ctx, cfg.Deploy.Build)
+	cmd.Dir = dir
+	if len(cfg.Env) > 0 {
+		cmd.Env = append(os.Environ(), cfg.Env...)
+	}
+	out, err := cmd.CombinedOutput()
+	if len(out) > 0 {
+		cfg.Logger.MultiColor(logger.VerboseLevel, logger.InfoSegment(strings.TrimRight(string(out), "\n")))
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("timed out after %s", cfg.Deploy.BuildTimeout)
+	}
+	if err != nil {
+		// The end of the output is where builds say what went wrong
+		lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+		if last := lines[len(lines)-1]; last != "" {
+			return fmt.Errorf("%w: %s", err, last)
+		}
+		return err
+	}
+	cfg.Logger.MultiColor(logger.DefaultLevel,
+		logger.InfoSegment("Built in "),
+		logger.HighlightSegment(time.Since(start).Round(time.Millisecond).String()),
+	)
+	return nil
+}
+
+// deployTimes returns when each worktree in base last went live, from the deploy journal
+func deployTimes(base string) map[string]time.Time {
+	times := map[string]time.Time{}
+	data, err := os.ReadFile(filepath.Join(base, deployJournal))
+	if err != nil {
+		return times
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		stamp, name, ok := strings.Cut(line, " ")
+		if at, err := time.Parse(time.RFC3339Nano, stamp); ok && err == nil && at.After(times[name]) {
+			times[name] = at
+		}
+	}
+	return times
+}
+
+// pruneWorktrees removes the worktrees beyond the Keep most recently deployed
+// ones, once the command was restarted in the new one. The live worktree and
+// the one the command runs in are never removed. Worktrees missing from the
+// deploy journal count as the oldest.
+func pruneWorktrees(ctx context.Context, cfg *config.Config, repo git.Repository, pm Processor) {
+	if cfg.Deploy.Mode != config.DeployWorktree || cfg.DryRun {
+		return
+	}
+	deployer, ok := repo.(worktreeDeployer)
+	if !ok {
+		return
+	}
+	base, err := deployDir(ctx, cfg, repo)
+	if err != nil {
+		cfg.Logger.Warn("Failed to prune worktrees: %v", err)
+		return
+	}
+	entries, err := os.ReadDir(base)
+	if err != nil {
+		cfg.Logger.Warn("Failed to list worktrees: %v", err)
+		return
+	}
+	keep := map[string]bool{}
+	if live, err := filepath.EvalSymlinks(filepath.Join(base, currentLink)); err == nil {
+		keep[filepath.Base(live)] = true
+	}
+	if running := pm.GetDir(); running != "" {
+		keep[filepath.Base(running)] = true
+	}
+
+	times := deployTimes(base)
+	var worktrees []string
+	for _, entry := range entries {
+		// The current symlink and the journal aren't directories
+		if entry.IsDir() {
+			worktrees = append(worktrees, entry.Name())
+		}
+	}
+	sort.SliceStable(worktrees, func(i, j int) bool { return times[worktrees[i]].After(times[worktrees[j]]) })
+
+	var kept []string
+	for i, name := range worktrees {
+		if i < cfg.Deploy.Keep || keep[name] {
+			kept = append(kept, name)
+			continue
+		}
+		dir := filepath.Join(base, name)
+		if err := deployer.RemoveWorktree(ctx, dir); err != nil {
+			cfg.Logger.Warn("Failed to remove old worktree: %v", err)
+			kept = append(kept, name)
+			continue
+		}
+		cfg.Logger.MultiColor(logger.VerboseLevel,
+			logger.InfoSegment("Removed old worktree "),
+			logger.HighlightSegment(dir),
+		)
+	}
+
+	// Only the kept worktrees stay in the journal, so it doesn't grow forever
+	var journal strings.Builder
+	for _, name := range kept {
+		if at, ok := times[name]; ok {
+			fmt.Fprintf(&journal, "%s %s\n", at.Format(time.RFC3339Nano), name)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(base, deployJournal), []byte(journal.String()), 0o644); err != nil {
+		cfg.Logger.Warn("Failed to rewrite the deploy journal: %v", err)
+	}
+}
+
+// useLiveWorktree points the command at the worktree the current symlink points to,
+// which may have been switched by hand to roll back
+func useLiveWorktree(ctx context.Context, cfg *config.Config, repo git.Repository, pm Processor) error {
+	if cfg.Deploy.Mode != config.DeployWorktree || cfg.DryRun {
+		return nil
+	}
+	dir, err := liveWorktree(ctx, cfg, repo)
+	if err != nil {
+		return err
+	}
+	pm.SetDir(dir)
+	return nil
+}
+
+// liveWorktree returns the worktree the current symlink points to
+func liveWorktree(ctx context.Context, cfg *config.Config, repo git.Repository) (string, error) {
+	base, err := deployDir(ctx, cfg, repo)
+	if err != nil {
+		return "", err
+	}
+	dir, err := filepath.EvalSymlinks(filepath.Join(base, currentLink))
+	if err != nil {
+		return "", fmt.Errorf("no live worktree: %w", err)
+	}
+	return dir, nil
+}
@@ -0,0 +1,185 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/ship-digital/pull-watch/internal/config"
+	"github.com/ship-digital/pull-watch/internal/errz"
+	"github.com/ship-digital/pull-watch/internal/events"
+	"github.com/ship-digital/pull-watch/internal/executor"
+	"github.com/ship-digital/pull-watch/internal/git"
+)
+
+// DiagnosisStatus is the outcome of a check run by Diagnose
+type DiagnosisStatus int
+
+const (
+	DiagnosisPass DiagnosisStatus = iota
+	DiagnosisSkip
+	DiagnosisWarn
+	DiagnosisFail
+)
+
+func (s DiagnosisStatus) String() string {
+	switch s {
+	case DiagnosisPass:
+		return "ok"
+	case DiagnosisSkip:
+		return "skip"
+	case DiagnosisWarn:
+		return "warn"
+	default:
+		return "fail"
+	}
+}
+
+// Diagnosis is the result of a single check, with what to do about it when it didn't pass
+type Diagnosis struct {
+	Check  string
+	Status DiagnosisStatus
+	Detail string
+	Fix    string
+}
+
+// leftoverFinder is implemented by repositories that can look for what an interrupted git left behind
+type leftoverFinder interface {
+	FindLeftovers(ctx context.Context) ([]git.Leftover, error)
+}
+
+// Diagnose runs the checks Run relies on before it can watch, in the order it needs them.
+// A failed check means Run would fail, a warning that it would run into trouble later.
+func Diagnose(cfg *config.Config, opts ...WatchOption) []Diagnosis {
+	options := &watchOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	repo := options.repository
+	if repo == nil {
+		repo = git.New(cfg)
+	}
+	commands := options.executor
+	if commands == nil {
+		commands = executor.New(cfg)
+	}
+	ctx := context.Background()
+	var results []Diagnosis
+
+	version, err := commands.ExecuteCommand(ctx, "git", "version")
+	if err != nil {
+		return append(results, Diagnosis{Check: "git", Status: DiagnosisFail, Detail: err.Error(), Fix: "install git and make sure it's on the PATH"})
+	}
+	results = append(results, Diagnosis{Check: "git", Detail: strings.TrimSpace(version)})
+
+	if cfg.LFS {
+		if version, err := commands.ExecuteCommand(ctx, "git", "lfs", "version"); err != nil {
+			results = append(results, Diagnosis{Check: "lfs", Status: DiagnosisFail, Detail: "git-lfs isn't installed", Fix: "install git-lfs, or leave out -lfs"})
+		} else {
+			results = append(results, Diagnosis{Check: "lfs", Detail: strings.TrimSpace(version)})
+		}
+	}
+
+	local, err := repo.GetLatestCommit(ctx)
+	if err != nil {
+		// Everything after needs a repository
+		return append(results, failed("repository", err, "run pull-watch in a git checkout or point -git-dir at one"))
+	}
+	results = append(results, Diagnosis{Check: "repository", Detail: fmt.Sprintf("%s at %s", cfg.GitDir, events.ShortCommit(local))})
+
+	branch, err := repo.GetCurrentBranch(ctx)
+	switch {
+	case err != nil:
+		results = append(results, failed("branch", err, ""))
+	case branch == "HEAD":
+		results = append(results, Diagnosis{Check: "branch", Status: DiagnosisFail, Detail: "detached HEAD, there's no branch to follow", Fix: "check out the branch to follow with: git checkout <branch>"})
+	default:
+		results = append(results, Diagnosis{Check: "branch", Detail: branch})
+	}
+
+	// Without a branch there's no upstream to look up, local mode never looks it up
+	if cfg.Mode == config.ModeLocal {
+		results = append(results, Diagnosis{Check: "remote", Status: DiagnosisSkip, Detail: "not used in local mode"})
+	} else if results[len(results)-1].Status == DiagnosisFail {
+		results = append(results, Diagnosis{Check: "remote", Status: DiagnosisSkip, Detail: "no branch to follow"})
+	} else if remote, err := repo.GetRemoteCommit(ctx); err != nil {
+		results = append(results, failed("remote", err, "check the remote with: git ls-remote"))
+	} else {
+		results = append(results, Diagnosis{Check: "remote", Detail: "upstream at " + events.ShortCommit(remote)})
+	}
+
+	clean, err := repo.IsClean(ctx)
+	switch {
+	case err != nil:
+		results = append(results, failed("working tree", err, ""))
+	case !clean:
+		results = append(results, Diagnosis{Check: "working tree", Status: DiagnosisWarn, Detail: "uncommitted changes, pulls that touch them will fail", Fix: "commit, stash or discard them (git status lists them)"})
+	default:
+		results = append(results, Diagnosis{Check: "working tree", Detail: "clean"})
+	}
+
+	if finder, ok := repo.(leftoverFinder); ok {
+		leftovers, err := finder.FindLeftovers(ctx)
+		switch {
+		case err != nil:
+			results = append(results, failed("leftovers", err, ""))
+		case len(leftovers) > 0:
+			var found []string
+			for _, l := range leftovers {
+				found = append(found, l.String())
+			}
+			results = append(results, Diagnosis{Check: "leftovers", Status: DiagnosisWarn, Detail: strings.Join(found, ", "), Fix: "pull-watch doctor -yes cleans them up"})
+		default:
+			results = append(results, Diagnosis{Check: "leftovers", Detail: "no lock files or interrupted operations"})
+		}
+	}
+
+	if len(cfg.Command) == 0 {
+		results = append(results, Diagnosis{Check: "command", Status: DiagnosisSkip, Detail: "none given", Fix: "pass it after --"})
+	} else if path, err := exec.LookPath(cfg.Command[0]); err != nil {
+		results = append(results, Diagnosis{Check: "command", Status: DiagnosisFail, Detail: err.Error(), Fix: "install it, or give its full path"})
+	} else {
+		results = append(results, Diagnosis{Check: "command", Detail: path})
+	}
+
+	return append(results, diagnoseSignals(cfg))
+}
+
+// diagnoseSignals checks the signals that stop and reload the command exist on this platform
+func diagnoseSignals(cfg *config.Config) Diagnosis {
+	steps := StopSequence(cfg)
+	names := make([]string, 0, len(steps)+1)
+	for _, step := range steps {
+		names = append(names, step.Signal)
+	}
+	if cfg.ReloadSignal != "" {
+		names = append(names, cfg.ReloadSignal)
+	}
+	for _, name := range names {
+		if _, err := lookupSignal(name); err != nil {
+			return Diagnosis{Check: "signals", Status: DiagnosisFail, Detail: err.Error(), Fix: "pick another signal with -stop-signal, -stop-sequence or -reload-signal"}
+		}
+	}
+
+	detail := "stop with " + describeStopSequence(steps)
+	if cfg.ReloadSignal != "" {
+		detail += ", reload with " + cfg.ReloadSignal
+	}
+	return Diagnosis{Check: "signals", Detail: detail}
+}
+
+// failed describes a failed check, the fix comes from the error's class when there's a hint for it
+func failed(check string, err error, fix string) Diagnosis {
+	detail := err.Error()
+	var cmdErr *errz.CommandError
+	if errors.As(err, &cmdErr) {
+		detail = cmdErr.Message()
+	}
+	if hint := Hint(err); hint != "" {
+		fix = hint
+	}
+	return Diagnosis{Check: check, Status: DiagnosisFail, Detail: detail, Fix: fix}
+}
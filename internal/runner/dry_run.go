@@ -0,0 +1,125 @@
+package runner
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ship-digital/pull-watch/internal/config"
+	"github.com/ship-digital/pull-watch/internal/events"
+	"github.com/ship-digital/pull-watch/internal/logger"
+)
+
+var _ Processor = &DryRunProcessManager{}
+
+// DryRunProcessManager logs the process actions that would be taken without running anything
+type DryRunProcessManager struct {
+	cfg         *config.Config
+	logger      *logger.Logger
+	running     bool
+	doneChan    chan struct{}
+	lastLogTime time.Time
+	backoff     time.Duration
+	startTime   time.Time
+}
+
+func NewDryRun(cfg *config.Config) *DryRunProcessManager {
+	return &DryRunProcessManager{
+		cfg:    cfg,
+		logger: cfg.Logger,
+		// Never closed: a process that doesn't exist never exits
+		doneChan: make(chan struct{}),
+	}
+}
+
+func (pm *DryRunProcessManager) Start() error {
+	pm.logger.MultiColor(logger.QuietLevel,
+		logger.HighlightSegment("[dry-run] "),
+		logger.InfoSegment("Would start process in "),
+		logger.HighlightSegment(pm.cfg.GitDir),
+		logger.InfoSegment(": "),
+		logger.HighlightSegment(strings.Join(pm.cfg.Command, " ")),
+	)
+	pm.running = true
+	pm.startTime = time.Now()
+	pm.doneChan = make(chan struct{})
+	pm.backoff = 0
+	pm.lastLogTime = time.Time{}
+	return nil
+}
+
+func (pm *DryRunProcessManager) Stop() error {
+	if !pm.running {
+		return nil
+	}
+
+	target := "process group"
+	if pm.cfg.SignalLeader {
+		target = "process"
+	}
+	action := fmt.Sprintf("Would send %s to %s", describeStopSequence(StopSequence(pm.cfg)), target)
+	pm.logger.MultiColor(logger.QuietLevel,
+		logger.HighlightSegment("[dry-run] "),
+		logger.InfoSegment(action),
+	)
+	pm.running = false
+	// The imaginary process exits as soon as it's asked to
+	close(pm.doneChan)
+	return nil
+}
+
+func (pm *DryRunProcessManager) GetDoneChan() <-chan struct{} {
+	return pm.doneChan
+}
+
+func (pm *DryRunProcessManager) GetBackoff() time.Duration {
+	return pm.backoff
+}
+
+func (pm *DryRunProcessManager) SetBackoff(d time.Duration) {
+	pm.backoff = d
+}
+
+func (pm *DryRunProcessManager) GetLastLogTime() time.Time {
+	return pm.lastLogTime
+}
+
+func (pm *DryRunProcessManager) SetLastLogTime(t time.Time) {
+	pm.lastLogTime = t
+}
+
+func (pm *DryRunProcessManager) GetLogger() *logger.Logger {
+	return pm.logger
+}
+
+func (pm *DryRunProcessManager) IsRunning() bool {
+	return pm.running
+}
+
+func (pm *DryRunProcessManager) GetPID() int {
+	return 0
+}
+
+func (pm *DryRunProcessManager) GetStartTime() time.Time {
+	return pm.startTime
+}
+
+func (pm *DryRunProcessManager) SetRevision(commit string) {}
+
+func (pm *DryRunProcessManager) SetDir(dir string) {}
+
+func (pm *DryRunProcessManager) GetDir() string {
+	return ""
+}
+
+func (pm *DryRunProcessManager) GetExitStatus() *events.ExitStatus {
+	return nil
+}
+
+func (pm *DryRunProcessManager) Signal(name string) error {
+	pm.logger.MultiColor(logger.QuietLevel,
+		logger.HighlightSegment("[dry-run] "),
+		logger.InfoSegment("Would send "+name+" to process"),
+	)
+	return nil
+}
@@ -0,0 +1,96 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/ship-digital/pull-watch/internal/config"
+	"github.com/ship-digital/pull-watch/internal/events"
+	"github.com/ship-digital/pull-watch/internal/git"
+	"github.com/ship-digital/pull-watch/internal/history"
+	"github.com/ship-digital/pull-watch/internal/logger"
+	"github.com/ship-digital/pull-watch/internal/notify"
+)
+
+// openHistory opens the deployment journal configured in cfg.
+// It returns nil when the journal can't be opened, history is then not recorded.
+func openHistory(ctx context.Context, cfg *config.Config, repo git.Repository) *history.Journal {
+	path := cfg.HistoryFile
+	if path == "" {
+		gitDir, err := repo.GetGitDir(ctx)
+		if err != nil {
+			cfg.Logger.Debug("Failed to locate git directory, history disabled: %v", err)
+			return nil
+		}
+		path = history.DefaultPath(gitDir)
+	}
+
+	journal, err := history.Open(path, int64(cfg.HistoryMaxMB)*1024*1024, cfg.HistoryKeep)
+	if err != nil {
+		cfg.Logger.Warn("Failed to open history file, history disabled: %v", err)
+		return nil
+	}
+
+	cfg.Logger.MultiColor(logger.VerboseLevel,
+		logger.InfoSegment("Recording history to "),
+		logger.HighlightSegment(journal.Path()),
+	)
+	return journal
+}
+
+// openNotifier creates the webhook notifier configured in cfg, or nil when no webhook is configured
+func openNotifier(cfg *config.Config) (*notify.Notifier, error) {
+	if len(cfg.NotifyURLs) == 0 {
+		return nil, nil
+	}
+
+	opts := notify.Options{Template: cfg.NotifyTemplate}
+	for _, raw := range cfg.NotifyURLs {
+		target, err := notify.ParseTarget(raw)
+		if err != nil {
+			return nil, err
+		}
+		opts.Targets = append(opts.Targets, target)
+	}
+
+	types, err := notify.ParseEvents(cfg.NotifyEvents)
+	if err != nil {
+		return nil, err
+	}
+	opts.Events = types
+
+	if host, err := os.Hostname(); err == nil {
+		opts.Host = host
+	}
+	if dir, err := filepath.Abs(cfg.GitDir); err == nil {
+		opts.Repository = filepath.Base(dir)
+	}
+
+	n, err := notify.New(opts, cfg.Logger)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.Logger.MultiColor(logger.VerboseLevel,
+		logger.InfoSegment("Sending notifications to "),
+		logger.HighlightSegment(fmt.Sprintf("%d", len(opts.Targets))),
+		logger.InfoSegment(" webhook(s)"),
+	)
+	return n, nil
+}
+
+// addSink makes cfg.Events deliver to sink as well as to any sink already configured
+func addSink(cfg *config.Config, sink events.Sink) {
+	if cfg.Events == nil {
+		cfg.Events = sink
+		return
+	}
+	cfg.Events = events.Sinks{cfg.Events, sink}
+}
+
+// recordEvent adds the commit subject to e and sends it to the configured sinks
+func recordEvent(ctx context.Context, cfg *config.Config, repo git.Repository, e events.Event) {
+	events.RecordWithSubject(ctx, cfg.Events, cfg.Logger, e, repo.GetCommitSubject)
+}
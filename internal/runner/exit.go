@@ -0,0 +1,43 @@
+package runner
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ship-digital/pull-watch/internal/events"
+	"github.com/ship-digital/pull-watch/internal/logger"
+)
+
+// ExitError is returned by Run when the command exits on its own and
+// ExitWithChild is set, pull-watch should exit with Status.Code
+type ExitError struct {
+	Status events.ExitStatus
+}
+
+func (e *ExitError) Error() string {
+	return "command " + e.Status.String()
+}
+
+// exitSegments describe how the process with pid ended, status is nil when unknown
+func exitSegments(pid int, status *events.ExitStatus, runtime time.Duration) []logger.ColoredSegment {
+	segments := []logger.ColoredSegment{
+		logger.InfoSegment("Process with PID "),
+		logger.HighlightSegment(fmt.Sprintf("%d", pid)),
+	}
+	if status == nil {
+		return append(segments, logger.InfoSegment(" exited"))
+	}
+	segments = append(segments,
+		logger.InfoSegment(" "+status.String()+" after "),
+		logger.HighlightSegment(runtime.Round(time.Millisecond).String()),
+	)
+	if usage := status.Usage(); usage != "" {
+		segments = append(segments, logger.InfoSegment(" ("+usage+")"))
+	}
+	return segments
+}
+
+// logExit reports how a process ended and what it used
+func logExit(log *logger.Logger, level logger.LogLevel, pid int, status events.ExitStatus, runtime time.Duration) {
+	log.MultiColor(level, exitSegments(pid, &status, runtime)...)
+}
@@ -0,0 +1,36 @@
+package runner
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ship-digital/pull-watch/internal/errz"
+)
+
+// hints suggest what to do about each class of git failure, checked in order
+var hints = []struct {
+	class error
+	hint  string
+}{
+	{errz.ErrAuth, "check the credentials git uses for the remote, see -git-ssh-key, -git-token-env and -git-token-file"},
+	{errz.ErrNetwork, "the remote can't be reached, check the network and the remote URL (git remote -v)"},
+	{context.DeadlineExceeded, "git took too long, raise -ls-remote-timeout, -fetch-timeout or -pull-timeout"},
+	{errz.ErrNoUpstreamBranch, "set one with: git branch --set-upstream-to=origin/<branch>"},
+	{errz.ErrMissingRef, "the branch or commit isn't on the remote anymore, check the upstream with: git branch -vv"},
+	{errz.ErrLocked, "another git process is using the repository, or one crashed and left its .lock file behind"},
+	{errz.ErrDirtyTree, "commit, stash or discard the local changes git lists above"},
+	{errz.ErrMergeConflict, "the pull was aborted and the checkout left as it was, merge the remote branch by hand or let the remote win with -pull-strategy reset"},
+	{errz.ErrIdentity, "the rebase and merge pull strategies make commits, set user.name and user.email with: git config"},
+	{errz.ErrDiverged, "the local branch has commits the remote doesn't, push them, reset to the remote or pick another -pull-strategy"},
+	{errz.ErrNotRepository, "point -git-dir at a git checkout"},
+}
+
+// Hint returns what the user can do about err, or an empty string when there's no advice
+func Hint(err error) string {
+	for _, h := range hints {
+		if errors.Is(err, h.class) {
+			return h.hint
+		}
+	}
+	return ""
+}
@@ -0,0 +1,69 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ship-digital/pull-watch/internal/config"
+	"github.com/ship-digital/pull-watch/internal/events"
+	"github.com/ship-digital/pull-watch/internal/git"
+	"github.com/ship-digital/pull-watch/internal/logger"
+	"github.com/ship-digital/pull-watch/internal/watcher"
+)
+
+// checkLocal restarts the command when the local HEAD moved since the last check.
+// It is the local mode counterpart of checkAndUpdate and never contacts the remote.
+func checkLocal(ctx context.Context, cfg *config.Config, repo git.Repository, lastCommit *string, failed *failedDeploy, pm Processor, shouldStart bool) error {
+	localHash, err := repo.GetLatestCommit(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get local commit: %w", err)
+	}
+
+	if localHash == *lastCommit {
+		cfg.Logger.MultiColor(logger.VerboseLevel,
+			logger.InfoSegment("Local commit "),
+			logger.HighlightSegment("unchanged"),
+			logger.InfoSegment(": "),
+			logger.HighlightSegment(localHash),
+		)
+		return nil
+	}
+	if !failed.due(localHash) {
+		// Reported when it failed, tried again later
+		return nil
+	}
+
+	pm.GetLogger().Info("\nLocal changes detected!")
+	cfg.Logger.MultiColor(logger.DefaultLevel,
+		logger.InfoSegment("Local HEAD moved from "),
+		logger.HighlightSegment(*lastCommit),
+		logger.InfoSegment(" to "),
+		logger.HighlightSegment(localHash),
+	)
+
+	recordEvent(ctx, cfg, repo, events.Event{Type: events.Change, Commit: localHash})
+	return goLive(ctx, cfg, repo, pm, lastCommit, failed, localHash)
+}
+
+// watchHead starts a filesystem watcher on the repository's HEAD and refs.
+// It returns nil when the watcher can't be set up, the poll ticker then acts as fallback.
+func watchHead(ctx context.Context, cfg *config.Config, repo git.Repository) *watcher.Watcher {
+	gitDir, err := repo.GetGitDir(ctx)
+	if err != nil {
+		cfg.Logger.Warn("Failed to locate git directory, falling back to polling: %v", err)
+		return nil
+	}
+
+	w, err := watcher.NewHeadWatcher(gitDir, cfg.Logger)
+	if err != nil {
+		cfg.Logger.Warn("Failed to watch %s, falling back to polling: %v", gitDir, err)
+		return nil
+	}
+
+	cfg.Logger.MultiColor(logger.VerboseLevel,
+		logger.InfoSegment("Watching "),
+		logger.HighlightSegment(gitDir),
+		logger.InfoSegment(" for HEAD changes"),
+	)
+	return w
+}
@@ -0,0 +1,196 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/ship-digital/pull-watch/internal/config"
+	"github.com/ship-digital/pull-watch/internal/git"
+	"github.com/ship-digital/pull-watch/internal/logger"
+)
+
+// CheckResult is the outcome of a one-shot comparison between local and remote
+type CheckResult struct {
+	Status       git.CommitComparisonResult
+	Branch       string
+	LocalCommit  string
+	RemoteCommit string
+}
+
+// Check compares the local and remote commits once without pulling
+func Check(cfg *config.Config, opts ...WatchOption) (*CheckResult, error) {
+	options := &watchOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	// Never pull, whatever the caller's config says
+	checkCfg := *cfg
+	checkCfg.CheckOnly = true
+
+	repo := options.repository
+	if repo == nil {
+		repo = git.New(&checkCfg)
+	}
+
+	ctx := context.Background()
+
+	result, err := compareOnce(ctx, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	status, err := repo.HandleCommitComparison(ctx, result.LocalCommit, result.RemoteCommit)
+	if err != nil {
+		return nil, err
+	}
+	result.Status = status
+
+	return result, nil
+}
+
+// Update runs a single pull-and-run cycle.
+// The command only runs when changes were pulled, or always with RunOnStart.
+// It returns the command's exit status, or 0 when nothing ran.
+func Update(cfg *config.Config, opts ...WatchOption) (int, error) {
+	options := &watchOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	repo := options.repository
+	if repo == nil {
+		repo = git.New(cfg)
+	}
+
+	ctx := context.Background()
+
+	result, err := compareOnce(ctx, repo)
+	if err != nil {
+		return 1, err
+	}
+
+	if err := repo.PrepareCheckout(ctx); err != nil {
+		return 1, err
+	}
+
+	status, err := repo.HandleCommitComparison(ctx, result.LocalCommit, result.RemoteCommit)
+	if err != nil {
+		return 1, err
+	}
+
+	changed := status == git.AIsAncestorOfB
+	if changed {
+		pulled, err := pulledCommit(ctx, cfg, repo, result.RemoteCommit)
+		if err != nil {
+			return 1, err
+		}
+		if changed, err = repo.TouchesCheckout(ctx, result.LocalCommit, pulled); err != nil {
+			// The pull went through, better an unneeded run than none
+			cfg.Logger.Warn("Failed to tell whether the changes touch the checkout, running anyway: %v", err)
+			changed = true
+		}
+	} else {
+		if changed, err = repo.UpdateTrackedSubmodules(ctx); err != nil {
+			return 1, err
+		}
+	}
+
+	if !changed && !cfg.RunOnStart {
+		cfg.Logger.MultiColor(logger.DefaultLevel,
+			logger.InfoSegment("No changes to the checkout, "),
+			logger.HighlightSegment("not running"),
+			logger.InfoSegment(" command (use "),
+			logger.HighlightSegment("-run-on-start"),
+			logger.InfoSegment(" to override)"),
+		)
+		return 0, nil
+	}
+
+	if len(cfg.Command) == 0 {
+		return 0, nil
+	}
+
+	// The command runs in the repository, or in the live worktree when deploying to worktrees
+	dir := cfg.GitDir
+	if cfg.Deploy.Mode == config.DeployWorktree && !cfg.DryRun {
+		if dir, err = liveWorktree(ctx, cfg, repo); err != nil {
+			return 1, err
+		}
+	}
+	return runForeground(cfg, dir)
+}
+
+func compareOnce(ctx context.Context, repo git.Repository) (*CheckResult, error) {
+	localCommit, err := repo.GetLatestCommit(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get local commit: %w", err)
+	}
+
+	remoteCommit, err := repo.GetRemoteCommit(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get remote commit: %w", err)
+	}
+
+	branch, err := repo.GetCurrentBranch(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current branch: %w", err)
+	}
+
+	return &CheckResult{
+		Branch:       branch,
+		LocalCommit:  localCommit,
+		RemoteCommit: remoteCommit,
+	}, nil
+}
+
+// runForeground runs the command in dir to completion, forwarding termination signals,
+// and returns its exit status using the shell convention of 128+signal for signals
+func runForeground(cfg *config.Config, dir string) (int, error) {
+	cfg.Logger.MultiColor(logger.DefaultLevel,
+		logger.InfoSegment("Running command: "),
+		logger.HighlightSegment(strings.Join(cfg.Command, " ")),
+	)
+
+	cmd := exec.Command(cfg.Command[0], cfg.Command[1:]...)
+	cmd.Dir = dir
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Stdin = os.Stdin
+
+	if err := cmd.Start(); err != nil {
+		return 1, fmt.Errorf("failed to start command: %w", err)
+	}
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	defer func() {
+		signal.Stop(sigChan)
+		close(sigChan)
+	}()
+	go func() {
+		for sig := range sigChan {
+			_ = cmd.Process.Signal(sig)
+		}
+	}()
+
+	err := cmd.Wait()
+	if err == nil {
+		return 0, nil
+	}
+
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return 1, fmt.Errorf("failed to wait for command: %w", err)
+	}
+	if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return 128 + int(status.Signal()), nil
+	}
+	return exitErr.ExitCode(), nil
+}
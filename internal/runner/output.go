@@ -0,0 +1,87 @@
+package runner
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/ship-digital/pull-watch/internal/config"
+	"github.com/ship-digital/pull-watch/internal/logfile"
+	"github.com/ship-digital/pull-watch/internal/logger"
+)
+
+// processOutput is where the command's stdout and stderr are written
+type processOutput struct {
+	stdout io.Writer
+	stderr io.Writer
+	files  []*logfile.Writer
+}
+
+// openOutput opens the log files configured in cfg, streams without one
+// keep going to pull-watch's own stdout and stderr
+func openOutput(cfg *config.Config) (*processOutput, error) {
+	output := &processOutput{stdout: os.Stdout, stderr: os.Stderr}
+	opts := logfile.Options{
+		MaxSize:  int64(cfg.LogMaxMB) * 1024 * 1024,
+		MaxAge:   cfg.LogMaxAge,
+		MaxFiles: cfg.LogKeep,
+		Compress: cfg.LogCompress,
+	}
+
+	if cfg.StdoutFile != "" {
+		w, err := output.open(cfg, cfg.StdoutFile, opts)
+		if err != nil {
+			return nil, err
+		}
+		output.stdout = w
+	}
+	if cfg.StderrFile != "" {
+		// The same file for both streams is a combined log, share the writer
+		// so the two streams don't rotate it separately
+		if samePath(cfg.StderrFile, cfg.StdoutFile) {
+			output.stderr = output.stdout
+		} else {
+			w, err := output.open(cfg, cfg.StderrFile, opts)
+			if err != nil {
+				output.Close()
+				return nil, err
+			}
+			output.stderr = w
+		}
+	}
+	return output, nil
+}
+
+func (o *processOutput) open(cfg *config.Config, path string, opts logfile.Options) (*logfile.Writer, error) {
+	w, err := logfile.Open(path, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open command output file: %w", err)
+	}
+	o.files = append(o.files, w)
+
+	cfg.Logger.MultiColor(logger.VerboseLevel,
+		logger.InfoSegment("Writing command output to "),
+		logger.HighlightSegment(path),
+	)
+	return w, nil
+}
+
+// Close closes the log files
+func (o *processOutput) Close() {
+	for _, f := range o.files {
+		f.Close()
+	}
+}
+
+func samePath(a, b string) bool {
+	if a == "" || b == "" {
+		return false
+	}
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	if errA != nil || errB != nil {
+		return a == b
+	}
+	return absA == absB
+}
@@ -1,14 +1,22 @@
 package runner
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/ship-digital/pull-watch/internal/config"
+	"github.com/ship-digital/pull-watch/internal/events"
+	"github.com/ship-digital/pull-watch/internal/limits"
 	"github.com/ship-digital/pull-watch/internal/logger"
+	"github.com/ship-digital/pull-watch/internal/output"
 )
 
 // Processor defines the interface for process management
@@ -23,6 +31,12 @@ type Processor interface {
 	GetLogger() *logger.Logger
 	IsRunning() bool
 	GetPID() int
+	GetStartTime() time.Time
+	SetRevision(commit string)
+	SetDir(dir string)
+	GetDir() string
+	Signal(name string) error
+	GetExitStatus() *events.ExitStatus
 }
 
 var (
@@ -39,18 +53,46 @@ type ProcessManager struct {
 	cfg         *config.Config
 	cmd         *exec.Cmd
 	doneChan    chan struct{}
-	stopped     bool
+	stopped     *atomic.Bool // set once the current process is asked to stop
 	logger      *logger.Logger
 	lastLogTime time.Time
 	backoff     time.Duration
 	pid         int
+	startTime   time.Time
+	revision    string
+	dir         string
+	stdout      io.Writer
+	stderr      io.Writer
+	// cgroup is created by the first start that needs it
+	cgroup *limits.Cgroup
+	// exitStatus is how the last process ended, nil while it runs
+	exitStatus atomic.Pointer[events.ExitStatus]
 }
 
-func New(cfg *config.Config) *ProcessManager {
-	return &ProcessManager{
+// ProcessOption configures a ProcessManager
+type ProcessOption func(*ProcessManager)
+
+// WithOutput sends the command's stdout and stderr to the given writers instead of
+// pull-watch's own. Writers other than os.Stdout and os.Stderr get a separator line
+// on every start, so output can be traced to the commit that produced it.
+func WithOutput(stdout, stderr io.Writer) ProcessOption {
+	return func(pm *ProcessManager) {
+		pm.stdout = stdout
+		pm.stderr = stderr
+	}
+}
+
+func New(cfg *config.Config, opts ...ProcessOption) *ProcessManager {
+	pm := &ProcessManager{
 		cfg:    cfg,
 		logger: cfg.Logger,
+		stdout: os.Stdout,
+		stderr: os.Stderr,
 	}
+	for _, opt := range opts {
+		opt(pm)
+	}
+	return pm
 }
 
 func (pm *ProcessManager) Start() error {
@@ -61,9 +103,11 @@ func (pm *ProcessManager) Start() error {
 	pm.backoff = 0
 	pm.lastLogTime = time.Time{}
 	pm.pid = 0
+	pm.exitStatus.Store(nil)
 
 	// Make sure any previous process is fully cleaned up
 	if pm.cmd != nil {
+		pm.stopped.Store(true)
 		if err := pm.forceStop(); err != nil {
 			pm.logger.MultiColor(logger.QuietLevel,
 				logger.ErrorSegment("Failed to clean up previous process: "),
@@ -73,31 +117,103 @@ func (pm *ProcessManager) Start() error {
 		pm.cmd = nil
 	}
 
-	pm.stopped = false
+	pm.stopped = new(atomic.Bool)
 	pm.doneChan = make(chan struct{})
 	pm.cmd = exec.Command(pm.cfg.Command[0], pm.cfg.Command[1:]...)
-	pm.cmd.Stdout = os.Stdout
-	pm.cmd.Stderr = os.Stderr
 	pm.cmd.Stdin = os.Stdin
+	pm.cmd.Dir = pm.dir
+	if len(pm.cfg.Env) > 0 {
+		pm.cmd.Env = append(os.Environ(), pm.cfg.Env...)
+	}
+
+	// Annotated output is read through pipes, the writers need the PID which is
+	// only known once the process started. They get the format from here: a
+	// reload changes cfg while they run.
+	format := pm.cfg.OutputFormat
+	annotate := format != "" && format != output.FormatRaw
+	if !annotate {
+		pm.cmd.Stdout = pm.stdout
+		pm.cmd.Stderr = pm.stderr
+	}
+	// Children that outlive the command may hold on to the pipes exec copies from
+	pm.cmd.WaitDelay = outputDrainTimeout
+
+	pm.writeSeparator()
 
 	setProcessGroup(pm.cmd)
 
-	if err := pm.cmd.Start(); err != nil {
+	if err := limits.Prepare(pm.cmd, pm.cfg.Limits); err != nil {
+		return err
+	}
+	var cgroup *limits.Cgroup
+	oomKills := 0
+	if pm.cfg.Limits.NeedsCgroup() {
+		if pm.cgroup == nil {
+			created, err := limits.NewCgroup()
+			if err != nil {
+				return err
+			}
+			pm.cgroup = created
+		}
+		cgroup = pm.cgroup
+		if err := cgroup.Set(pm.cfg.Limits); err != nil {
+			return err
+		}
+		cgroup.Attach(pm.cmd)
+		oomKills = cgroup.OOMKills()
+	}
+
+	// Pipes of our own rather than StdoutPipe, which Wait closes: they are read
+	// until drained even when Wait returns first
+	var pipes outputPipes
+	if annotate {
+		if err := pipes.attach(pm.cmd); err != nil {
+			return err
+		}
+	}
+
+	err := pm.cmd.Start()
+	pipes.closeWriters()
+	if err != nil {
+		pipes.closeReaders()
 		return fmt.Errorf("failed to start command: %w", err)
 	}
 
 	pm.pid = pm.cmd.Process.Pid
+	pm.startTime = time.Now()
 
 	pm.logger.MultiColor(logger.DefaultLevel,
 		logger.InfoSegment("Started process with PID "),
 		logger.HighlightSegment(fmt.Sprintf("%d", pm.pid)),
 	)
 
+	var copying sync.WaitGroup
+	if annotate {
+		fields := output.Fields{Commit: events.ShortCommit(pm.revision), PID: pm.pid}
+		copying.Add(2)
+		go pm.copyOutput(&copying, pipes.stdout, pm.stdout, format, fields, "stdout")
+		go pm.copyOutput(&copying, pipes.stderr, pm.stderr, format, fields, "stderr")
+	}
+
+	cmd, done, stopped := pm.cmd, pm.doneChan, pm.stopped
+	pid, startTime := pm.pid, pm.startTime
 	go func() {
-		pm.cmd.Wait()
+		// The command's exit counts, not that of children still holding its output
+		cmd.Wait()
+		pipes.drain(&copying, pm.logger)
+		status := exitStatus(cmd.ProcessState)
+		status.OOMKilled = cgroup != nil && cgroup.OOMKills() > oomKills
+		pm.exitStatus.Store(&status)
+		// Exits nobody asked for are reported by Run
+		if stopped.Load() {
+			logExit(pm.logger, logger.VerboseLevel, pid, status, time.Since(startTime))
+		}
+		// Signal exit before taking the lock: Stop holds it while waiting on done
+		close(done)
 		pm.mu.Lock()
-		close(pm.doneChan)
-		pm.cmd = nil
+		if pm.cmd == cmd {
+			pm.cmd = nil
+		}
 		pm.mu.Unlock()
 	}()
 
@@ -108,16 +224,12 @@ func (pm *ProcessManager) Stop() error {
 	pm.mu.Lock()
 	defer pm.mu.Unlock()
 
-	if !pm.IsRunning() {
+	if !pm.running() {
 		return nil
 	}
 
-	pm.stopped = true
-
-	if pm.cfg.GracefulStop {
-		return pm.gracefulStop(pm.cfg.StopTimeout)
-	}
-	return pm.forceStop()
+	pm.stopped.Store(true)
+	return pm.stopWith(StopSequence(pm.cfg))
 }
 
 func (pm *ProcessManager) GetDoneChan() <-chan struct{} {
@@ -145,6 +257,13 @@ func (pm *ProcessManager) GetLogger() *logger.Logger {
 }
 
 func (pm *ProcessManager) IsRunning() bool {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	return pm.running()
+}
+
+// running is IsRunning for callers already holding the lock
+func (pm *ProcessManager) running() bool {
 	return pm.cmd != nil && pm.cmd.Process != nil
 }
 
@@ -154,35 +273,227 @@ func (pm *ProcessManager) GetPID() int {
 	return pm.pid
 }
 
-func (pm *ProcessManager) gracefulStop(timeout time.Duration) error {
-	if pm.cmd != nil && pm.cmd.Process != nil {
-		pm.logger.MultiColor(logger.DefaultLevel,
-			logger.HighlightSegment("Gracefully"),
-			logger.InfoSegment(" stopping process with PID "),
-			logger.HighlightSegment(fmt.Sprintf("%d", pm.pid)),
-		)
+func (pm *ProcessManager) GetStartTime() time.Time {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	return pm.startTime
+}
+
+// SetRevision sets the commit the next start runs, it is written to the output separators
+func (pm *ProcessManager) SetRevision(commit string) {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	pm.revision = commit
+}
+
+// SetDir sets the directory the next start runs in, empty for pull-watch's own
+func (pm *ProcessManager) SetDir(dir string) {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	pm.dir = dir
+}
+
+// GetDir returns the directory the command was last started in, empty for pull-watch's own
+func (pm *ProcessManager) GetDir() string {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	if pm.cmd == nil {
+		return ""
+	}
+	return pm.cmd.Dir
+}
+
+// GetExitStatus returns how the last process ended, nil while it runs or before the first start
+func (pm *ProcessManager) GetExitStatus() *events.ExitStatus {
+	return pm.exitStatus.Load()
+}
+
+// Close removes the cgroup of the command, once it has been stopped
+func (pm *ProcessManager) Close() error {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	if pm.cgroup == nil {
+		return nil
 	}
-	if err := terminateProcess(pm.cmd); err != nil {
-		return pm.forceStop()
+	err := pm.cgroup.Close()
+	pm.cgroup = nil
+	return err
+}
+
+// Signal sends the named signal to the command itself, not to its process group
+func (pm *ProcessManager) Signal(name string) error {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	if !pm.running() {
+		return os.ErrProcessDone
+	}
+	sig, err := lookupSignal(name)
+	if err != nil {
+		return err
+	}
+	return signalProcess(pm.cmd, sig, true)
+}
+
+// copyOutput annotates the lines read from r and writes them to w
+// outputDrainTimeout bounds the wait for the output of an exited command, children
+// that inherited its stdout or stderr keep the pipes open for as long as they run
+const outputDrainTimeout = time.Second
+
+// outputPipes carry the command's stdout and stderr to the annotating writers
+type outputPipes struct {
+	stdout, stderr *os.File
+	writers        []*os.File
+}
+
+// attach makes cmd write to new pipes
+func (p *outputPipes) attach(cmd *exec.Cmd) error {
+	for _, stream := range []struct {
+		name   string
+		reader **os.File
+		writer *io.Writer
+	}{
+		{"stdout", &p.stdout, &cmd.Stdout},
+		{"stderr", &p.stderr, &cmd.Stderr},
+	} {
+		r, w, err := os.Pipe()
+		if err != nil {
+			p.closeWriters()
+			p.closeReaders()
+			return fmt.Errorf("failed to create %s pipe: %w", stream.name, err)
+		}
+		*stream.reader = r
+		*stream.writer = w
+		p.writers = append(p.writers, w)
 	}
+	return nil
+}
+
+// closeWriters closes pull-watch's copies of the write ends, once the command has its own
+func (p *outputPipes) closeWriters() {
+	for _, w := range p.writers {
+		w.Close()
+	}
+	p.writers = nil
+}
+
+func (p *outputPipes) closeReaders() {
+	for _, r := range []*os.File{p.stdout, p.stderr} {
+		if r != nil {
+			r.Close()
+		}
+	}
+}
 
+// drain waits for copying to reach the end of the pipes, at most outputDrainTimeout,
+// then closes them so copying returns even while other processes hold them open
+func (p *outputPipes) drain(copying *sync.WaitGroup, log *logger.Logger) {
+	if p.stdout == nil {
+		return
+	}
+	drained := make(chan struct{})
+	go func() {
+		copying.Wait()
+		close(drained)
+	}()
 	select {
-	case <-pm.doneChan:
-		return nil
-	case <-time.After(timeout):
-		return pm.forceStop()
+	case <-drained:
+	case <-time.After(outputDrainTimeout):
+		log.Debug("Command output is still open after it exited, a child process holds it")
 	}
+	p.closeReaders()
+	<-drained
+}
+
+func (pm *ProcessManager) copyOutput(wg *sync.WaitGroup, r io.Reader, w io.Writer, format output.Format, fields output.Fields, stream string) {
+	defer wg.Done()
+	fields.Stream = stream
+	lines := output.NewWriter(w, format, fields)
+	if _, err := io.Copy(lines, r); err != nil {
+		pm.logger.Debug("Failed to copy command %s: %v", stream, err)
+	}
+	if err := lines.Close(); err != nil {
+		pm.logger.Debug("Failed to flush command %s: %v", stream, err)
+	}
+}
+
+// writeSeparator marks the start of a new run in output files
+func (pm *ProcessManager) writeSeparator() {
+	line := fmt.Sprintf("\n===== %s pull-watch: starting %q",
+		time.Now().Format(time.RFC3339), strings.Join(pm.cfg.Command, " "))
+	if pm.revision != "" {
+		line += " at " + pm.revision
+	}
+	line += " =====\n"
+
+	var written []io.Writer
+	for _, w := range []io.Writer{pm.stdout, pm.stderr} {
+		if w == nil || w == io.Writer(os.Stdout) || w == io.Writer(os.Stderr) || containsWriter(written, w) {
+			continue
+		}
+		if _, err := io.WriteString(w, line); err != nil {
+			pm.logger.Warn("Failed to write output separator: %v", err)
+		}
+		written = append(written, w)
+	}
+}
+
+func containsWriter(writers []io.Writer, w io.Writer) bool {
+	for _, other := range writers {
+		if other == w {
+			return true
+		}
+	}
+	return false
+}
+
+// stopWith sends the signal of each step in turn, moving on to the next one
+// when the process outlives the step's timeout
+func (pm *ProcessManager) stopWith(steps []config.StopStep) error {
+	for _, step := range steps {
+		if step.Signal == killSignal {
+			break
+		}
+		sig, err := lookupSignal(step.Signal)
+		if err != nil {
+			return pm.forceStop()
+		}
+
+		if pm.cmd != nil && pm.cmd.Process != nil {
+			pm.logger.MultiColor(logger.DefaultLevel,
+				logger.HighlightSegment("Gracefully"),
+				logger.InfoSegment(" stopping process with PID "),
+				logger.HighlightSegment(fmt.Sprintf("%d", pm.pid)),
+				logger.InfoSegment(" ("),
+				logger.HighlightSegment(step.Signal),
+				logger.InfoSegment(fmt.Sprintf(", waiting %s)", step.Timeout)),
+			)
+		}
+		if err := signalProcess(pm.cmd, sig, pm.cfg.SignalLeader); err != nil {
+			if errors.Is(err, os.ErrProcessDone) || errors.Is(err, syscall.ESRCH) {
+				return nil
+			}
+			return pm.forceStop()
+		}
+
+		select {
+		case <-pm.doneChan:
+			return nil
+		case <-time.After(step.Timeout):
+		}
+	}
+	return pm.forceStop()
 }
 
 func (pm *ProcessManager) forceStop() error {
-	if pm.IsRunning() {
+	if pm.running() {
 		pm.logger.MultiColor(logger.DefaultLevel,
 			logger.HighlightSegment("Force"),
 			logger.InfoSegment(" killing process with PID "),
 			logger.HighlightSegment(fmt.Sprintf("%d", pm.pid)),
 		)
-		err := killProcess(pm.cmd)
-		if err != nil && (err.Error() == "os: process already finished" || err.Error() == "process already finished") {
+		err := killProcess(pm.cmd, pm.cfg.SignalLeader)
+		if err != nil && (errors.Is(err, os.ErrProcessDone) || errors.Is(err, syscall.ESRCH)) {
 			return nil
 		}
 		pm.pid = 0
@@ -3,10 +3,41 @@
 package runner
 
 import (
+	"fmt"
+	"os"
 	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
 	"syscall"
+
+	"github.com/ship-digital/pull-watch/internal/events"
 )
 
+// signals are the signals known by name, the ones a stop sequence can send
+// and the ones commands usually die of
+var signals = map[string]syscall.Signal{
+	"SIGHUP":   syscall.SIGHUP,
+	"SIGINT":   syscall.SIGINT,
+	"SIGQUIT":  syscall.SIGQUIT,
+	"SIGILL":   syscall.SIGILL,
+	"SIGTRAP":  syscall.SIGTRAP,
+	"SIGABRT":  syscall.SIGABRT,
+	"SIGBUS":   syscall.SIGBUS,
+	"SIGFPE":   syscall.SIGFPE,
+	"SIGKILL":  syscall.SIGKILL,
+	"SIGUSR1":  syscall.SIGUSR1,
+	"SIGSEGV":  syscall.SIGSEGV,
+	"SIGUSR2":  syscall.SIGUSR2,
+	"SIGPIPE":  syscall.SIGPIPE,
+	"SIGALRM":  syscall.SIGALRM,
+	"SIGTERM":  syscall.SIGTERM,
+	"SIGCONT":  syscall.SIGCONT,
+	"SIGXCPU":  syscall.SIGXCPU,
+	"SIGXFSZ":  syscall.SIGXFSZ,
+	"SIGWINCH": syscall.SIGWINCH,
+}
+
 func setProcessGroup(cmd *exec.Cmd) {
 	// Set process group so child processes get signals
 	cmd.SysProcAttr = &syscall.SysProcAttr{
@@ -14,18 +45,61 @@ func setProcessGroup(cmd *exec.Cmd) {
 	}
 }
 
-func terminateProcess(cmd *exec.Cmd) error {
-	if cmd.Process == nil {
-		return nil
+// lookupSignal returns the signal called name, in its SIGNAME form or as a number
+func lookupSignal(name string) (syscall.Signal, error) {
+	if sig, ok := signals[name]; ok {
+		return sig, nil
 	}
-	// Send SIGTERM to process group instead of just the process
-	return syscall.Kill(-cmd.Process.Pid, syscall.SIGTERM)
+	if n, err := strconv.Atoi(strings.TrimPrefix(name, "SIG")); err == nil && n > 0 {
+		return syscall.Signal(n), nil
+	}
+	return 0, fmt.Errorf("unknown signal %q", name)
 }
 
-func killProcess(cmd *exec.Cmd) error {
+// signalProcess sends sig to the command, or to its whole process group unless leaderOnly is set
+func signalProcess(cmd *exec.Cmd, sig syscall.Signal, leaderOnly bool) error {
 	if cmd.Process == nil {
 		return nil
 	}
-	// Send SIGKILL to process group
-	return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	if leaderOnly {
+		return syscall.Kill(cmd.Process.Pid, sig)
+	}
+	// A negative PID addresses the process group
+	return syscall.Kill(-cmd.Process.Pid, sig)
+}
+
+func killProcess(cmd *exec.Cmd, leaderOnly bool) error {
+	return signalProcess(cmd, syscall.SIGKILL, leaderOnly)
+}
+
+// exitStatus reads the exit code, signal and resource usage of an exited command
+func exitStatus(state *os.ProcessState) events.ExitStatus {
+	status := events.ExitStatus{
+		Code:        state.ExitCode(),
+		UserCPUMS:   state.UserTime().Milliseconds(),
+		SystemCPUMS: state.SystemTime().Milliseconds(),
+	}
+	if ws, ok := state.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		status.Code = 128 + int(ws.Signal())
+		status.Signal = signalName(ws.Signal())
+		status.CoreDumped = ws.CoreDump()
+	}
+	if usage, ok := state.SysUsage().(*syscall.Rusage); ok {
+		status.MaxRSSKB = int64(usage.Maxrss)
+		// macOS reports bytes, everyone else kilobytes
+		if runtime.GOOS == "darwin" {
+			status.MaxRSSKB /= 1024
+		}
+	}
+	return status
+}
+
+// signalName returns the SIGNAME form of sig, or SIG and its number for unknown ones
+func signalName(sig syscall.Signal) string {
+	for name, known := range signals {
+		if known == sig {
+			return name
+		}
+	}
+	return fmt.Sprintf("SIG%d", int(sig))
 }
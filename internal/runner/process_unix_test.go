@@ -0,0 +1,233 @@
+//go:build !windows
+
+package runner
+
+import (
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/ship-digital/pull-watch/internal/config"
+	"github.com/ship-digital/pull-watch/internal/events"
+	"github.com/ship-digital/pull-watch/internal/logger"
+)
+
+// Environment of the signal recording helper process
+const (
+	helperRecordEnv = "PULL_WATCH_HELPER_RECORD"
+	helperChildEnv  = "PULL_WATCH_HELPER_CHILD"
+)
+
+// TestHelperProcess isn't a real test. It's the command stopped by the tests below:
+// it writes "ready" and then the name of every signal it gets to a file, and
+// optionally starts a second copy of itself in the same process group.
+func TestHelperProcess(t *testing.T) {
+	record := os.Getenv(helperRecordEnv)
+	if record == "" {
+		return
+	}
+
+	f, err := os.OpenFile(record, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		os.Exit(2)
+	}
+	received := make(chan os.Signal, 10)
+	signal.Notify(received, syscall.SIGHUP, syscall.SIGINT, syscall.SIGQUIT, syscall.SIGTERM, syscall.SIGUSR1, syscall.SIGWINCH)
+
+	if childRecord := os.Getenv(helperChildEnv); childRecord != "" {
+		child := exec.Command(os.Args[0], "-test.run=TestHelperProcess")
+		child.Env = append(os.Environ(), helperRecordEnv+"="+childRecord, helperChildEnv+"=")
+		if err := child.Start(); err != nil {
+			os.Exit(2)
+		}
+		waitForLines(childRecord, 1, 5*time.Second)
+	}
+
+	f.WriteString("ready\n")
+	// Never exit on a signal, only SIGKILL (or the test giving up) ends the helper
+	deadline := time.After(10 * time.Second)
+	for {
+		select {
+		case sig := <-received:
+			f.WriteString(strings.ToUpper(strings.ReplaceAll(sig.String(), " ", "")) + "\n")
+		case <-deadline:
+			os.Exit(0)
+		}
+	}
+}
+
+// waitForLines waits until the file at path holds n lines and returns them
+func waitForLines(path string, n int, timeout time.Duration) []string {
+	deadline := time.Now().Add(timeout)
+	for {
+		data, _ := os.ReadFile(path)
+		lines := strings.Fields(string(data))
+		if len(lines) >= n || time.Now().After(deadline) {
+			return lines
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func startHelper(t *testing.T, cfg *config.Config, record, childRecord string) *ProcessManager {
+	t.Helper()
+	cfg.Command = []string{os.Args[0], "-test.run=TestHelperProcess"}
+	cfg.Env = []string{helperRecordEnv + "=" + record, helperChildEnv + "=" + childRecord}
+	cfg.Logger = logger.New(logger.WithLogLevel(logger.QuietLevel))
+
+	pm := New(cfg)
+	if err := pm.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	// Clean up whatever is left of the process group
+	pid := pm.GetPID()
+	t.Cleanup(func() { syscall.Kill(-pid, syscall.SIGKILL) })
+
+	if lines := waitForLines(record, 1, 5*time.Second); len(lines) == 0 {
+		t.Fatal("helper process didn't get ready")
+	}
+	return pm
+}
+
+func TestProcessManager_StopSequence(t *testing.T) {
+	steps, err := ParseStopSequence("int:200ms, SIGQUIT:200ms,TERM:200ms,SIGKILL")
+	if err != nil {
+		t.Fatalf("ParseStopSequence() error = %v", err)
+	}
+
+	record := filepath.Join(t.TempDir(), "signals")
+	pm := startHelper(t, &config.Config{StopSequence: steps}, record, "")
+
+	start := time.Now()
+	if err := pm.Stop(); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+	select {
+	case <-pm.GetDoneChan():
+	case <-time.After(5 * time.Second):
+		t.Fatal("process not stopped")
+	}
+	if elapsed := time.Since(start); elapsed < 600*time.Millisecond {
+		t.Errorf("Stop() returned after %s, before the sequence ran out", elapsed)
+	}
+
+	want := []string{"ready", "INTERRUPT", "QUIT", "TERMINATED"}
+	if got := waitForLines(record, len(want), time.Second); !reflect.DeepEqual(got, want) {
+		t.Errorf("helper received %v, want %v", got, want)
+	}
+}
+
+func TestProcessManager_StopSignalGroup(t *testing.T) {
+	for _, leaderOnly := range []bool{false, true} {
+		dir := t.TempDir()
+		record, childRecord := filepath.Join(dir, "leader"), filepath.Join(dir, "child")
+		pm := startHelper(t, &config.Config{
+			StopSignal:   "SIGWINCH",
+			StopTimeout:  200 * time.Millisecond,
+			SignalLeader: leaderOnly,
+		}, record, childRecord)
+
+		if err := pm.Stop(); err != nil {
+			t.Fatalf("Stop() error = %v", err)
+		}
+
+		if got := waitForLines(record, 2, time.Second); !reflect.DeepEqual(got, []string{"ready", "WINDOWCHANGED"}) {
+			t.Errorf("leaderOnly=%v: leader received %v", leaderOnly, got)
+		}
+		want := []string{"ready", "WINDOWCHANGED"}
+		if leaderOnly {
+			want = []string{"ready"}
+		}
+		if got := waitForLines(childRecord, len(want), 500*time.Millisecond); !reflect.DeepEqual(got, want) {
+			t.Errorf("leaderOnly=%v: child received %v, want %v", leaderOnly, got, want)
+		}
+	}
+}
+
+func TestProcessManager_ExitStatus(t *testing.T) {
+	tests := []struct {
+		script string
+		want   events.ExitStatus
+	}{
+		{script: "exit 0", want: events.ExitStatus{Code: 0}},
+		{script: "exit 42", want: events.ExitStatus{Code: 42}},
+		{script: "kill -USR1 $$", want: events.ExitStatus{Code: 128 + int(syscall.SIGUSR1), Signal: "SIGUSR1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.script, func(t *testing.T) {
+			pm := New(&config.Config{
+				Command: []string{"sh", "-c", tt.script},
+				Logger:  logger.New(logger.WithLogLevel(logger.QuietLevel)),
+			})
+			if err := pm.Start(); err != nil {
+				t.Fatalf("Start() error = %v", err)
+			}
+			if pm.GetExitStatus() != nil {
+				t.Error("GetExitStatus() should be nil while the process runs")
+			}
+			select {
+			case <-pm.GetDoneChan():
+			case <-time.After(5 * time.Second):
+				t.Fatal("process didn't exit")
+			}
+
+			got := pm.GetExitStatus()
+			if got == nil {
+				t.Fatal("GetExitStatus() = nil after exit")
+			}
+			if got.Code != tt.want.Code || got.Signal != tt.want.Signal || got.OOMKilled {
+				t.Errorf("GetExitStatus() = %+v, want %+v", *got, tt.want)
+			}
+			if got.MaxRSSKB <= 0 {
+				t.Errorf("MaxRSSKB = %d, want the peak memory use", got.MaxRSSKB)
+			}
+		})
+	}
+}
+
+func TestParseStopSequence(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    []config.StopStep
+		wantErr bool
+	}{
+		{spec: "SIGINT:10s,SIGTERM:5s,SIGKILL", want: []config.StopStep{
+			{Signal: "SIGINT", Timeout: 10 * time.Second},
+			{Signal: "SIGTERM", Timeout: 5 * time.Second},
+			{Signal: "SIGKILL"},
+		}},
+		{spec: "quit:30s", want: []config.StopStep{{Signal: "SIGQUIT", Timeout: 30 * time.Second}}},
+		{spec: "SIGINT,SIGKILL", wantErr: true},
+		{spec: "SIGKILL,SIGTERM:5s", wantErr: true},
+		{spec: "SIGNOPE:5s", wantErr: true},
+		{spec: "SIGTERM:soon", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := ParseStopSequence(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseStopSequence() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseStopSequence() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// The effective sequence always ends with SIGKILL
+	steps := StopSequence(&config.Config{StopSequence: []config.StopStep{{Signal: "SIGQUIT", Timeout: time.Second}}})
+	if last := steps[len(steps)-1]; last.Signal != "SIGKILL" {
+		t.Errorf("StopSequence() ends with %s", last.Signal)
+	}
+	if got := StopSequence(&config.Config{}); len(got) != 1 || got[0].Signal != "SIGKILL" {
+		t.Errorf("StopSequence() without graceful stop = %v", got)
+	}
+}
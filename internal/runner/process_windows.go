@@ -4,8 +4,11 @@ package runner
 
 import (
 	"fmt"
+	"os"
 	"os/exec"
 	"syscall"
+
+	"github.com/ship-digital/pull-watch/internal/events"
 )
 
 func setProcessGroup(cmd *exec.Cmd) {
@@ -14,15 +17,44 @@ func setProcessGroup(cmd *exec.Cmd) {
 	}
 }
 
-// Windows specific process termination
-func terminateProcess(cmd *exec.Cmd) error {
-	// On Windows, we'll use taskkill /T to terminate the process tree
-	kill := exec.Command("taskkill", "/T", "/PID", fmt.Sprint(cmd.Process.Pid))
-	return kill.Run()
+// lookupSignal returns the signal called name. Windows has no signals, SIGTERM
+// asks the process to close and SIGKILL terminates it.
+func lookupSignal(name string) (syscall.Signal, error) {
+	switch name {
+	case "SIGTERM":
+		return syscall.SIGTERM, nil
+	case "SIGKILL":
+		return syscall.SIGKILL, nil
+	}
+	return 0, fmt.Errorf("signal %q isn't supported on Windows (only SIGTERM and SIGKILL)", name)
+}
+
+// signalProcess asks the command to close, or force kills it for SIGKILL.
+// The whole process tree is addressed unless leaderOnly is set.
+func signalProcess(cmd *exec.Cmd, sig syscall.Signal, leaderOnly bool) error {
+	if cmd.Process == nil {
+		return nil
+	}
+	args := []string{"/PID", fmt.Sprint(cmd.Process.Pid)}
+	if !leaderOnly {
+		args = append([]string{"/T"}, args...)
+	}
+	if sig == syscall.SIGKILL {
+		args = append([]string{"/F"}, args...)
+	}
+	return exec.Command("taskkill", args...).Run()
+}
+
+func killProcess(cmd *exec.Cmd, leaderOnly bool) error {
+	return signalProcess(cmd, syscall.SIGKILL, leaderOnly)
 }
 
-func killProcess(cmd *exec.Cmd) error {
-	// Force kill with /F flag
-	kill := exec.Command("taskkill", "/F", "/T", "/PID", fmt.Sprint(cmd.Process.Pid))
-	return kill.Run()
+// exitStatus reads the exit code and CPU time of an exited command, Windows
+// has no signals and doesn't report the peak memory use
+func exitStatus(state *os.ProcessState) events.ExitStatus {
+	return events.ExitStatus{
+		Code:        state.ExitCode(),
+		UserCPUMS:   state.UserTime().Milliseconds(),
+		SystemCPUMS: state.SystemTime().Milliseconds(),
+	}
 }
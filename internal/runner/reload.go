@@ -0,0 +1,314 @@
+package runner
+
+import (
+	"fmt"
+	"log"
+	"reflect"
+	"strings"
+
+	"github.com/ship-digital/pull-watch/internal/config"
+	"github.com/ship-digital/pull-watch/internal/limits"
+	"github.com/ship-digital/pull-watch/internal/logger"
+)
+
+// effect is what it takes for a changed setting to be applied
+type effect int
+
+const (
+	// reloadLive settings are applied while the command keeps running
+	reloadLive effect = iota
+	// reloadRestartsCommand settings are applied by restarting the command
+	reloadRestartsCommand
+	// reloadNeedsRestart settings only apply once pull-watch itself is restarted
+	reloadNeedsRestart
+)
+
+// setting is a configuration value that can change on reload, named after its flag
+type setting struct {
+	name   string
+	effect effect
+	value  func(cfg *config.Config) interface{}
+	// apply copies the value from next to cfg, nil for reloadNeedsRestart settings
+	apply func(cfg, next *config.Config)
+}
+
+var settings = []setting{
+	{"interval", reloadLive,
+		func(c *config.Config) interface{} { return c.PollInterval },
+		func(cfg, next *config.Config) { cfg.PollInterval = next.PollInterval }},
+	{"log-level", reloadLive,
+		func(c *config.Config) interface{} { return c.LogLevel },
+		func(cfg, next *config.Config) {
+			cfg.LogLevel = next.LogLevel
+			cfg.Logger.SetLevel(next.LogLevel)
+		}},
+	{"timestamp", reloadLive,
+		func(c *config.Config) interface{} { return c.ShowTimestamp },
+		func(cfg, next *config.Config) {
+			cfg.ShowTimestamp = next.ShowTimestamp
+			if next.ShowTimestamp {
+				cfg.Logger.SetFlags(log.LstdFlags)
+			} else {
+				cfg.Logger.SetFlags(0)
+			}
+		}},
+	{"graceful", reloadLive,
+		func(c *config.Config) interface{} { return c.GracefulStop },
+		func(cfg, next *config.Config) { cfg.GracefulStop = next.GracefulStop }},
+	{"stop-timeout", reloadLive,
+		func(c *config.Config) interface{} { return c.StopTimeout },
+		func(cfg, next *config.Config) { cfg.StopTimeout = next.StopTimeout }},
+	{"stop-signal", reloadLive,
+		func(c *config.Config) interface{} { return c.StopSignal },
+		func(cfg, next *config.Config) { cfg.StopSignal = next.StopSignal }},
+	{"stop-sequence", reloadLive,
+		func(c *config.Config) interface{} { return describeStopSequence(c.StopSequence) },
+		func(cfg, next *config.Config) { cfg.StopSequence = next.StopSequence }},
+	{"signal-group", reloadLive,
+		func(c *config.Config) interface{} { return !c.SignalLeader },
+		func(cfg, next *config.Config) { cfg.SignalLeader = next.SignalLeader }},
+	{"reload-signal", reloadLive,
+		func(c *config.Config) interface{} { return c.ReloadSignal },
+		func(cfg, next *config.Config) { cfg.ReloadSignal = next.ReloadSignal }},
+	{"reload-check", reloadLive,
+		func(c *config.Config) interface{} { return c.ReloadCheck },
+		func(cfg, next *config.Config) { cfg.ReloadCheck = next.ReloadCheck }},
+	{"reload-timeout", reloadLive,
+		func(c *config.Config) interface{} { return c.ReloadTimeout },
+		func(cfg, next *config.Config) { cfg.ReloadTimeout = next.ReloadTimeout }},
+	{"git-ssh-key", reloadLive,
+		func(c *config.Config) interface{} { return c.Auth.SSHKey },
+		func(cfg, next *config.Config) { cfg.Auth.SSHKey = next.Auth.SSHKey }},
+	{"git-known-hosts", reloadLive,
+		func(c *config.Config) interface{} { return c.Auth.KnownHosts },
+		func(cfg, next *config.Config) { cfg.Auth.KnownHosts = next.Auth.KnownHosts }},
+	{"git-token-env", reloadLive,
+		func(c *config.Config) interface{} { return c.Auth.TokenEnv },
+		func(cfg, next *config.Config) { cfg.Auth.TokenEnv = next.Auth.TokenEnv }},
+	{"git-token-file", reloadLive,
+		func(c *config.Config) interface{} { return c.Auth.TokenFile },
+		func(cfg, next *config.Config) { cfg.Auth.TokenFile = next.Auth.TokenFile }},
+	{"git-token-user", reloadLive,
+		func(c *config.Config) interface{} { return c.Auth.TokenUser },
+		func(cfg, next *config.Config) { cfg.Auth.TokenUser = next.Auth.TokenUser }},
+	{"ls-remote-timeout", reloadLive,
+		func(c *config.Config) interface{} { return c.GitTimeouts.LsRemote },
+		func(cfg, next *config.Config) { cfg.GitTimeouts.LsRemote = next.GitTimeouts.LsRemote }},
+	{"fetch-timeout", reloadLive,
+		func(c *config.Config) interface{} { return c.GitTimeouts.Fetch },
+		func(cfg, next *config.Config) { cfg.GitTimeouts.Fetch = next.GitTimeouts.Fetch }},
+	{"pull-timeout", reloadLive,
+		func(c *config.Config) interface{} { return c.GitTimeouts.Pull },
+		func(cfg, next *config.Config) { cfg.GitTimeouts.Pull = next.GitTimeouts.Pull }},
+	{"lfs-timeout", reloadLive,
+		func(c *config.Config) interface{} { return c.GitTimeouts.LFS },
+		func(cfg, next *config.Config) { cfg.GitTimeouts.LFS = next.GitTimeouts.LFS }},
+	{"git-retries", reloadLive,
+		func(c *config.Config) interface{} { return c.GitRetries },
+		func(cfg, next *config.Config) { cfg.GitRetries = next.GitRetries }},
+	{"git-retry-backoff", reloadLive,
+		func(c *config.Config) interface{} { return c.RetryBackoff },
+		func(cfg, next *config.Config) { cfg.RetryBackoff = next.RetryBackoff }},
+	{"max-interval", reloadLive,
+		func(c *config.Config) interface{} { return c.MaxInterval },
+		func(cfg, next *config.Config) { cfg.MaxInterval = next.MaxInterval }},
+	{"stale-lock-age", reloadLive,
+		func(c *config.Config) interface{} { return c.StaleLockAge },
+		func(cfg, next *config.Config) { cfg.StaleLockAge = next.StaleLockAge }},
+	{"pull-strategy", reloadLive,
+		func(c *config.Config) interface{} { return c.PullStrategy },
+		func(cfg, next *config.Config) { cfg.PullStrategy = next.PullStrategy }},
+	{"submodules", reloadLive,
+		func(c *config.Config) interface{} { return c.Submodules.Mode },
+		func(cfg, next *config.Config) { cfg.Submodules.Mode = next.Submodules.Mode }},
+	{"submodule-remote", reloadLive,
+		func(c *config.Config) interface{} { return c.Submodules.Remote },
+		func(cfg, next *config.Config) { cfg.Submodules.Remote = next.Submodules.Remote }},
+	{"detect", reloadLive,
+		func(c *config.Config) interface{} { return c.Detection.Strategy },
+		func(cfg, next *config.Config) { cfg.Detection.Strategy = next.Detection.Strategy }},
+	{"fetch-depth", reloadLive,
+		func(c *config.Config) interface{} { return c.Detection.Depth },
+		func(cfg, next *config.Config) { cfg.Detection.Depth = next.Detection.Depth }},
+	{"fetch-filter", reloadLive,
+		func(c *config.Config) interface{} { return c.Detection.Filter },
+		func(cfg, next *config.Config) { cfg.Detection.Filter = next.Detection.Filter }},
+	{"build", reloadLive,
+		func(c *config.Config) interface{} { return c.Deploy.Build },
+		func(cfg, next *config.Config) { cfg.Deploy.Build = next.Deploy.Build }},
+	{"build-timeout", reloadLive,
+		func(c *config.Config) interface{} { return c.Deploy.BuildTimeout },
+		func(cfg, next *config.Config) { cfg.Deploy.BuildTimeout = next.Deploy.BuildTimeout }},
+	{"deploy-keep", reloadLive,
+		func(c *config.Config) interface{} { return c.Deploy.Keep },
+		func(cfg, next *config.Config) { cfg.Deploy.Keep = next.Deploy.Keep }},
+	{"lfs", reloadLive,
+		func(c *config.Config) interface{} { return c.LFS },
+		func(cfg, next *config.Config) { cfg.LFS = next.LFS }},
+	{"exit-with-child", reloadLive,
+		func(c *config.Config) interface{} { return c.ExitWithChild },
+		func(cfg, next *config.Config) { cfg.ExitWithChild = next.ExitWithChild }},
+	{"no-restart", reloadLive,
+		func(c *config.Config) interface{} { return c.NoRestart },
+		func(cfg, next *config.Config) { cfg.NoRestart = next.NoRestart }},
+	{"watch", reloadLive,
+		func(c *config.Config) interface{} { return c.WatchTree },
+		func(cfg, next *config.Config) { cfg.WatchTree = next.WatchTree }},
+	{"watch-include", reloadLive,
+		func(c *config.Config) interface{} { return c.WatchInclude },
+		func(cfg, next *config.Config) { cfg.WatchInclude = next.WatchInclude }},
+	{"watch-exclude", reloadLive,
+		func(c *config.Config) interface{} { return c.WatchExclude },
+		func(cfg, next *config.Config) { cfg.WatchExclude = next.WatchExclude }},
+	{"watch-debounce", reloadLive,
+		func(c *config.Config) interface{} { return c.WatchDebounce },
+		func(cfg, next *config.Config) { cfg.WatchDebounce = next.WatchDebounce }},
+	{"notify", reloadLive,
+		func(c *config.Config) interface{} { return c.NotifyURLs },
+		func(cfg, next *config.Config) { cfg.NotifyURLs = next.NotifyURLs }},
+	{"notify-events", reloadLive,
+		func(c *config.Config) interface{} { return c.NotifyEvents },
+		func(cfg, next *config.Config) { cfg.NotifyEvents = next.NotifyEvents }},
+	{"notify-template", reloadLive,
+		func(c *config.Config) interface{} { return c.NotifyTemplate },
+		func(cfg, next *config.Config) { cfg.NotifyTemplate = next.NotifyTemplate }},
+	{"output-format", reloadLive,
+		func(c *config.Config) interface{} { return c.OutputFormat },
+		func(cfg, next *config.Config) { cfg.OutputFormat = next.OutputFormat }},
+	{"command", reloadRestartsCommand,
+		func(c *config.Config) interface{} { return c.Command },
+		func(cfg, next *config.Config) { cfg.Command = next.Command }},
+	{"env", reloadRestartsCommand,
+		func(c *config.Config) interface{} { return c.Env },
+		func(cfg, next *config.Config) { cfg.Env = next.Env }},
+	{"limit-memory", reloadRestartsCommand,
+		func(c *config.Config) interface{} { return limits.FormatSize(c.Limits.Memory) },
+		func(cfg, next *config.Config) { cfg.Limits.Memory = next.Limits.Memory }},
+	{"limit-nofile", reloadRestartsCommand,
+		func(c *config.Config) interface{} { return c.Limits.OpenFiles },
+		func(cfg, next *config.Config) { cfg.Limits.OpenFiles = next.Limits.OpenFiles }},
+	{"limit-cpu", reloadRestartsCommand,
+		func(c *config.Config) interface{} { return c.Limits.CPUTime },
+		func(cfg, next *config.Config) { cfg.Limits.CPUTime = next.Limits.CPUTime }},
+	{"run-as", reloadRestartsCommand,
+		func(c *config.Config) interface{} { return c.Limits.RunAs },
+		func(cfg, next *config.Config) { cfg.Limits.RunAs = next.Limits.RunAs }},
+	{"nice", reloadRestartsCommand,
+		func(c *config.Config) interface{} { return c.Limits.Nice },
+		func(cfg, next *config.Config) { cfg.Limits.Nice = next.Limits.Nice }},
+	{"ionice", reloadRestartsCommand,
+		func(c *config.Config) interface{} { return fmt.Sprintf("%s:%d", c.Limits.IOClass, c.Limits.IOLevel) },
+		func(cfg, next *config.Config) {
+			cfg.Limits.IOClass, cfg.Limits.IOLevel = next.Limits.IOClass, next.Limits.IOLevel
+		}},
+	{"cgroup-memory-max", reloadRestartsCommand,
+		func(c *config.Config) interface{} { return limits.FormatSize(c.Limits.CgroupMemoryMax) },
+		func(cfg, next *config.Config) { cfg.Limits.CgroupMemoryMax = next.Limits.CgroupMemoryMax }},
+	{"cgroup-cpu-max", reloadRestartsCommand,
+		func(c *config.Config) interface{} { return c.Limits.CgroupCPUMax },
+		func(cfg, next *config.Config) { cfg.Limits.CgroupCPUMax = next.Limits.CgroupCPUMax }},
+	{"mode", reloadNeedsRestart, func(c *config.Config) interface{} { return c.Mode }, nil},
+	{"git-dir", reloadNeedsRestart, func(c *config.Config) interface{} { return c.GitDir }, nil},
+	{"deploy", reloadNeedsRestart, func(c *config.Config) interface{} { return c.Deploy.Mode }, nil},
+	{"deploy-dir", reloadNeedsRestart, func(c *config.Config) interface{} { return c.Deploy.Dir }, nil},
+	{"sparse", reloadNeedsRestart, func(c *config.Config) interface{} { return c.Sparse }, nil},
+	{"dry-run", reloadNeedsRestart, func(c *config.Config) interface{} { return c.DryRun }, nil},
+	{"no-history", reloadNeedsRestart, func(c *config.Config) interface{} { return !c.History }, nil},
+	{"history-file", reloadNeedsRestart, func(c *config.Config) interface{} { return c.HistoryFile }, nil},
+	{"history-max-mb", reloadNeedsRestart, func(c *config.Config) interface{} { return c.HistoryMaxMB }, nil},
+	{"history-keep", reloadNeedsRestart, func(c *config.Config) interface{} { return c.HistoryKeep }, nil},
+	{"stdout-file", reloadNeedsRestart, func(c *config.Config) interface{} { return c.StdoutFile }, nil},
+	{"stderr-file", reloadNeedsRestart, func(c *config.Config) interface{} { return c.StderrFile }, nil},
+	{"log-max-mb", reloadNeedsRestart, func(c *config.Config) interface{} { return c.LogMaxMB }, nil},
+	{"log-max-age", reloadNeedsRestart, func(c *config.Config) interface{} { return c.LogMaxAge }, nil},
+	{"log-keep", reloadNeedsRestart, func(c *config.Config) interface{} { return c.LogKeep }, nil},
+	{"log-no-compress", reloadNeedsRestart, func(c *config.Config) interface{} { return !c.LogCompress }, nil},
+}
+
+// configChange is a setting that differs between the running and the reloaded configuration
+type configChange struct {
+	setting  *setting
+	from, to interface{}
+}
+
+// diffConfig lists the settings that differ between cfg and next
+func diffConfig(cfg, next *config.Config) []configChange {
+	var changes []configChange
+	for i := range settings {
+		s := &settings[i]
+		from, to := s.value(cfg), s.value(next)
+		if !reflect.DeepEqual(from, to) {
+			changes = append(changes, configChange{setting: s, from: from, to: to})
+		}
+	}
+	return changes
+}
+
+// applyConfig copies the changed settings that can be applied without restarting
+// pull-watch from next to cfg, and reports whether the command has to be restarted
+func applyConfig(cfg, next *config.Config, changes []configChange) bool {
+	restart := false
+	for _, change := range changes {
+		if change.setting.apply == nil {
+			continue
+		}
+		change.setting.apply(cfg, next)
+		restart = restart || change.setting.effect == reloadRestartsCommand
+	}
+	return restart
+}
+
+// changed reports whether any of the named settings is in changes
+func changed(changes []configChange, names ...string) bool {
+	for _, change := range changes {
+		for _, name := range names {
+			if change.setting.name == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// logConfigChanges logs what a reload changed and what it couldn't
+func logConfigChanges(cfg *config.Config, changes []configChange) {
+	if len(changes) == 0 {
+		cfg.Logger.MultiColor(logger.DefaultLevel,
+			logger.InfoSegment("Configuration reloaded, nothing changed"),
+		)
+		return
+	}
+
+	cfg.Logger.MultiColor(logger.DefaultLevel,
+		logger.InfoSegment("Configuration reloaded:"),
+	)
+	for _, change := range changes {
+		segments := []logger.ColoredSegment{
+			logger.InfoSegment("  " + change.setting.name + ": "),
+			logger.HighlightSegment(formatSetting(change.from)),
+			logger.InfoSegment(" -> "),
+			logger.HighlightSegment(formatSetting(change.to)),
+		}
+		if change.setting.effect == reloadNeedsRestart {
+			segments = append(segments, logger.ErrorSegment(" (restart pull-watch to apply)"))
+		}
+		cfg.Logger.MultiColor(logger.DefaultLevel, segments...)
+	}
+}
+
+func formatSetting(v interface{}) string {
+	switch v := v.(type) {
+	case []string:
+		if len(v) == 0 {
+			return "(none)"
+		}
+		return strings.Join(v, " ")
+	case string:
+		if v == "" {
+			return `""`
+		}
+		return fmt.Sprintf("%q", v)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
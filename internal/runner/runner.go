@@ -6,14 +6,22 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"strings"
 	"syscall"
 	"time"
 
 	"github.com/ship-digital/pull-watch/internal/config"
+	"github.com/ship-digital/pull-watch/internal/control"
 	"github.com/ship-digital/pull-watch/internal/errz"
+	"github.com/ship-digital/pull-watch/internal/events"
+	"github.com/ship-digital/pull-watch/internal/executor"
 	"github.com/ship-digital/pull-watch/internal/git"
+	"github.com/ship-digital/pull-watch/internal/limits"
 	"github.com/ship-digital/pull-watch/internal/logger"
+	"github.com/ship-digital/pull-watch/internal/notify"
+	"github.com/ship-digital/pull-watch/internal/sdnotify"
+	"github.com/ship-digital/pull-watch/internal/watcher"
 )
 
 // WatchOption configures the Watch function
@@ -22,6 +30,9 @@ type WatchOption func(*watchOptions)
 type watchOptions struct {
 	repository     git.Repository
 	processManager Processor
+	control        <-chan control.Request
+	reload         func() (*config.Config, error)
+	executor       executor.CommandExecutor
 }
 
 // WithRepository sets a custom repository implementation
@@ -38,7 +49,30 @@ func WithProcessManager(pm Processor) WatchOption {
 	}
 }
 
+// WithControl handles the requests received from a control socket
+func WithControl(requests <-chan control.Request) WatchOption {
+	return func(opts *watchOptions) {
+		opts.control = requests
+	}
+}
+
+// WithExecutor sets the executor of the commands run outside the repository, like git version
+func WithExecutor(exec executor.CommandExecutor) WatchOption {
+	return func(opts *watchOptions) {
+		opts.executor = exec
+	}
+}
+
+// WithReload makes SIGHUP, changes to cfg.ConfigFile and reload requests on the
+// control socket read the configuration again with load and apply it
+func WithReload(load func() (*config.Config, error)) WatchOption {
+	return func(opts *watchOptions) {
+		opts.reload = load
+	}
+}
+
 func Run(cfg *config.Config, opts ...WatchOption) error {
+	startedAt := time.Now()
 	options := &watchOptions{}
 	for _, opt := range opts {
 		opt(options)
@@ -46,51 +80,129 @@ func Run(cfg *config.Config, opts ...WatchOption) error {
 
 	repo := options.repository
 	if repo == nil {
-		repo = git.New(cfg)
+		var gitOpts []git.Option
+		if cfg.DryRun {
+			gitOpts = append(gitOpts, git.WithExecutor(executor.NewDryRun(cfg, executor.New(cfg))))
+		}
+		repo = git.New(cfg, gitOpts...)
 	}
 
 	pm := options.processManager
 	if pm == nil {
-		pm = New(cfg)
+		if cfg.DryRun {
+			pm = NewDryRun(cfg)
+		} else {
+			output, err := openOutput(cfg)
+			if err != nil {
+				return err
+			}
+			defer output.Close()
+			manager := New(cfg, WithOutput(output.stdout, output.stderr))
+			defer manager.Close()
+			pm = manager
+		}
+	}
+
+	if cfg.DryRun {
+		cfg.Logger.MultiColor(logger.QuietLevel,
+			logger.HighlightSegment("[dry-run] "),
+			logger.InfoSegment("No changes will be pulled and no processes will be started or stopped"),
+		)
 	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	if cfg.History && !cfg.DryRun {
+		if journal := openHistory(ctx, cfg, repo); journal != nil {
+			defer journal.Close()
+			addSink(cfg, journal)
+		}
+	}
+
+	// The notifier is replaced when the configuration is reloaded,
+	// sinks holds everything it is added to
+	sinks := cfg.Events
+	var notifier *notify.Notifier
+	if !cfg.DryRun {
+		var err error
+		notifier, err = openNotifier(cfg)
+		if err != nil {
+			return fmt.Errorf("failed to set up notifications: %w", err)
+		}
+		if notifier != nil {
+			addSink(cfg, notifier)
+		}
+	}
+	defer func() {
+		if notifier != nil {
+			notifier.Close()
+		}
+	}()
+
 	lastLocalCommit, err := repo.GetLatestCommit(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to get initial commit: %w", err)
 	}
 
-	lastRemoteCommit, err := repo.GetRemoteCommit(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to get initial remote commit: %w", err)
-	}
+	var lastRemoteCommit string
+	if cfg.Mode == config.ModeLocal {
+		cfg.Logger.MultiColor(logger.DefaultLevel,
+			logger.InfoSegment("Starting "),
+			logger.HighlightSegment("local"),
+			logger.InfoSegment(" watch with "),
+			logger.HighlightSegment(cfg.PollInterval.String()),
+			logger.InfoSegment(" fallback interval"),
+		)
+		cfg.Logger.MultiColor(logger.DefaultLevel,
+			logger.InfoSegment("Local commit: "),
+			logger.HighlightSegment(lastLocalCommit),
+		)
+	} else {
+		lastRemoteCommit, err = repo.GetRemoteCommit(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get initial remote commit: %w", err)
+		}
 
-	cfg.Logger.MultiColor(logger.DefaultLevel,
-		logger.InfoSegment("Starting watch with "),
-		logger.HighlightSegment(cfg.PollInterval.String()),
-		logger.InfoSegment(" interval"),
-	)
-	cfg.Logger.MultiColor(logger.DefaultLevel,
-		logger.InfoSegment("Local commit: "),
-		logger.HighlightSegment(lastLocalCommit),
-	)
-	cfg.Logger.MultiColor(logger.DefaultLevel,
-		logger.InfoSegment("Remote commit: "),
-		logger.HighlightSegment(lastRemoteCommit),
-	)
+		cfg.Logger.MultiColor(logger.DefaultLevel,
+			logger.InfoSegment("Starting watch with "),
+			logger.HighlightSegment(cfg.PollInterval.String()),
+			logger.InfoSegment(" interval"),
+		)
+		cfg.Logger.MultiColor(logger.DefaultLevel,
+			logger.InfoSegment("Local commit: "),
+			logger.HighlightSegment(lastLocalCommit),
+		)
+		cfg.Logger.MultiColor(logger.DefaultLevel,
+			logger.InfoSegment("Remote commit: "),
+			logger.HighlightSegment(lastRemoteCommit),
+		)
+	}
 	cfg.Logger.MultiColor(logger.DefaultLevel,
 		logger.InfoSegment("Command: "),
 		logger.HighlightSegment(strings.Join(cfg.Command, " ")),
 	)
 
-	comparison, err := repo.HandleCommitComparison(ctx, lastLocalCommit, lastRemoteCommit)
-	if err != nil {
+	if err := repo.PrepareCheckout(ctx); err != nil {
 		return err
 	}
 
-	shouldStart := cfg.RunOnStart || comparison == git.AIsAncestorOfB
+	// Local mode never contacts the remote, so there is nothing to catch up on
+	shouldStart := cfg.RunOnStart
+	if cfg.Mode != config.ModeLocal {
+		comparison, err := repo.HandleCommitComparison(ctx, lastLocalCommit, lastRemoteCommit)
+		if err != nil {
+			return err
+		}
+		shouldStart = shouldStart || comparison == git.AIsAncestorOfB
+		if comparison == git.AIsAncestorOfB {
+			// The pull already happened, or is pretended to in a dry run so the
+			// same commit isn't reported on every tick
+			if lastLocalCommit, err = pulledCommit(ctx, cfg, repo, lastRemoteCommit); err != nil {
+				return err
+			}
+		}
+	}
 
 	if shouldStart && cfg.RunOnStart {
 		cfg.Logger.MultiColor(logger.DefaultLevel,
@@ -107,36 +219,258 @@ func Run(cfg *config.Config, opts ...WatchOption) error {
 	}
 
 	if shouldStart {
-		if err := pm.Start(); err != nil {
+		if err := deployCommit(ctx, cfg, repo, lastLocalCommit); err != nil {
+			return err
+		}
+		if err := useLiveWorktree(ctx, cfg, repo, pm); err != nil {
 			return err
 		}
+		pm.SetRevision(lastLocalCommit)
+		err := pm.Start()
+		recordEvent(ctx, cfg, repo, events.Event{Type: events.Start, Commit: lastLocalCommit, PID: pm.GetPID()}.WithError(err))
+		if err != nil {
+			return err
+		}
+		pruneWorktrees(ctx, cfg, repo, pm)
+	}
+
+	notifyServiceManager(cfg, sdnotify.Ready, sdnotify.Status(serviceStatus(lastLocalCommit, pm)))
+
+	// watchdog stays nil unless the service manager enabled its watchdog
+	var watchdog <-chan time.Time
+	if interval := sdnotify.WatchdogInterval(); interval > 0 {
+		watchdogTicker := time.NewTicker(interval / 2)
+		defer watchdogTicker.Stop()
+		watchdog = watchdogTicker.C
 	}
 
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
+	// hupChan and configChanges stay nil (and never fire) without a way to reload
+	var hupChan chan os.Signal
+	var configChanges <-chan struct{}
+	if options.reload != nil {
+		hupChan = make(chan os.Signal, 1)
+		signal.Notify(hupChan, syscall.SIGHUP)
+		defer signal.Stop(hupChan)
+
+		if cfg.ConfigFile != "" {
+			if w, err := watcher.NewFileWatcher(cfg.ConfigFile, cfg.Logger); err != nil {
+				cfg.Logger.Warn("Failed to watch %s, send SIGHUP to reload it: %v", cfg.ConfigFile, err)
+			} else {
+				defer w.Close()
+				configChanges = w.Changes()
+			}
+		}
+	}
+
 	ticker := time.NewTicker(cfg.PollInterval)
 	defer ticker.Stop()
 
+	check := checkAndUpdate
+	// headChanges stays nil (and never fires) unless local mode has a working watcher
+	var headChanges <-chan struct{}
+	if cfg.Mode == config.ModeLocal {
+		check = checkLocal
+		if w := watchHead(ctx, cfg, repo); w != nil {
+			defer w.Close()
+			headChanges = w.Changes()
+		}
+	}
+
+	// The tree watcher is replaced when its settings are reloaded
+	var tree *watcher.Watcher
+	var treeChanges <-chan struct{}
+	stopTreeWatcher := func() {
+		if tree != nil {
+			tree.Close()
+			tree, treeChanges = nil, nil
+		}
+	}
+	startTreeWatcher := func() {
+		stopTreeWatcher()
+		if cfg.WatchTree {
+			if tree = watchTree(ctx, cfg, repo); tree != nil {
+				treeChanges = tree.Changes()
+			}
+		}
+	}
+	startTreeWatcher()
+	defer func() {
+		if tree != nil {
+			tree.Close()
+		}
+	}()
+
+	// A closed done channel is always ready, exitedDone remembers the one already
+	// handled so the loop doesn't spin on it until the next start replaces it
+	var exitedDone <-chan struct{}
+	doneChan := func() <-chan struct{} {
+		if ch := pm.GetDoneChan(); ch != exitedDone {
+			return ch
+		}
+		return nil
+	}
+
+	// A pull rewrites files in the working tree, treeQuietUntil keeps the
+	// tree watcher from restarting the command a second time for it
+	var treeQuietUntil time.Time
 	var processExited bool
+	var lastCheck time.Time
+	var lastCheckErr error
+	var remoteDown outage
+	var failed failedDeploy
+	runCheck := func() {
+		before := lastLocalCommit
+		lastCheck = time.Now()
+		lastCheckErr = check(ctx, cfg, repo, &lastLocalCommit, &failed, pm, processExited)
+		if git.IsTransient(lastCheckErr) {
+			// Check less often while the remote is down, and don't complain on every tick
+			ticker.Reset(remoteDown.fail(ctx, cfg, repo, lastCheckErr))
+		} else {
+			if remoteDown.recover(cfg) {
+				ticker.Reset(cfg.PollInterval)
+			}
+			if lastCheckErr != nil {
+				logCheckError(ctx, cfg, repo, lastCheckErr)
+			}
+		}
+		if lastLocalCommit != before {
+			treeQuietUntil = time.Now().Add(2 * treeDebounce(cfg))
+		}
+		processExited = false
+	}
+
+	// reloadConfig reads the configuration again and applies what changed, a
+	// configuration that can't be loaded is reported and the current one kept
+	reloadConfig := func() error {
+		next, err := options.reload()
+		if err == nil && !cfg.DryRun && changed(diffConfig(cfg, next), "notify", "notify-events", "notify-template") {
+			// Open the new notifier first so a bad webhook doesn't leave none behind
+			next.Logger = cfg.Logger
+			var replacement *notify.Notifier
+			if replacement, err = openNotifier(next); err == nil {
+				if notifier != nil {
+					notifier.Close()
+				}
+				notifier = replacement
+				cfg.Events = sinks
+				if notifier != nil {
+					addSink(cfg, notifier)
+				}
+			}
+		}
+		if err != nil {
+			cfg.Logger.MultiColor(logger.QuietLevel,
+				logger.ErrorSegment("Failed to reload configuration, keeping the current one: "),
+				logger.HighlightSegment(fmt.Sprintf("%v", err)),
+			)
+			err = fmt.Errorf("failed to reload configuration: %w", err)
+			recordEvent(ctx, cfg, repo, events.Event{Type: events.Error}.WithError(err))
+			return err
+		}
+
+		changes := diffConfig(cfg, next)
+		logConfigChanges(cfg, changes)
+		// The tree watcher asks git about new paths from its own goroutine,
+		// it must not read cfg while the changes are copied in
+		watching := tree != nil && len(changes) > 0
+		if watching {
+			stopTreeWatcher()
+		}
+		restartNeeded := applyConfig(cfg, next, changes)
+
+		if changed(changes, "interval") {
+			ticker.Reset(cfg.PollInterval)
+		}
+		if watching || changed(changes, "watch", "watch-include", "watch-exclude", "watch-debounce") {
+			startTreeWatcher()
+		}
+		if !restartNeeded {
+			return nil
+		}
+		if !pm.IsRunning() {
+			pm.GetLogger().Info("The command isn't running, the new one starts with the next update")
+			return nil
+		}
+		pm.GetLogger().Info("Command or environment changed")
+		err = restartCommand(ctx, cfg, repo, pm, lastLocalCommit)
+		processExited = false
+		return err
+	}
+
 	for {
 		select {
 		case <-ticker.C:
-			if err := checkAndUpdate(ctx, cfg, repo, &lastLocalCommit, pm, processExited); err != nil {
-				//TODO: This is a bit of a hack, but it works for now
-				if !errors.Is(err, os.ErrProcessDone) && !strings.Contains(err.Error(), errz.ErrInterrupt.Error()) {
-					cfg.Logger.MultiColor(logger.DefaultLevel,
-						logger.ErrorSegment("Error during update check: "),
-						logger.HighlightSegment(fmt.Sprintf("%v", err)),
-					)
-				}
+			runCheck()
+
+		case <-headChanges:
+			runCheck()
+
+		case <-treeChanges:
+			if time.Now().Before(treeQuietUntil) {
+				cfg.Logger.Debug("Ignoring working tree changes caused by the last update")
+				continue
+			}
+			pm.GetLogger().Info("\nWorking tree changes detected!")
+			if err := restart(ctx, cfg, repo, pm, lastLocalCommit); err != nil {
+				logCheckError(ctx, cfg, repo, err)
 			}
 			processExited = false
 
-		case <-pm.GetDoneChan():
+		case <-configChanges:
+			pm.GetLogger().Info("\nConfiguration file changed, reloading")
+			// Failures are logged by reloadConfig
+			_ = reloadConfig()
+
+		case <-hupChan:
+			pm.GetLogger().Info("\nReceived SIGHUP, reloading configuration")
+			// Failures are logged by reloadConfig
+			_ = reloadConfig()
+
+		case <-watchdog:
+			notifyServiceManager(cfg, sdnotify.Watchdog)
+
+		case <-doneChan():
+			exitedDone = pm.GetDoneChan()
 			if !processExited {
 				processExited = true
 				now := time.Now()
+				notifyServiceManager(cfg, sdnotify.Status(serviceStatus(lastLocalCommit, pm)))
+
+				status := pm.GetExitStatus()
+				runtime := now.Sub(pm.GetStartTime())
+				event := events.Event{
+					Type:    exitEventType(status),
+					Commit:  lastLocalCommit,
+					PID:     pm.GetPID(),
+					Outcome: "exited",
+					Exit:    status,
+				}.WithDuration(runtime)
+				if status != nil && status.OOMKilled {
+					event.Outcome = "oom-killed"
+					// Always worth knowing, unlike a repeated plain exit
+					cfg.Logger.MultiColor(logger.QuietLevel,
+						logger.ErrorSegment("Process with PID "),
+						logger.HighlightSegment(fmt.Sprintf("%d", pm.GetPID())),
+						logger.ErrorSegment(" killed for exceeding its memory limit of "),
+						logger.HighlightSegment(limits.FormatSize(cfg.Limits.CgroupMemoryMax)),
+					)
+				}
+				recordEvent(ctx, cfg, repo, event)
+
+				if cfg.ExitWithChild {
+					cfg.Logger.MultiColor(logger.DefaultLevel,
+						append(exitSegments(pm.GetPID(), status, runtime), logger.InfoSegment(", shutting down"))...,
+					)
+					notifyServiceManager(cfg, sdnotify.Stopping)
+					if status == nil {
+						// Whatever happened, it wasn't a success
+						return &ExitError{Status: events.ExitStatus{Code: 1}}
+					}
+					return &ExitError{Status: *status}
+				}
 
 				// Initialize backoff on first exit
 				if pm.GetBackoff() == 0 {
@@ -146,9 +480,7 @@ func Run(cfg *config.Config, opts ...WatchOption) error {
 				// Log only if enough time has passed
 				if now.Sub(pm.GetLastLogTime()) >= pm.GetBackoff() {
 					cfg.Logger.MultiColor(logger.DefaultLevel,
-						logger.InfoSegment("Process with PID "),
-						logger.HighlightSegment(fmt.Sprintf("%d", pm.(*ProcessManager).GetPID())),
-						logger.InfoSegment(" exited, waiting for changes before restart"),
+						append(exitSegments(pm.GetPID(), status, runtime), logger.InfoSegment(", waiting for changes before restart"))...,
 					)
 					pm.SetLastLogTime(now)
 					// Increase backoff for next time (cap at maxBackoff)
@@ -160,41 +492,77 @@ func Run(cfg *config.Config, opts ...WatchOption) error {
 				}
 			}
 
+		case req := <-options.control:
+			switch req.Command {
+			case control.CommandStatus:
+				gitDir, _ := filepath.Abs(cfg.GitDir)
+				status := &control.Status{
+					PID:          os.Getpid(),
+					StartedAt:    startedAt,
+					Mode:         cfg.Mode,
+					GitDir:       gitDir,
+					Command:      cfg.Command,
+					PollInterval: cfg.PollInterval.String(),
+					Commit:       lastLocalCommit,
+					LastCheck:    lastCheck,
+					Process: control.ProcessStatus{
+						Running:   pm.IsRunning(),
+						PID:       pm.GetPID(),
+						StartedAt: pm.GetStartTime(),
+						BackoffMS: pm.GetBackoff().Milliseconds(),
+						LastExit:  pm.GetExitStatus(),
+					},
+				}
+				if lastCheckErr != nil {
+					status.LastError = lastCheckErr.Error()
+				}
+				req.Respond(control.Response{OK: true, Status: status})
+
+			case control.CommandReload:
+				if options.reload != nil {
+					pm.GetLogger().Info("\nReload requested over the control socket")
+					if err := reloadConfig(); err != nil {
+						req.Respond(controlResponse(err))
+						continue
+					}
+				} else {
+					pm.GetLogger().Info("\nCheck requested over the control socket")
+				}
+				runCheck()
+				req.Respond(controlResponse(lastCheckErr))
+
+			case control.CommandRestart:
+				pm.GetLogger().Info("\nRestart requested over the control socket")
+				err := restart(ctx, cfg, repo, pm, lastLocalCommit)
+				if err != nil {
+					logCheckError(ctx, cfg, repo, err)
+				}
+				processExited = false
+				req.Respond(controlResponse(err))
+
+			case control.CommandStop:
+				cfg.Logger.MultiColor(logger.DefaultLevel,
+					logger.InfoSegment("Stop requested over the control socket, shutting down..."),
+				)
+				notifyServiceManager(cfg, sdnotify.Stopping)
+				err := shutdown(cfg, pm)
+				req.Respond(controlResponse(err))
+				return err
+			}
+
 		case sig := <-sigChan:
 			cfg.Logger.MultiColor(logger.DefaultLevel,
 				logger.InfoSegment("Received signal "),
 				logger.HighlightSegment(fmt.Sprintf("%v", sig)),
 				logger.InfoSegment(", shutting down..."),
 			)
-
-			// If process was never started, we can exit immediately
-			if !pm.IsRunning() {
-				return nil
-			}
-
-			// Stop the process and wait for it to finish before exiting
-			if err := pm.Stop(); err != nil {
-				cfg.Logger.MultiColor(logger.DefaultLevel,
-					logger.ErrorSegment("Error stopping process with PID "),
-					logger.HighlightSegment(fmt.Sprintf("%d", pm.(*ProcessManager).GetPID())),
-					logger.ErrorSegment(": "),
-					logger.HighlightSegment(fmt.Sprintf("%v", err)),
-				)
-				return err
-			}
-			// Wait for process to fully terminate
-			select {
-			case <-pm.GetDoneChan():
-				return nil
-			case <-time.After(5 * time.Second):
-				// Force exit if process doesn't terminate in time
-				return fmt.Errorf("process failed to terminate gracefully")
-			}
+			notifyServiceManager(cfg, sdnotify.Stopping)
+			return shutdown(cfg, pm)
 		}
 	}
 }
 
-func checkAndUpdate(ctx context.Context, cfg *config.Config, repo git.Repository, lastCommit *string, pm Processor, shouldStart bool) error {
+func checkAndUpdate(ctx context.Context, cfg *config.Config, repo git.Repository, lastCommit *string, failed *failedDeploy, pm Processor, shouldStart bool) error {
 	localHash, err := repo.GetLatestCommit(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to get local commit: %w", err)
@@ -205,40 +573,215 @@ func checkAndUpdate(ctx context.Context, cfg *config.Config, repo git.Repository
 		return fmt.Errorf("failed to get remote commit: %w", err)
 	}
 
+	if cfg.DryRun && remoteHash == *lastCommit {
+		// Nothing is really pulled in a dry run, this commit was already reported
+		return nil
+	}
+
 	comparison, err := repo.HandleCommitComparison(ctx, localHash, remoteHash)
 	if err != nil {
 		return err
 	}
 
 	if comparison == git.AIsAncestorOfB {
+		pulled, err := pulledCommit(ctx, cfg, repo, remoteHash)
+		if err != nil {
+			return err
+		}
+		touched, err := repo.TouchesCheckout(ctx, localHash, pulled)
+		if err != nil {
+			// The pull went through, better an unneeded restart than stale code
+			cfg.Logger.Warn("Failed to tell whether the changes touch the checkout, restarting anyway: %v", err)
+			touched = true
+		}
+		if !touched {
+			*lastCommit = pulled
+			return nil
+		}
 		pm.GetLogger().Info("\nChanges detected!")
+		return goLive(ctx, cfg, repo, pm, lastCommit, failed, pulled)
+	}
 
-		*lastCommit = remoteHash
-
-		// Restart logic is conditional based on the NoRestart flag
-		if !cfg.NoRestart {
-			pm.GetLogger().Info("Restarting command due to changes...")
-			if err := pm.Stop(); err != nil {
-				pm.GetLogger().MultiColor(logger.DefaultLevel,
-					logger.ErrorSegment("Error stopping process with PID "),
-					logger.HighlightSegment(fmt.Sprintf("%d", pm.(*ProcessManager).GetPID())),
-					logger.ErrorSegment(": "),
-					logger.HighlightSegment(fmt.Sprintf("%v", err)),
-				)
-				// Log the stop error, but proceed to attempt start
-			}
+	// A deploy that failed after its pull is tried again, after a while, until it goes live
+	if cfg.Deploy.Mode == config.DeployWorktree && !cfg.DryRun && localHash != *lastCommit {
+		if !failed.due(localHash) {
+			return nil
+		}
+		cfg.Logger.MultiColor(logger.DefaultLevel,
+			logger.InfoSegment("Retrying the deploy of "),
+			logger.HighlightSegment(events.ShortCommit(localHash)),
+		)
+		return goLive(ctx, cfg, repo, pm, lastCommit, failed, localHash)
+	}
 
-			time.Sleep(100 * time.Millisecond) // Brief pause for process termination
+	// Submodules tracking a branch move without a new commit in the superproject
+	moved, err := repo.UpdateTrackedSubmodules(ctx)
+	if err != nil {
+		return err
+	}
+	if moved {
+		pm.GetLogger().Info("\nSubmodule changes detected!")
+		return restart(ctx, cfg, repo, pm, localHash)
+	}
 
-			if err := pm.Start(); err != nil {
-				// Starting error is critical, return it
-				pm.GetLogger().Error(fmt.Sprintf("Error starting command after changes: %v", err))
-				return fmt.Errorf("failed to restart command: %w", err)
-			}
-		} else {
-			pm.GetLogger().Info("NoRestart flag set, skipping command restart. Working directory updated.")
+	return nil
+}
+
+// exitEventType tells a crash from a clean exit, an unknown status counts as a crash
+func exitEventType(status *events.ExitStatus) events.Type {
+	if status == nil || status.Code != 0 || status.Signal != "" || status.OOMKilled {
+		return events.Crash
+	}
+	return events.Exit
+}
+
+// pulledCommit returns the commit HEAD is on after remoteHash was pulled.
+// Rebase and merge pulls of a diverged branch leave HEAD on a new commit of their own.
+func pulledCommit(ctx context.Context, cfg *config.Config, repo git.Repository, remoteHash string) (string, error) {
+	if cfg.DryRun || (cfg.PullStrategy != config.PullRebase && cfg.PullStrategy != config.PullMerge) {
+		return remoteHash, nil
+	}
+	head, err := repo.GetLatestCommit(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get local commit: %w", err)
+	}
+	return head, nil
+}
+
+// shutdown stops the command and waits for it to exit
+func shutdown(cfg *config.Config, pm Processor) error {
+	// If process was never started, we can exit immediately
+	if !pm.IsRunning() {
+		return nil
+	}
+
+	// Stop the process and wait for it to finish before exiting
+	if err := pm.Stop(); err != nil {
+		cfg.Logger.MultiColor(logger.DefaultLevel,
+			logger.ErrorSegment("Error stopping process with PID "),
+			logger.HighlightSegment(fmt.Sprintf("%d", pm.GetPID())),
+			logger.ErrorSegment(": "),
+			logger.HighlightSegment(fmt.Sprintf("%v", err)),
+		)
+		return err
+	}
+	// Wait for process to fully terminate
+	select {
+	case <-pm.GetDoneChan():
+		return nil
+	case <-time.After(5 * time.Second):
+		// Force exit if process doesn't terminate in time
+		return fmt.Errorf("process failed to terminate gracefully")
+	}
+}
+
+// controlResponse reports the outcome of a control request
+func controlResponse(err error) control.Response {
+	if err != nil {
+		return control.Response{Error: err.Error()}
+	}
+	return control.Response{OK: true}
+}
+
+// goLive deploys commit and restarts the command on it, then prunes the old
+// worktrees. lastCommit only moves on once that all worked, so that a failed
+// deploy is tried again.
+func goLive(ctx context.Context, cfg *config.Config, repo git.Repository, pm Processor, lastCommit *string, failed *failedDeploy, commit string) error {
+	if err := deployCommit(ctx, cfg, repo, commit); err != nil {
+		return failed.fail(cfg, commit, err)
+	}
+	*failed = failedDeploy{}
+	if err := restart(ctx, cfg, repo, pm, commit); err != nil {
+		return err
+	}
+	*lastCommit = commit
+	pruneWorktrees(ctx, cfg, repo, pm)
+	return nil
+}
+
+// restart stops and starts the command after an update, unless NoRestart is set.
+// With a reload signal the command is asked to reload itself first.
+func restart(ctx context.Context, cfg *config.Config, repo git.Repository, pm Processor, commit string) error {
+	if cfg.NoRestart {
+		pm.GetLogger().Info("NoRestart flag set, skipping command restart. Working directory updated.")
+		// Keep "systemctl status" up to date with the commit
+		notifyServiceManager(cfg, sdnotify.Status(serviceStatus(commit, pm)))
+		return nil
+	}
+
+	if cfg.ReloadSignal != "" && pm.IsRunning() {
+		start := time.Now()
+		err := reloadInPlace(ctx, cfg, pm)
+		recordEvent(ctx, cfg, repo, events.Event{Type: events.Reload, Commit: commit, PID: pm.GetPID()}.WithDuration(time.Since(start)).WithError(err))
+		if err == nil {
+			pm.SetRevision(commit)
+			notifyServiceManager(cfg, sdnotify.Status(serviceStatus(commit, pm)))
+			return nil
 		}
+		pm.GetLogger().Warn("Reload failed, restarting the command: %v", err)
+	}
+	return restartCommand(ctx, cfg, repo, pm, commit)
+}
+
+// restartCommand stops and starts the command, even when NoRestart is set
+func restartCommand(ctx context.Context, cfg *config.Config, repo git.Repository, pm Processor, commit string) error {
+	// Keep "systemctl status" up to date with the commit and PID
+	defer func() {
+		notifyServiceManager(cfg, sdnotify.Status(serviceStatus(commit, pm)))
+	}()
+
+	// Before stopping, so a broken deploy directory doesn't leave nothing running
+	if err := useLiveWorktree(ctx, cfg, repo, pm); err != nil {
+		return err
+	}
+
+	pm.GetLogger().Info("Restarting command due to changes...")
+	start := time.Now()
+	if err := pm.Stop(); err != nil {
+		pm.GetLogger().MultiColor(logger.DefaultLevel,
+			logger.ErrorSegment("Error stopping process with PID "),
+			logger.HighlightSegment(fmt.Sprintf("%d", pm.GetPID())),
+			logger.ErrorSegment(": "),
+			logger.HighlightSegment(fmt.Sprintf("%v", err)),
+		)
+		// Log the stop error, but proceed to attempt start
+	}
+
+	time.Sleep(100 * time.Millisecond) // Brief pause for process termination
+
+	pm.SetRevision(commit)
+	err := pm.Start()
+	recordEvent(ctx, cfg, repo, events.Event{Type: events.Restart, Commit: commit, PID: pm.GetPID()}.WithDuration(time.Since(start)).WithError(err))
+	if err != nil {
+		// Starting error is critical, return it
+		pm.GetLogger().Error(fmt.Sprintf("Error starting command after changes: %v", err))
+		return fmt.Errorf("failed to restart command: %w", err)
 	}
 
 	return nil
 }
+
+// treeDebounce returns the effective debounce of the working tree watcher
+func treeDebounce(cfg *config.Config) time.Duration {
+	if cfg.WatchDebounce > 0 {
+		return cfg.WatchDebounce
+	}
+	return 500 * time.Millisecond
+}
+
+// logCheckError reports a failed update check, ignoring errors caused by shutdown
+func logCheckError(ctx context.Context, cfg *config.Config, repo git.Repository, err error) {
+	if errors.Is(err, os.ErrProcessDone) || errors.Is(err, errz.ErrInterrupt) {
+		return
+	}
+	recordEvent(ctx, cfg, repo, events.Event{Type: events.Error}.WithError(err))
+	cfg.Logger.MultiColor(logger.DefaultLevel,
+		logger.ErrorSegment("Error during update check: "),
+		logger.HighlightSegment(fmt.Sprintf("%v", err)),
+	)
+	if hint := Hint(err); hint != "" {
+		cfg.Logger.MultiColor(logger.DefaultLevel,
+			logger.InfoSegment("Hint: "+hint),
+		)
+	}
+}
@@ -2,18 +2,32 @@ package runner
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
 	"syscall"
 	"testing"
 	"time"
 
 	"github.com/ship-digital/pull-watch/internal/config"
+	"github.com/ship-digital/pull-watch/internal/control"
+	"github.com/ship-digital/pull-watch/internal/errz"
+	"github.com/ship-digital/pull-watch/internal/events"
 	"github.com/ship-digital/pull-watch/internal/git"
 	"github.com/ship-digital/pull-watch/internal/logger"
+	"github.com/ship-digital/pull-watch/internal/output"
 )
 
 // MockRepo implements a mock git repository for testing
 type MockRepo struct {
+	// mu guards the commits, which tests move while Run reads them
+	mu             sync.Mutex
 	localCommits   []string
 	remoteCommits  []string
 	pullError      error
@@ -21,13 +35,19 @@ type MockRepo struct {
 	compareError   error
 	currentIndex   int
 	compareHandler func(local, remote string) git.CommitComparisonResult
+	gitDir         string
+	touchError     error
 }
 
 func (m *MockRepo) GetLatestCommit(ctx context.Context) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	return m.localCommits[0], nil
 }
 
 func (m *MockRepo) GetRemoteCommit(ctx context.Context) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	return m.remoteCommits[m.currentIndex], nil
 }
 
@@ -35,10 +55,26 @@ func (m *MockRepo) Pull(ctx context.Context) (string, error) {
 	if m.pullError != nil {
 		return "", m.pullError
 	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	m.localCommits[0] = m.remoteCommits[m.currentIndex]
 	return "Changes pulled successfully", nil
 }
 
+// setLocalCommit moves the local HEAD
+func (m *MockRepo) setLocalCommit(commit string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.localCommits[0] = commit
+}
+
+// setRemoteIndex moves the remote to m.remoteCommits[i]
+func (m *MockRepo) setRemoteIndex(i int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.currentIndex = i
+}
+
 func (m *MockRepo) HandleCommitComparison(ctx context.Context, local, remote string) (git.CommitComparisonResult, error) {
 	if m.compareError != nil {
 		return git.UnknownCommitComparisonResult, m.compareError
@@ -61,6 +97,40 @@ func (m *MockRepo) IsClean(ctx context.Context) (bool, error) {
 	return true, nil // For testing we can assume the repo is clean
 }
 
+func (m *MockRepo) ListIgnored(ctx context.Context) ([]string, error) {
+	return nil, nil // For testing nothing is ignored
+}
+
+func (m *MockRepo) IsIgnored(ctx context.Context, path string) (bool, error) {
+	return false, nil // For testing nothing is ignored
+}
+
+func (m *MockRepo) GetCommitSubject(ctx context.Context, commit string) (string, error) {
+	return "Subject of " + commit, nil
+}
+
+func (m *MockRepo) UpdateTrackedSubmodules(ctx context.Context) (bool, error) {
+	return false, nil // For testing there are no submodules
+}
+
+func (m *MockRepo) PrepareCheckout(ctx context.Context) error {
+	return nil
+}
+
+func (m *MockRepo) TouchesCheckout(ctx context.Context, from, to string) (bool, error) {
+	if m.touchError != nil {
+		return false, m.touchError
+	}
+	return true, nil // For testing everything is checked out
+}
+
+func (m *MockRepo) GetGitDir(ctx context.Context) (string, error) {
+	if m.gitDir == "" {
+		return "", fmt.Errorf("no git dir")
+	}
+	return m.gitDir, nil
+}
+
 // TestProcessManager wraps ProcessManager for testing
 type TestProcessManager struct {
 	pm         *ProcessManager
@@ -122,18 +192,58 @@ func (pm *TestProcessManager) GetLogger() *logger.Logger {
 	return pm.pm.GetLogger()
 }
 
+// IsRunning implements Processor interface
+func (pm *TestProcessManager) IsRunning() bool {
+	return pm.pm.IsRunning()
+}
+
+// GetPID implements Processor interface
+func (pm *TestProcessManager) GetPID() int {
+	return pm.pm.GetPID()
+}
+
+// GetStartTime implements Processor interface
+func (pm *TestProcessManager) GetStartTime() time.Time {
+	return pm.pm.GetStartTime()
+}
+
+// SetRevision implements Processor interface
+func (pm *TestProcessManager) SetRevision(commit string) {
+	pm.pm.SetRevision(commit)
+}
+
+// SetDir implements Processor interface
+func (pm *TestProcessManager) SetDir(dir string) {
+	pm.pm.SetDir(dir)
+}
+
+// GetDir implements Processor interface
+func (pm *TestProcessManager) GetDir() string {
+	return pm.pm.GetDir()
+}
+
+// GetExitStatus implements Processor interface
+func (pm *TestProcessManager) GetExitStatus() *events.ExitStatus {
+	return pm.pm.GetExitStatus()
+}
+
+// Signal implements Processor interface
+func (pm *TestProcessManager) Signal(name string) error {
+	return pm.pm.Signal(name)
+}
+
 func (pm *TestProcessManager) handleCommitComparison(ctx context.Context, cfg *config.Config, repo git.Repository, local, remote string) (git.CommitComparisonResult, error) {
 	result, err := repo.HandleCommitComparison(ctx, local, remote)
 	if err != nil {
 		return git.UnknownCommitComparisonResult, err
 	}
 
-	if cfg.Verbose {
-		pm.pm.logger.MultiColor(
+	if cfg.LogLevel >= logger.VerboseLevel {
+		pm.pm.logger.MultiColor(logger.VerboseLevel,
 			logger.InfoSegment("Local commit: "),
 			logger.HighlightSegment(local),
 		)
-		pm.pm.logger.MultiColor(
+		pm.pm.logger.MultiColor(logger.VerboseLevel,
 			logger.InfoSegment("Remote commit: "),
 			logger.HighlightSegment(remote),
 		)
@@ -141,22 +251,22 @@ func (pm *TestProcessManager) handleCommitComparison(ctx context.Context, cfg *c
 
 	switch result {
 	case git.CommitsEqual:
-		if cfg.Verbose {
+		if cfg.LogLevel >= logger.VerboseLevel {
 			pm.pm.logger.Info("Local commit and remote commit are the same: not pulling.")
 		}
 	case git.AIsAncestorOfB:
-		if cfg.Verbose {
+		if cfg.LogLevel >= logger.VerboseLevel {
 			pm.pm.logger.Info("Local commit is behind remote commit, pulling changes...")
 		}
 		if _, err := repo.Pull(ctx); err != nil {
 			return result, fmt.Errorf("failed to pull changes: %w", err)
 		}
 	case git.BIsAncestorOfA:
-		if cfg.Verbose {
+		if cfg.LogLevel >= logger.VerboseLevel {
 			pm.pm.logger.Info("Local commit is ahead of remote commit, not pulling.")
 		}
 	case git.CommitsDiverged:
-		if cfg.Verbose {
+		if cfg.LogLevel >= logger.VerboseLevel {
 			pm.pm.logger.Info("Local and remote commits have diverged, not pulling.")
 		}
 	}
@@ -203,6 +313,95 @@ func TestProcessManager_Start(t *testing.T) {
 	}
 }
 
+func TestProcessManager_OutputFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "output.log")
+	cfg := &config.Config{
+		Command:    []string{"sh", "-c", "echo out; echo err >&2"},
+		Logger:     logger.New(),
+		StdoutFile: path,
+		StderrFile: path,
+		LogMaxMB:   1,
+		LogKeep:    1,
+	}
+
+	output, err := openOutput(cfg)
+	if err != nil {
+		t.Fatalf("openOutput() error = %v", err)
+	}
+	defer output.Close()
+	if output.stdout != output.stderr {
+		t.Fatal("stdout and stderr to the same file should share a writer")
+	}
+
+	pm := New(cfg, WithOutput(output.stdout, output.stderr))
+	for _, commit := range []string{"abc123", "def456"} {
+		pm.SetRevision(commit)
+		if err := pm.Start(); err != nil {
+			t.Fatalf("Start() error = %v", err)
+		}
+		select {
+		case <-pm.GetDoneChan():
+		case <-time.After(2 * time.Second):
+			t.Fatal("process did not exit")
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	got := string(data)
+	for _, want := range []string{"at abc123 =====\nout\n", "at def456 =====\n", "err\n"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output file missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Count(got, "pull-watch: starting") != 2 {
+		t.Errorf("expected one separator per start:\n%s", got)
+	}
+}
+
+func TestProcessManager_OutputHeldByChild(t *testing.T) {
+	for _, format := range []output.Format{output.FormatRaw, output.FormatPrefixed} {
+		t.Run(string(format), func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "output.log")
+			cfg := &config.Config{
+				// The background sleep inherits stdout and outlives the shell
+				Command:      []string{"sh", "-c", "echo before; sleep 10 & echo after"},
+				Logger:       logger.New(),
+				OutputFormat: format,
+				StdoutFile:   path,
+				LogMaxMB:     1,
+			}
+			out, err := openOutput(cfg)
+			if err != nil {
+				t.Fatalf("openOutput() error = %v", err)
+			}
+			defer out.Close()
+
+			pm := New(cfg, WithOutput(out.stdout, out.stderr))
+			if err := pm.Start(); err != nil {
+				t.Fatalf("Start() error = %v", err)
+			}
+			select {
+			case <-pm.GetDoneChan():
+			case <-time.After(5 * time.Second):
+				t.Fatal("the exit went unnoticed while a child held the output open")
+			}
+			if status := pm.GetExitStatus(); status == nil || status.Code != 0 {
+				t.Errorf("exit status = %v, want code 0", status)
+			}
+
+			data, _ := os.ReadFile(path)
+			for _, want := range []string{"before", "after"} {
+				if !strings.Contains(string(data), want) {
+					t.Errorf("output file missing %q:\n%s", want, data)
+				}
+			}
+		})
+	}
+}
+
 func TestProcessManager_Stop(t *testing.T) {
 	tests := []struct {
 		name         string
@@ -260,7 +459,8 @@ func TestProcessManager_Stop(t *testing.T) {
 			// Give it a moment to start and verify it's running
 			time.Sleep(100 * time.Millisecond)
 
-			if pm.cmd == nil || pm.cmd.Process == nil {
+			// The PID outlives the process, which may already have exited
+			if pm.GetPID() == 0 {
 				t.Fatal("Process did not start properly")
 			}
 
@@ -354,7 +554,7 @@ func TestWatch_BasicScenarios(t *testing.T) {
 				Logger:       logger.New(),
 				RunOnStart:   tt.runOnStart,
 				PollInterval: 100 * time.Millisecond,
-				Verbose:      true,
+				LogLevel:     logger.VerboseLevel,
 			}
 
 			ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
@@ -411,6 +611,17 @@ func TestWatch_EdgeCases(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name:          "invalid command in dry run",
+			localCommits:  []string{"abc123"},
+			remoteCommits: []string{"def456"},
+			compareResult: git.AIsAncestorOfB,
+			setupFunc: func(cfg *config.Config) {
+				cfg.Command = []string{"nonexistentcommand"}
+				cfg.DryRun = true
+			},
+			wantErr: false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -426,7 +637,7 @@ func TestWatch_EdgeCases(t *testing.T) {
 				Logger:       logger.New(),
 				RunOnStart:   true,
 				PollInterval: 100 * time.Millisecond,
-				Verbose:      true,
+				LogLevel:     logger.VerboseLevel,
 			}
 
 			if tt.setupFunc != nil {
@@ -475,7 +686,7 @@ func TestWatch_LocalAheadThenBehind(t *testing.T) {
 		Command:      []string{"sleep", "0.1"},
 		Logger:       logger.New(),
 		PollInterval: 50 * time.Millisecond,
-		Verbose:      true,
+		LogLevel:     logger.VerboseLevel,
 		RunOnStart:   false,
 	}
 
@@ -500,7 +711,7 @@ func TestWatch_LocalAheadThenBehind(t *testing.T) {
 	drainExecutions(executions)
 
 	// Simulate remote moving ahead
-	mockRepo.currentIndex = 2 // Move to ghi789
+	mockRepo.setRemoteIndex(2) // Move to ghi789
 
 	// Wait for execution
 	select {
@@ -517,6 +728,459 @@ func TestWatch_LocalAheadThenBehind(t *testing.T) {
 	}
 }
 
+func TestWatch_LocalModeRestartsOnHeadMove(t *testing.T) {
+	gitDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(gitDir, "refs", "heads"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	headFile := filepath.Join(gitDir, "HEAD")
+	if err := os.WriteFile(headFile, []byte("ref: refs/heads/main\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	mockRepo := &MockRepo{
+		localCommits:  []string{"abc123"},
+		remoteCommits: []string{"unused"},
+		gitDir:        gitDir,
+	}
+
+	executions := make(chan struct{}, 10)
+	cfg := &config.Config{
+		Command: []string{"sleep", "0.1"},
+		Logger:  logger.New(),
+		// Long enough that only the filesystem watcher can trigger the restart
+		PollInterval: time.Hour,
+		Mode:         config.ModeLocal,
+	}
+
+	testPM := NewTestProcessManager(cfg, executions)
+
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- Run(cfg, WithRepository(mockRepo), WithProcessManager(testPM))
+	}()
+
+	// Give the watcher time to start, nothing should run without -run-on-start
+	time.Sleep(200 * time.Millisecond)
+	select {
+	case <-executions:
+		t.Fatal("Command started without a HEAD change")
+	default:
+	}
+
+	// Simulate another tool moving HEAD
+	mockRepo.setLocalCommit("def456")
+	if err := os.WriteFile(filepath.Join(gitDir, "refs", "heads", "main"), []byte("def456\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-executions:
+		// Success - command executed after HEAD moved
+	case err := <-errChan:
+		t.Errorf("Watch returned unexpectedly with error: %v", err)
+	case <-time.After(2 * time.Second):
+		t.Error("Command was not executed after local HEAD moved")
+	}
+}
+
+// recordingSink collects recorded events for assertions
+type recordingSink struct {
+	mu     sync.Mutex
+	events []events.Event
+}
+
+func (r *recordingSink) Record(e events.Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, e)
+	return nil
+}
+
+func (r *recordingSink) count(t events.Type) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for _, e := range r.events {
+		if e.Type == t {
+			n++
+		}
+	}
+	return n
+}
+
+func TestWatch_RecordsEachExitOnce(t *testing.T) {
+	mockRepo := &MockRepo{
+		localCommits:  []string{"abc123"},
+		remoteCommits: []string{"abc123"},
+		compareResult: git.CommitsEqual,
+	}
+
+	sink := &recordingSink{}
+	cfg := &config.Config{
+		Command:      []string{"true"},
+		Logger:       logger.New(),
+		RunOnStart:   true,
+		PollInterval: 50 * time.Millisecond,
+		Events:       sink,
+	}
+
+	go func() {
+		_ = Run(cfg, WithRepository(mockRepo))
+	}()
+
+	// Several ticks pass after the command exits
+	time.Sleep(500 * time.Millisecond)
+
+	if got := sink.count(events.Start); got != 1 {
+		t.Errorf("recorded %d start events, want 1", got)
+	}
+	// A clean exit isn't a crash
+	if got := sink.count(events.Exit); got != 1 {
+		t.Errorf("recorded %d exit events, want 1", got)
+	}
+	if got := sink.count(events.Crash); got != 0 {
+		t.Errorf("recorded %d crash events, want 0", got)
+	}
+}
+
+// unknownExitProcessManager can't tell how the command ended
+type unknownExitProcessManager struct {
+	*TestProcessManager
+}
+
+func (pm unknownExitProcessManager) GetExitStatus() *events.ExitStatus {
+	return nil
+}
+
+func TestWatch_ExitWithChild(t *testing.T) {
+	mockRepo := &MockRepo{
+		localCommits:  []string{"abc123"},
+		remoteCommits: []string{"abc123"},
+		compareResult: git.CommitsEqual,
+	}
+
+	sink := &recordingSink{}
+	cfg := &config.Config{
+		Command:       []string{"sh", "-c", "exit 3"},
+		Logger:        logger.New(),
+		RunOnStart:    true,
+		PollInterval:  time.Hour,
+		Events:        sink,
+		ExitWithChild: true,
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- Run(cfg, WithRepository(mockRepo))
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run() didn't return after the command exited")
+	}
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) || exitErr.Status.Code != 3 {
+		t.Fatalf("Run() error = %v, want exit code 3", err)
+	}
+
+	t.Run("unknown exit status", func(t *testing.T) {
+		cfg := &config.Config{
+			Command:       []string{"true"},
+			Logger:        logger.New(),
+			RunOnStart:    true,
+			PollInterval:  time.Hour,
+			ExitWithChild: true,
+		}
+		pm := unknownExitProcessManager{NewTestProcessManager(cfg, make(chan struct{}, 1))}
+
+		done := make(chan error, 1)
+		go func() {
+			done <- Run(cfg, WithRepository(mockRepo), WithProcessManager(pm))
+		}()
+
+		var err error
+		select {
+		case err = <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Run() didn't return after the command exited")
+		}
+		var exitErr *ExitError
+		if !errors.As(err, &exitErr) || exitErr.Status.Code == 0 {
+			t.Fatalf("Run() error = %v, want a nonzero exit code", err)
+		}
+	})
+
+	if got := sink.count(events.Crash); got != 1 {
+		t.Errorf("recorded %d crash events, want 1", got)
+	}
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	for _, e := range sink.events {
+		if e.Type == events.Crash && (e.Exit == nil || e.Exit.Code != 3) {
+			t.Errorf("crash event exit = %+v, want code 3", e.Exit)
+		}
+	}
+}
+
+func TestWatch_Control(t *testing.T) {
+	mockRepo := &MockRepo{
+		localCommits:  []string{"abc123"},
+		remoteCommits: []string{"abc123"},
+		compareResult: git.CommitsEqual,
+	}
+
+	executions := make(chan struct{}, 10)
+	cfg := &config.Config{
+		Command:      []string{"sleep", "10"},
+		Logger:       logger.New(),
+		RunOnStart:   true,
+		PollInterval: time.Hour,
+	}
+	pm := NewTestProcessManager(cfg, executions)
+
+	requests := make(chan control.Request)
+	done := make(chan error, 1)
+	go func() {
+		done <- Run(cfg, WithRepository(mockRepo), WithProcessManager(pm), WithControl(requests))
+	}()
+	waitForExecution(t, executions)
+
+	resp := send(t, requests, control.CommandStatus)
+	if !resp.OK || resp.Status == nil {
+		t.Fatalf("status response = %+v", resp)
+	}
+	firstPID := resp.Status.Process.PID
+	if resp.Status.Commit != "abc123" || !resp.Status.Process.Running || firstPID == 0 {
+		t.Errorf("status = %+v, want abc123 with a running process", resp.Status)
+	}
+
+	if resp := send(t, requests, control.CommandRestart); !resp.OK {
+		t.Fatalf("restart response = %+v", resp)
+	}
+	waitForExecution(t, executions)
+	if pid := send(t, requests, control.CommandStatus).Status.Process.PID; pid == firstPID {
+		t.Errorf("process PID unchanged after restart: %d", pid)
+	}
+
+	if resp := send(t, requests, control.CommandStop); !resp.OK {
+		t.Fatalf("stop response = %+v", resp)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Run() error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run() didn't return after stop")
+	}
+	if pm.IsRunning() {
+		t.Error("process still running after stop")
+	}
+}
+
+func TestWatch_Reload(t *testing.T) {
+	mockRepo := &MockRepo{
+		localCommits:  []string{"abc123"},
+		remoteCommits: []string{"abc123"},
+		compareResult: git.CommitsEqual,
+	}
+
+	executions := make(chan struct{}, 10)
+	cfg := &config.Config{
+		Command:      []string{"sleep", "10"},
+		Logger:       logger.New(),
+		RunOnStart:   true,
+		PollInterval: time.Hour,
+		Mode:         config.ModeRemote,
+	}
+	pm := NewTestProcessManager(cfg, executions)
+
+	// next is what the configuration reads as on the next reload
+	var next config.Config
+	load := func() (*config.Config, error) {
+		loaded := next
+		loaded.Logger = logger.New()
+		return &loaded, nil
+	}
+
+	requests := make(chan control.Request)
+	done := make(chan error, 1)
+	go func() {
+		done <- Run(cfg, WithRepository(mockRepo), WithProcessManager(pm), WithControl(requests), WithReload(load))
+	}()
+	waitForExecution(t, executions)
+
+	reload := func() control.Response {
+		req := control.NewRequest(control.CommandReload)
+		requests <- req
+		resp, ok := req.Wait(5 * time.Second)
+		if !ok {
+			t.Fatal("no response to reload")
+		}
+		return resp
+	}
+	firstPID := pm.GetPID()
+
+	// Live settings don't touch the command
+	next = *cfg
+	next.PollInterval = time.Minute
+	next.LogLevel = logger.VerboseLevel
+	next.Mode = config.ModeLocal
+	if resp := reload(); !resp.OK {
+		t.Fatalf("reload response = %+v", resp)
+	}
+	if cfg.PollInterval != time.Minute || cfg.Logger.Level() != logger.VerboseLevel {
+		t.Errorf("interval %s and log level %s not applied", cfg.PollInterval, cfg.Logger.Level())
+	}
+	if cfg.Mode != config.ModeRemote {
+		t.Errorf("mode changed to %q without restarting pull-watch", cfg.Mode)
+	}
+	if pm.GetPID() != firstPID {
+		t.Error("command restarted for a live setting")
+	}
+
+	// A new environment restarts it
+	next.Env = []string{"PORT=8080"}
+	if resp := reload(); !resp.OK {
+		t.Fatalf("reload response = %+v", resp)
+	}
+	waitForExecution(t, executions)
+	if pm.GetPID() == firstPID {
+		t.Error("command not restarted after its environment changed")
+	}
+
+	if resp := send(t, requests, control.CommandStop); !resp.OK {
+		t.Fatalf("stop response = %+v", resp)
+	}
+	<-done
+}
+
+func TestRestart_ReloadSignal(t *testing.T) {
+	reloadSettle = 100 * time.Millisecond
+	reloadCheckInterval = 50 * time.Millisecond
+	defer func() {
+		reloadSettle = time.Second
+		reloadCheckInterval = 500 * time.Millisecond
+	}()
+
+	dir := t.TempDir()
+	reloads := filepath.Join(dir, "reloads")
+	// Counts SIGHUPs and keeps running
+	reloadable := fmt.Sprintf("trap 'echo reload >> %s' HUP; while :; do sleep 0.05; done", reloads)
+
+	tests := []struct {
+		name        string
+		command     []string
+		check       string
+		wantRestart bool
+	}{
+		{name: "reloads in place", command: []string{"sh", "-c", reloadable}},
+		{name: "ready", command: []string{"sh", "-c", reloadable}, check: "test -s " + reloads},
+		{name: "exits on signal", command: []string{"sleep", "10"}, wantRestart: true},
+		{name: "never ready", command: []string{"sh", "-c", reloadable}, check: "false", wantRestart: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Remove(reloads)
+			sink := &recordingSink{}
+			cfg := &config.Config{
+				Command:       tt.command,
+				Logger:        logger.New(),
+				ReloadSignal:  "SIGHUP",
+				ReloadCheck:   tt.check,
+				ReloadTimeout: 300 * time.Millisecond,
+				Events:        sink,
+			}
+			pm := New(cfg)
+			if err := pm.Start(); err != nil {
+				t.Fatal(err)
+			}
+			defer pm.Stop()
+			// Give the shell time to set up its trap
+			time.Sleep(100 * time.Millisecond)
+			firstPID := pm.GetPID()
+
+			if err := restart(context.Background(), cfg, &MockRepo{}, pm, "def456"); err != nil {
+				t.Fatalf("restart() error = %v", err)
+			}
+
+			if restarted := pm.GetPID() != firstPID; restarted != tt.wantRestart {
+				t.Errorf("restarted = %v, want %v", restarted, tt.wantRestart)
+			}
+			if !pm.IsRunning() {
+				t.Error("command not running after restart()")
+			}
+			if got := sink.count(events.Reload); got != 1 {
+				t.Errorf("recorded %d reload events, want 1", got)
+			}
+			if _, err := os.Stat(reloads); !tt.wantRestart && err != nil {
+				t.Error("command didn't receive the reload signal")
+			}
+		})
+	}
+}
+
+func TestDiffConfig(t *testing.T) {
+	cfg := &config.Config{
+		PollInterval: 15 * time.Second,
+		Command:      []string{"npm", "start"},
+		Logger:       logger.New(),
+		History:      true,
+	}
+	next := *cfg
+	next.PollInterval = 30 * time.Second
+	next.WatchExclude = []string{"*.log"}
+	next.Command = []string{"npm", "run", "dev"}
+	next.History = false
+
+	var names []string
+	for _, change := range diffConfig(cfg, &next) {
+		names = append(names, change.setting.name)
+	}
+	want := []string{"interval", "watch-exclude", "command", "no-history"}
+	if !reflect.DeepEqual(names, want) {
+		t.Fatalf("diffConfig() = %v, want %v", names, want)
+	}
+
+	if !applyConfig(cfg, &next, diffConfig(cfg, &next)) {
+		t.Error("applyConfig() should ask for a command restart")
+	}
+	if cfg.PollInterval != next.PollInterval || !reflect.DeepEqual(cfg.Command, next.Command) {
+		t.Errorf("applyConfig() didn't apply the live settings: %+v", cfg)
+	}
+	if !cfg.History {
+		t.Error("applyConfig() applied a setting that needs a restart of pull-watch")
+	}
+	if changes := diffConfig(cfg, &next); len(changes) != 1 || changes[0].setting.name != "no-history" {
+		t.Errorf("diffConfig() after apply = %v", changes)
+	}
+}
+
+// send makes a control request and waits for the response
+func send(t *testing.T, requests chan<- control.Request, cmd control.Command) control.Response {
+	t.Helper()
+	req := control.NewRequest(cmd)
+	requests <- req
+	resp, ok := req.Wait(5 * time.Second)
+	if !ok {
+		t.Fatalf("no response to %s", cmd)
+	}
+	return resp
+}
+
+func waitForExecution(t *testing.T, executions chan struct{}) {
+	t.Helper()
+	select {
+	case <-executions:
+	case <-time.After(5 * time.Second):
+		t.Fatal("command was not started")
+	}
+}
+
 // Helper function to drain the executions channel
 func drainExecutions(ch chan struct{}) {
 	for {
@@ -528,3 +1192,553 @@ func drainExecutions(ch chan struct{}) {
 		}
 	}
 }
+
+func TestPollBackoff(t *testing.T) {
+	tests := []struct {
+		interval, max time.Duration
+		failures      int
+		want          time.Duration
+	}{
+		{interval: 15 * time.Second, max: 5 * time.Minute, failures: 1, want: 30 * time.Second},
+		{interval: 15 * time.Second, max: 5 * time.Minute, failures: 3, want: 2 * time.Minute},
+		{interval: 15 * time.Second, max: 5 * time.Minute, failures: 10, want: 5 * time.Minute},
+		{interval: 15 * time.Second, max: 0, failures: 3, want: 15 * time.Second},
+		{interval: 10 * time.Minute, max: 5 * time.Minute, failures: 3, want: 10 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		if got := pollBackoff(tt.interval, tt.max, tt.failures); got != tt.want {
+			t.Errorf("pollBackoff(%s, %s, %d) = %s, want %s", tt.interval, tt.max, tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestOutage_WarnsOnce(t *testing.T) {
+	defer func(d time.Duration) { unreachableWarnAfter = d }(unreachableWarnAfter)
+	unreachableWarnAfter = 20 * time.Millisecond
+
+	sink := &recordingSink{}
+	cfg := &config.Config{
+		PollInterval: time.Second,
+		MaxInterval:  4 * time.Second,
+		Logger:       logger.New(logger.WithLogLevel(logger.QuietLevel)),
+		Events:       sink,
+	}
+	repo := &MockRepo{localCommits: []string{"abc123"}, remoteCommits: []string{"abc123"}}
+	err := fmt.Errorf("fatal: unable to access 'https://example.com/repo.git/': Could not resolve host: example.com")
+
+	var o outage
+	var intervals []time.Duration
+	for i := 0; i < 4; i++ {
+		intervals = append(intervals, o.fail(context.Background(), cfg, repo, err))
+		time.Sleep(10 * time.Millisecond)
+	}
+	if want := []time.Duration{2 * time.Second, 4 * time.Second, 4 * time.Second, 4 * time.Second}; !reflect.DeepEqual(intervals, want) {
+		t.Errorf("poll intervals = %v, want %v", intervals, want)
+	}
+	if got := sink.count(events.Error); got != 1 {
+		t.Errorf("recorded %d error events, want 1", got)
+	}
+
+	if !o.recover(cfg) {
+		t.Error("recover() = false after an outage")
+	}
+	if o.recover(cfg) {
+		t.Error("recover() = true without an outage")
+	}
+}
+
+func TestCheck(t *testing.T) {
+	mockRepo := &MockRepo{
+		localCommits:  []string{"abc123"},
+		remoteCommits: []string{"def456"},
+		compareResult: git.AIsAncestorOfB,
+	}
+
+	cfg := &config.Config{Logger: logger.New()}
+	result, err := Check(cfg, WithRepository(mockRepo))
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+
+	if result.Status != git.AIsAncestorOfB {
+		t.Errorf("Check() status = %v, want %v", result.Status, git.AIsAncestorOfB)
+	}
+	if result.LocalCommit != "abc123" || result.RemoteCommit != "def456" || result.Branch != "main" {
+		t.Errorf("Check() = %+v, want abc123/def456 on main", result)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	tests := []struct {
+		name          string
+		compareResult git.CommitComparisonResult
+		runOnStart    bool
+		touchError    error
+		command       []string
+		want          int
+	}{
+		{
+			name:          "changes pulled - command exit status",
+			compareResult: git.AIsAncestorOfB,
+			command:       []string{"sh", "-c", "exit 3"},
+			want:          3,
+		},
+		{
+			name:          "no changes - command not run",
+			compareResult: git.CommitsEqual,
+			command:       []string{"sh", "-c", "exit 3"},
+			want:          0,
+		},
+		{
+			name:          "no changes - run on start",
+			compareResult: git.CommitsEqual,
+			runOnStart:    true,
+			command:       []string{"sh", "-c", "exit 5"},
+			want:          5,
+		},
+		{
+			name:          "changes pulled - changed files unknown, command run",
+			compareResult: git.AIsAncestorOfB,
+			touchError:    errors.New("diff failed"),
+			command:       []string{"sh", "-c", "exit 4"},
+			want:          4,
+		},
+		{
+			name:          "changes pulled - no command",
+			compareResult: git.AIsAncestorOfB,
+			want:          0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := &MockRepo{
+				localCommits:  []string{"abc123"},
+				remoteCommits: []string{"def456"},
+				compareResult: tt.compareResult,
+				touchError:    tt.touchError,
+			}
+
+			cfg := &config.Config{
+				Command:    tt.command,
+				Logger:     logger.New(),
+				RunOnStart: tt.runOnStart,
+			}
+
+			got, err := Update(cfg, WithRepository(mockRepo))
+			if err != nil {
+				t.Fatalf("Update() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Update() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdate_RunsInRepository(t *testing.T) {
+	repoDir, live := t.TempDir(), t.TempDir()
+	deploys := t.TempDir()
+	if err := os.Symlink(live, filepath.Join(deploys, currentLink)); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		deploy config.Deploy
+		want   string
+	}{
+		{name: "checkout", want: repoDir},
+		{name: "worktree deploys", deploy: config.Deploy{Mode: config.DeployWorktree, Dir: deploys}, want: live},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := &MockRepo{
+				localCommits:  []string{"abc123"},
+				remoteCommits: []string{"abc123"},
+				compareResult: git.CommitsEqual,
+			}
+			cfg := &config.Config{
+				GitDir:     repoDir,
+				Command:    []string{"sh", "-c", "pwd -P > ran"},
+				Logger:     logger.New(),
+				RunOnStart: true,
+				Deploy:     tt.deploy,
+			}
+
+			if got, err := Update(cfg, WithRepository(mockRepo)); err != nil || got != 0 {
+				t.Fatalf("Update() = %d, %v", got, err)
+			}
+			data, err := os.ReadFile(filepath.Join(tt.want, "ran"))
+			if err != nil {
+				t.Fatalf("the command didn't run in %s: %v", tt.want, err)
+			}
+			if want, _ := filepath.EvalSymlinks(tt.want); strings.TrimSpace(string(data)) != want {
+				t.Errorf("the command ran in %s, want %s", strings.TrimSpace(string(data)), want)
+			}
+		})
+	}
+}
+
+// touchRecordingRepo is a MockRepo that records the range TouchesCheckout was asked about
+type touchRecordingRepo struct {
+	*MockRepo
+	from, to string
+}
+
+func (r *touchRecordingRepo) TouchesCheckout(ctx context.Context, from, to string) (bool, error) {
+	r.from, r.to = from, to
+	return r.MockRepo.TouchesCheckout(ctx, from, to)
+}
+
+func TestCheckAndUpdate_DivergedPull(t *testing.T) {
+	for _, strategy := range []string{config.PullRebase, config.PullMerge} {
+		t.Run(strategy, func(t *testing.T) {
+			mockRepo := &MockRepo{
+				localCommits:  []string{"abc123"},
+				remoteCommits: []string{"def456"},
+			}
+			// Rebasing or merging the diverged branch puts HEAD on a commit of its own
+			mockRepo.compareHandler = func(local, remote string) git.CommitComparisonResult {
+				mockRepo.setLocalCommit("fed789")
+				return git.AIsAncestorOfB
+			}
+			repo := &touchRecordingRepo{MockRepo: mockRepo}
+
+			cfg := &config.Config{
+				Command:      []string{"true"},
+				Logger:       logger.New(),
+				PullStrategy: strategy,
+			}
+			pm := New(cfg)
+			defer pm.Stop()
+
+			lastCommit := "abc123"
+			if err := checkAndUpdate(context.Background(), cfg, repo, &lastCommit, &failedDeploy{}, pm, false); err != nil {
+				t.Fatalf("checkAndUpdate() error = %v", err)
+			}
+			if lastCommit != "fed789" {
+				t.Errorf("last commit = %s, want the new HEAD fed789", lastCommit)
+			}
+			if repo.from != "abc123" || repo.to != "fed789" {
+				t.Errorf("TouchesCheckout(%s, %s), want TouchesCheckout(abc123, fed789)", repo.from, repo.to)
+			}
+		})
+	}
+}
+
+// unauthorizedRepo is a MockRepo whose remote turns down the credentials
+type unauthorizedRepo struct {
+	*MockRepo
+}
+
+func (r unauthorizedRepo) GetRemoteCommit(ctx context.Context) (string, error) {
+	return "", &errz.CommandError{Command: "git", ExitCode: 128, Stderr: "fatal: Authentication failed for 'https://example.com/repo.git/'\n", Err: errors.New("exit status 128"), Kind: errz.ErrAuth}
+}
+
+// versionExecutor answers git version like an installed git would
+type versionExecutor struct {
+	cfg *config.Config
+}
+
+func (e versionExecutor) ExecuteCommand(ctx context.Context, name string, args ...string) (string, error) {
+	return "git version 2.45.0\n", nil
+}
+
+func (e versionExecutor) GetConfig() *config.Config {
+	return e.cfg
+}
+
+func TestDiagnose(t *testing.T) {
+	repo := unauthorizedRepo{&MockRepo{localCommits: []string{"abc123"}, remoteCommits: []string{"def456"}}}
+	cfg := &config.Config{GitDir: ".", Command: []string{"no-such-command-for-pull-watch"}, Logger: logger.New()}
+
+	diagnose := func() (map[string]DiagnosisStatus, map[string]string) {
+		statuses := map[string]DiagnosisStatus{}
+		fixes := map[string]string{}
+		for _, d := range Diagnose(cfg, WithRepository(repo), WithExecutor(versionExecutor{cfg})) {
+			statuses[d.Check] = d.Status
+			fixes[d.Check] = d.Fix
+		}
+		return statuses, fixes
+	}
+	statuses, fixes := diagnose()
+
+	want := map[string]DiagnosisStatus{
+		"git":          DiagnosisPass,
+		"repository":   DiagnosisPass,
+		"branch":       DiagnosisPass,
+		"remote":       DiagnosisFail,
+		"working tree": DiagnosisPass,
+		"command":      DiagnosisFail,
+		"signals":      DiagnosisPass,
+	}
+	for check, status := range want {
+		if statuses[check] != status {
+			t.Errorf("Diagnose() %s = %v, want %v", check, statuses[check], status)
+		}
+	}
+	if fixes["remote"] != Hint(errz.ErrAuth) {
+		t.Errorf("Diagnose() remote fix = %q, want the credentials hint", fixes["remote"])
+	}
+
+	// Local mode never talks to the remote
+	cfg.Mode = config.ModeLocal
+	if statuses, _ := diagnose(); statuses["remote"] != DiagnosisSkip {
+		t.Errorf("Diagnose() remote in local mode = %v, want %v", statuses["remote"], DiagnosisSkip)
+	}
+}
+
+func TestDeployCommit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	run := func(args ...string) string {
+		t.Helper()
+		out, err := exec.Command("git", append([]string{"-C", dir, "-c", "user.email=a@b", "-c", "user.name=a"}, args...)...).CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	run("init", "-q")
+	var commits []string
+	for _, file := range []string{"one", "two", "BROKEN"} {
+		if err := os.WriteFile(filepath.Join(dir, file), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		run("add", file)
+		run("commit", "-q", "-m", file)
+		commits = append(commits, run("rev-parse", "HEAD"))
+	}
+
+	cfg := &config.Config{
+		GitDir: dir,
+		Logger: logger.New(),
+		Deploy: config.Deploy{Mode: config.DeployWorktree, Dir: filepath.Join(t.TempDir(), "deploys"), Build: "test ! -e BROKEN && touch built", Keep: 1},
+	}
+	repo := git.New(cfg)
+	ctx := context.Background()
+	live := func() string {
+		t.Helper()
+		target, err := os.Readlink(filepath.Join(cfg.Deploy.Dir, currentLink))
+		if err != nil {
+			t.Fatalf("no current symlink: %v", err)
+		}
+		return target
+	}
+
+	for _, commit := range commits[:2] {
+		if err := deployCommit(ctx, cfg, repo, commit); err != nil {
+			t.Fatalf("deployCommit(%s) error = %v", commit, err)
+		}
+		if got := live(); got != events.ShortCommit(commit) {
+			t.Errorf("current -> %s, want %s", got, events.ShortCommit(commit))
+		}
+		if _, err := os.Stat(filepath.Join(cfg.Deploy.Dir, live(), "built")); err != nil {
+			t.Errorf("the build didn't run in the new worktree: %v", err)
+		}
+	}
+
+	// The command still runs in the first worktree until it's restarted
+	first := filepath.Join(cfg.Deploy.Dir, events.ShortCommit(commits[0]))
+	cfg.Command = []string{"sleep", "5"}
+	pm := New(cfg)
+	pm.SetDir(first)
+	if err := pm.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer pm.Stop()
+	// Touched by a build, the first worktree looks newer than the live one
+	future := time.Now().Add(time.Hour)
+	if err := os.Chtimes(first, future, future); err != nil {
+		t.Fatal(err)
+	}
+	pruneWorktrees(ctx, cfg, repo, pm)
+	if _, err := os.Stat(first); err != nil {
+		t.Errorf("the worktree the command runs in was removed: %v", err)
+	}
+
+	if err := pm.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if err := useLiveWorktree(ctx, cfg, repo, pm); err != nil {
+		t.Fatalf("useLiveWorktree() error = %v", err)
+	}
+	if err := pm.Start(); err != nil {
+		t.Fatal(err)
+	}
+	pruneWorktrees(ctx, cfg, repo, pm)
+	if _, err := os.Stat(first); !os.IsNotExist(err) {
+		t.Error("the first worktree should be removed with -deploy-keep 1 once the command left it")
+	}
+
+	if err := deployCommit(ctx, cfg, repo, commits[2]); err == nil {
+		t.Error("deployCommit() should fail when the build does")
+	}
+	if got := live(); got != events.ShortCommit(commits[1]) {
+		t.Errorf("current -> %s after a failed build, want it left at %s", got, events.ShortCommit(commits[1]))
+	}
+	if _, err := os.Stat(filepath.Join(cfg.Deploy.Dir, events.ShortCommit(commits[2]))); !os.IsNotExist(err) {
+		t.Error("the worktree of the failed build should be removed")
+	}
+
+	want, _ := filepath.EvalSymlinks(filepath.Join(cfg.Deploy.Dir, events.ShortCommit(commits[1])))
+	if got := pm.GetDir(); got != want {
+		t.Errorf("command runs in %q, want %q", got, want)
+	}
+}
+
+// upToDateRepo is a real repository whose remote is always on the local HEAD
+type upToDateRepo struct {
+	*git.GitRepository
+}
+
+func (r upToDateRepo) GetRemoteCommit(ctx context.Context) (string, error) {
+	return r.GetLatestCommit(ctx)
+}
+
+func TestCheckAndUpdate_FailedBuildRunsOnce(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	run := func(args ...string) string {
+		t.Helper()
+		out, err := exec.Command("git", append([]string{"-C", dir, "-c", "user.email=a@b", "-c", "user.name=a"}, args...)...).CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	run("init", "-q")
+	run("commit", "-q", "--allow-empty", "-m", "live")
+	live := run("rev-parse", "HEAD")
+	// Pulled, but its build fails every time
+	run("commit", "-q", "--allow-empty", "-m", "broken")
+
+	builds := filepath.Join(t.TempDir(), "builds")
+	cfg := &config.Config{
+		GitDir:  dir,
+		Command: []string{"true"},
+		Logger:  logger.New(),
+		Deploy:  config.Deploy{Mode: config.DeployWorktree, Dir: filepath.Join(t.TempDir(), "deploys"), Build: "echo >> " + builds + " && false", Keep: 1},
+	}
+	repo := upToDateRepo{git.New(cfg)}
+	pm := New(cfg)
+	defer pm.Stop()
+
+	countBuilds := func() int {
+		data, _ := os.ReadFile(builds)
+		return strings.Count(string(data), "\n")
+	}
+
+	lastCommit := live
+	var failed failedDeploy
+	ctx := context.Background()
+	if err := checkAndUpdate(ctx, cfg, repo, &lastCommit, &failed, pm, false); err == nil {
+		t.Error("the first failed build should be reported")
+	}
+	for i := 0; i < 3; i++ {
+		if err := checkAndUpdate(ctx, cfg, repo, &lastCommit, &failed, pm, false); err != nil {
+			t.Errorf("tick %d: the failed build was reported again: %v", i, err)
+		}
+	}
+	if got := countBuilds(); got != 1 {
+		t.Errorf("build ran %d times, want once per commit", got)
+	}
+	if lastCommit != live {
+		t.Errorf("last commit = %s, want it left at the live %s", lastCommit, live)
+	}
+
+	// Once the retry delay passed the build runs again, without another report
+	defer func(retry time.Duration) { failedDeployRetry = retry }(failedDeployRetry)
+	failedDeployRetry = 0
+	if err := checkAndUpdate(ctx, cfg, repo, &lastCommit, &failed, pm, false); err != nil {
+		t.Errorf("the failed build was reported again after the retry delay: %v", err)
+	}
+	if got := countBuilds(); got != 2 {
+		t.Errorf("build ran %d times, want a retry after the delay", got)
+	}
+}
+
+func TestWatch_ReloadWhileWatching(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	for _, args := range [][]string{{"init", "-q"}, {"-c", "user.email=a@b", "-c", "user.name=a", "commit", "-q", "--allow-empty", "-m", "live"}} {
+		if out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+
+	cfg := &config.Config{
+		GitDir: dir,
+		// Keeps the output copiers busy
+		Command:       []string{"sh", "-c", "while :; do echo out; echo err >&2; sleep 0.01; done"},
+		Logger:        logger.New(),
+		RunOnStart:    true,
+		PollInterval:  time.Hour,
+		Mode:          config.ModeRemote,
+		OutputFormat:  output.FormatPrefixed,
+		WatchTree:     true,
+		WatchDebounce: 10 * time.Millisecond,
+	}
+	repo := upToDateRepo{git.New(cfg)}
+	pm := New(cfg, WithOutput(io.Discard, io.Discard))
+
+	next := *cfg
+	load := func() (*config.Config, error) {
+		loaded := next
+		loaded.Logger = logger.New()
+		return &loaded, nil
+	}
+
+	requests := make(chan control.Request)
+	done := make(chan error, 1)
+	go func() {
+		done <- Run(cfg, WithRepository(repo), WithProcessManager(pm), WithControl(requests), WithReload(load))
+	}()
+
+	// New files make the tree watcher ask git whether they're ignored
+	stopWriting := make(chan struct{})
+	writing := make(chan struct{})
+	go func() {
+		defer close(writing)
+		for i := 0; ; i++ {
+			select {
+			case <-stopWriting:
+				return
+			default:
+			}
+			os.WriteFile(filepath.Join(dir, fmt.Sprintf("file%d", i)), nil, 0o644)
+			time.Sleep(5 * time.Millisecond)
+		}
+	}()
+	defer func() {
+		close(stopWriting)
+		<-writing
+	}()
+
+	for i := 0; i < 10; i++ {
+		next.Auth.SSHKey = fmt.Sprintf("/keys/id%d", i)
+		next.GitTimeouts.LsRemote = time.Duration(i+1) * time.Second
+		next.OutputFormat = []output.Format{output.FormatJSON, output.FormatPrefixed}[i%2]
+		if resp := send(t, requests, control.CommandReload); !resp.OK {
+			t.Fatalf("reload response = %+v", resp)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if cfg.Auth.SSHKey != "/keys/id9" {
+		t.Errorf("ssh key = %q after reloading", cfg.Auth.SSHKey)
+	}
+
+	if resp := send(t, requests, control.CommandStop); !resp.OK {
+		t.Fatalf("stop response = %+v", resp)
+	}
+	<-done
+}
@@ -0,0 +1,25 @@
+package runner
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ship-digital/pull-watch/internal/config"
+	"github.com/ship-digital/pull-watch/internal/events"
+	"github.com/ship-digital/pull-watch/internal/sdnotify"
+)
+
+// notifyServiceManager reports state changes when running as a systemd Type=notify service
+func notifyServiceManager(cfg *config.Config, states ...string) {
+	if _, err := sdnotify.Notify(strings.Join(states, "\n")); err != nil {
+		cfg.Logger.Debug("Failed to notify the service manager: %v", err)
+	}
+}
+
+// serviceStatus describes what the runner is doing in a line for "systemctl status"
+func serviceStatus(commit string, pm Processor) string {
+	if pm.IsRunning() {
+		return fmt.Sprintf("Running PID %d at %s", pm.GetPID(), events.ShortCommit(commit))
+	}
+	return fmt.Sprintf("Watching at %s, command not running", events.ShortCommit(commit))
+}
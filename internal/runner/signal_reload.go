@@ -0,0 +1,103 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/ship-digital/pull-watch/internal/config"
+	"github.com/ship-digital/pull-watch/internal/logger"
+)
+
+var (
+	// reloadSettle is how long a reloaded command has to stay up when there is no readiness check
+	reloadSettle = time.Second
+	// reloadCheckInterval is how often the readiness check runs until it passes
+	reloadCheckInterval = 500 * time.Millisecond
+)
+
+// errExitedOnReload is returned when the command exits after the reload signal
+var errExitedOnReload = errors.New("command exited after the reload signal")
+
+// reloadInPlace sends cfg.ReloadSignal to the running command and waits until it is
+// ready again. An error means the command has to be restarted instead.
+func reloadInPlace(ctx context.Context, cfg *config.Config, pm Processor) error {
+	done := pm.GetDoneChan()
+
+	pm.GetLogger().MultiColor(logger.DefaultLevel,
+		logger.InfoSegment("Reloading command, sending "),
+		logger.HighlightSegment(cfg.ReloadSignal),
+		logger.InfoSegment(" to PID "),
+		logger.HighlightSegment(fmt.Sprintf("%d", pm.GetPID())),
+	)
+	if err := pm.Signal(cfg.ReloadSignal); err != nil {
+		return fmt.Errorf("failed to send %s: %w", cfg.ReloadSignal, err)
+	}
+	if cfg.DryRun {
+		return nil
+	}
+
+	if cfg.ReloadCheck == "" {
+		select {
+		case <-done:
+			return errExitedOnReload
+		case <-time.After(reloadSettle):
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	timeout := cfg.ReloadTimeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	deadline := time.After(timeout)
+	ticker := time.NewTicker(reloadCheckInterval)
+	defer ticker.Stop()
+
+	var lastErr error
+	for {
+		// The first check waits a tick, so it doesn't ask the command before it reloaded
+		select {
+		case <-done:
+			return errExitedOnReload
+		case <-deadline:
+			return fmt.Errorf("readiness check didn't pass within %s: %w", timeout, lastErr)
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		if lastErr = runReadinessCheck(ctx, cfg.ReloadCheck); lastErr == nil {
+			pm.GetLogger().Info("Command reloaded and ready")
+			return nil
+		}
+		cfg.Logger.Debug("Readiness check failed: %v", lastErr)
+	}
+}
+
+// runReadinessCheck runs check with the shell, it passes when it exits with status 0
+func runReadinessCheck(ctx context.Context, check string) error {
+	cmd := shellCommand(ctx, check)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		if len(out) > 0 {
+			line, _, _ := strings.Cut(strings.TrimSpace(string(out)), "\n")
+			return fmt.Errorf("%w: %s", err, line)
+		}
+		return err
+	}
+	return nil
+}
+
+// shellCommand runs line with the platform's shell
+func shellCommand(ctx context.Context, line string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", "/C", line)
+	}
+	return exec.CommandContext(ctx, "sh", "-c", line)
+}
@@ -0,0 +1,101 @@
+package runner
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ship-digital/pull-watch/internal/config"
+)
+
+// killSignal ends every stop sequence, the command can't ignore it
+const killSignal = "SIGKILL"
+
+// ParseStopSequence parses a comma separated list of signal[:timeout] steps,
+// like "SIGINT:10s,SIGTERM:5s,SIGKILL". Every step but SIGKILL needs a timeout.
+func ParseStopSequence(spec string) ([]config.StopStep, error) {
+	var steps []config.StopStep
+	parts := strings.Split(spec, ",")
+	for i, part := range parts {
+		name, timeout, hasTimeout := strings.Cut(strings.TrimSpace(part), ":")
+		signal, err := CanonicalSignal(name)
+		if err != nil {
+			return nil, err
+		}
+
+		step := config.StopStep{Signal: signal}
+		if hasTimeout {
+			if step.Timeout, err = time.ParseDuration(timeout); err != nil || step.Timeout <= 0 {
+				return nil, fmt.Errorf("invalid timeout %q for %s", timeout, signal)
+			}
+		} else if signal != killSignal {
+			return nil, fmt.Errorf("%s needs a timeout (e.g. %s:5s)", signal, signal)
+		}
+		if signal == killSignal && i < len(parts)-1 {
+			return nil, fmt.Errorf("%s must be the last step", killSignal)
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+// CanonicalSignal validates a signal name for this platform and returns it in
+// its SIGNAME form, "int", "INT" and "SIGINT" are all accepted
+func CanonicalSignal(name string) (string, error) {
+	name = strings.ToUpper(strings.TrimSpace(name))
+	if !strings.HasPrefix(name, "SIG") {
+		name = "SIG" + name
+	}
+	if _, err := lookupSignal(name); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// StopSequence returns the steps taken to stop the command. It always ends with
+// SIGKILL, so the command is gone once the last timeout passed.
+func StopSequence(cfg *config.Config) []config.StopStep {
+	var steps []config.StopStep
+	switch {
+	case len(cfg.StopSequence) > 0:
+		steps = append(steps, cfg.StopSequence...)
+	case cfg.GracefulStop || cfg.StopSignal != "":
+		signal := cfg.StopSignal
+		if signal == "" {
+			signal = "SIGTERM"
+		}
+		steps = append(steps, config.StopStep{Signal: signal, Timeout: cfg.StopTimeout})
+	}
+
+	if len(steps) == 0 || steps[len(steps)-1].Signal != killSignal {
+		steps = append(steps, config.StopStep{Signal: killSignal})
+	}
+	return steps
+}
+
+// StopDuration is how long steps can take before SIGKILL is sent
+func StopDuration(steps []config.StopStep) time.Duration {
+	var total time.Duration
+	for _, step := range steps {
+		if step.Signal == killSignal {
+			break
+		}
+		total += step.Timeout
+	}
+	return total
+}
+
+// describeStopSequence renders steps like "SIGINT, SIGKILL after 10s"
+func describeStopSequence(steps []config.StopStep) string {
+	parts := make([]string, 0, len(steps))
+	var wait time.Duration
+	for _, step := range steps {
+		if wait > 0 {
+			parts = append(parts, fmt.Sprintf("%s after %s", step.Signal, wait))
+		} else {
+			parts = append(parts, step.Signal)
+		}
+		wait = step.Timeout
+	}
+	return strings.Join(parts, ", then ")
+}
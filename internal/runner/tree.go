@@ -0,0 +1,96 @@
+package runner
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+
+	"github.com/ship-digital/pull-watch/internal/config"
+	"github.com/ship-digital/pull-watch/internal/git"
+	"github.com/ship-digital/pull-watch/internal/logger"
+	"github.com/ship-digital/pull-watch/internal/watcher"
+)
+
+// ignoreMatcher answers .gitignore questions for the tree watcher.
+// Ignored paths known at startup are answered from memory, anything else asks git once.
+// It is only used from the watcher goroutine, so it needs no locking.
+type ignoreMatcher struct {
+	ctx   context.Context
+	repo  git.Repository
+	root  string
+	known map[string]bool
+	log   *logger.Logger
+}
+
+func newIgnoreMatcher(ctx context.Context, repo git.Repository, root string, log *logger.Logger) *ignoreMatcher {
+	m := &ignoreMatcher{
+		ctx:   ctx,
+		repo:  repo,
+		root:  root,
+		known: make(map[string]bool),
+		log:   log,
+	}
+
+	ignored, err := repo.ListIgnored(ctx)
+	if err != nil {
+		log.Debug("Failed to list ignored files: %v", err)
+	}
+	for _, p := range ignored {
+		m.known[strings.TrimSuffix(p, "/")] = true
+	}
+	return m
+}
+
+func (m *ignoreMatcher) ignored(path string) bool {
+	rel, err := filepath.Rel(m.root, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return false
+	}
+	rel = filepath.ToSlash(rel)
+
+	// Anything below an ignored directory is ignored too
+	for p := rel; p != "." && p != "/"; p = filepath.ToSlash(filepath.Dir(p)) {
+		if ignored, ok := m.known[p]; ok && ignored {
+			return true
+		}
+	}
+	if ignored, ok := m.known[rel]; ok {
+		return ignored
+	}
+
+	ignored, err := m.repo.IsIgnored(m.ctx, rel)
+	if err != nil {
+		m.log.Debug("Failed to check ignore rules for %s: %v", rel, err)
+		return false
+	}
+	m.known[rel] = ignored
+	return ignored
+}
+
+// watchTree starts a filesystem watcher on the working tree.
+// It returns nil when the watcher can't be set up, tree watching is then disabled.
+func watchTree(ctx context.Context, cfg *config.Config, repo git.Repository) *watcher.Watcher {
+	root, err := filepath.Abs(cfg.GitDir)
+	if err != nil {
+		cfg.Logger.Warn("Failed to resolve %s, working tree watching disabled: %v", cfg.GitDir, err)
+		return nil
+	}
+
+	w, err := watcher.NewTreeWatcher(root, watcher.TreeOptions{
+		Include:  cfg.WatchInclude,
+		Exclude:  cfg.WatchExclude,
+		Debounce: treeDebounce(cfg),
+		Ignored:  newIgnoreMatcher(ctx, repo, root, cfg.Logger).ignored,
+	}, cfg.Logger)
+	if err != nil {
+		cfg.Logger.Warn("Failed to watch working tree, working tree watching disabled: %v", err)
+		return nil
+	}
+
+	cfg.Logger.MultiColor(logger.DefaultLevel,
+		logger.InfoSegment("Watching working tree "),
+		logger.HighlightSegment(root),
+		logger.InfoSegment(" for file changes"),
+	)
+	return w
+}
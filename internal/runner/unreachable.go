@@ -0,0 +1,79 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ship-digital/pull-watch/internal/config"
+	"github.com/ship-digital/pull-watch/internal/events"
+	"github.com/ship-digital/pull-watch/internal/git"
+	"github.com/ship-digital/pull-watch/internal/logger"
+)
+
+// unreachableWarnAfter is how long the remote may be unreachable before it is worth a warning
+var unreachableWarnAfter = 5 * time.Minute
+
+// outage tracks an unreachable remote, so it is reported once instead of on every tick
+type outage struct {
+	since    time.Time
+	failures int
+	warned   bool
+}
+
+// fail records a check that failed with a transient error and returns the
+// poll interval to use until the remote is back
+func (o *outage) fail(ctx context.Context, cfg *config.Config, repo git.Repository, err error) time.Duration {
+	if o.since.IsZero() {
+		o.since = time.Now()
+	}
+	o.failures++
+	interval := pollBackoff(cfg.PollInterval, cfg.MaxInterval, o.failures)
+
+	cfg.Logger.MultiColor(logger.VerboseLevel,
+		logger.InfoSegment("Remote unreachable, next check in "),
+		logger.HighlightSegment(interval.String()),
+		logger.InfoSegment(": "),
+		logger.HighlightSegment(fmt.Sprintf("%v", err)),
+	)
+
+	if down := time.Since(o.since); !o.warned && down >= unreachableWarnAfter {
+		o.warned = true
+		err = fmt.Errorf("remote unreachable for %s: %w", down.Round(time.Minute), err)
+		recordEvent(ctx, cfg, repo, events.Event{Type: events.Error}.WithError(err))
+		cfg.Logger.MultiColor(logger.QuietLevel,
+			logger.ErrorSegment("Remote unreachable for "),
+			logger.HighlightSegment(down.Round(time.Minute).String()),
+			logger.ErrorSegment(", still checking every "),
+			logger.HighlightSegment(interval.String()),
+		)
+	}
+	return interval
+}
+
+// recover ends the outage, it reports whether there was one
+func (o *outage) recover(cfg *config.Config) bool {
+	if o.since.IsZero() {
+		return false
+	}
+	if o.warned {
+		cfg.Logger.MultiColor(logger.DefaultLevel,
+			logger.InfoSegment("Remote reachable again after "),
+			logger.HighlightSegment(time.Since(o.since).Round(time.Second).String()),
+		)
+	}
+	*o = outage{}
+	return true
+}
+
+// pollBackoff doubles interval for every failure, up to max. No max means no backoff.
+func pollBackoff(interval, max time.Duration, failures int) time.Duration {
+	next := interval
+	for i := 0; i < failures && next < max; i++ {
+		next *= 2
+	}
+	if next > max && max > interval {
+		return max
+	}
+	return next
+}
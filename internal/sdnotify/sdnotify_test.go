@@ -0,0 +1,64 @@
+package sdnotify
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestNotify(t *testing.T) {
+	t.Setenv("NOTIFY_SOCKET", "")
+	if sent, err := Notify(Ready); sent || err != nil {
+		t.Errorf("Notify() without a socket = %v, %v, want false, nil", sent, err)
+	}
+
+	// Unix socket paths are limited to about 100 bytes, t.TempDir() can be longer
+	dir, err := os.MkdirTemp("", "sd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "notify.sock")
+
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatalf("ListenUnixgram() error = %v", err)
+	}
+	defer conn.Close()
+	t.Setenv("NOTIFY_SOCKET", path)
+
+	if sent, err := Notify(Ready + "\n" + Status("Running PID 42 at abc123")); !sent || err != nil {
+		t.Fatalf("Notify() = %v, %v", sent, err)
+	}
+
+	buf := make([]byte, 256)
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if got, want := string(buf[:n]), "READY=1\nSTATUS=Running PID 42 at abc123"; got != want {
+		t.Errorf("received %q, want %q", got, want)
+	}
+}
+
+func TestWatchdogInterval(t *testing.T) {
+	t.Setenv("WATCHDOG_USEC", "")
+	t.Setenv("WATCHDOG_PID", "")
+	if got := WatchdogInterval(); got != 0 {
+		t.Errorf("WatchdogInterval() without a watchdog = %s", got)
+	}
+
+	t.Setenv("WATCHDOG_USEC", "30000000")
+	if got := WatchdogInterval(); got != 30*time.Second {
+		t.Errorf("WatchdogInterval() = %s, want 30s", got)
+	}
+
+	t.Setenv("WATCHDOG_PID", strconv.Itoa(os.Getpid()+1))
+	if got := WatchdogInterval(); got != 0 {
+		t.Errorf("WatchdogInterval() for another process = %s, want 0", got)
+	}
+}
@@ -0,0 +1,325 @@
+package service
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Kind is a service manager a definition can be generated for
+type Kind string
+
+const (
+	// Systemd generates a Type=notify unit
+	Systemd Kind = "systemd"
+	// Launchd generates a launch daemon property list
+	Launchd Kind = "launchd"
+	// Supervisord generates a [program] block
+	Supervisord Kind = "supervisord"
+	// OpenRC generates an init script run by supervise-daemon
+	OpenRC Kind = "openrc"
+)
+
+// Kinds lists the supported service managers
+var Kinds = []Kind{Systemd, Launchd, Supervisord, OpenRC}
+
+// Restart policies
+const (
+	// RestartAlways restarts pull-watch whenever it exits
+	RestartAlways = "always"
+	// RestartOnFailure restarts pull-watch only when it exits with an error
+	RestartOnFailure = "on-failure"
+	// RestartNever leaves pull-watch stopped when it exits
+	RestartNever = "no"
+)
+
+// Spec describes how the service runs pull-watch
+type Spec struct {
+	Name        string
+	Description string
+	// Args are the full command line, starting with the pull-watch executable
+	Args       []string
+	WorkingDir string
+	User       string
+	Restart    string
+	Env        []string
+	// Watchdog is how long systemd waits for a sign of life before restarting, zero disables it
+	Watchdog time.Duration
+	// StopTimeout is how long the service manager waits for pull-watch to stop its command
+	StopTimeout time.Duration
+	// Delegate hands the cgroup subtree to pull-watch, for commands with cgroup limits
+	Delegate bool
+}
+
+// Validate checks that spec can be rendered
+func (spec Spec) Validate() error {
+	if spec.Name == "" || strings.ContainsAny(spec.Name, "/\\ \t\n") {
+		return fmt.Errorf("invalid service name %q", spec.Name)
+	}
+	if len(spec.Args) == 0 {
+		return fmt.Errorf("no command line to run")
+	}
+	switch spec.Restart {
+	case RestartAlways, RestartOnFailure, RestartNever:
+	default:
+		return fmt.Errorf("invalid restart policy %q (expected %q, %q or %q)", spec.Restart, RestartAlways, RestartOnFailure, RestartNever)
+	}
+	return nil
+}
+
+// DefaultPath returns where the definition of the named service is installed
+func DefaultPath(kind Kind, name string) string {
+	switch kind {
+	case Systemd:
+		return filepath.Join("/etc/systemd/system", name+".service")
+	case Launchd:
+		return filepath.Join("/Library/LaunchDaemons", name+".plist")
+	case Supervisord:
+		return filepath.Join("/etc/supervisor/conf.d", name+".conf")
+	case OpenRC:
+		return filepath.Join("/etc/init.d", name)
+	default:
+		return ""
+	}
+}
+
+// NextSteps returns the commands that enable the named service once its definition is installed at path
+func NextSteps(kind Kind, name, path string) []string {
+	switch kind {
+	case Systemd:
+		return []string{"systemctl daemon-reload", "systemctl enable --now " + name}
+	case Launchd:
+		return []string{"launchctl bootstrap system " + path}
+	case Supervisord:
+		return []string{"supervisorctl reread", "supervisorctl update " + name}
+	case OpenRC:
+		return []string{"rc-update add " + name + " default", "rc-service " + name + " start"}
+	default:
+		return nil
+	}
+}
+
+// Render generates the service definition of kind for spec
+func Render(kind Kind, spec Spec) (string, error) {
+	if err := spec.Validate(); err != nil {
+		return "", err
+	}
+	switch kind {
+	case Systemd:
+		return renderSystemd(spec), nil
+	case Launchd:
+		return renderLaunchd(spec), nil
+	case Supervisord:
+		return renderSupervisord(spec), nil
+	case OpenRC:
+		return renderOpenRC(spec), nil
+	default:
+		return "", fmt.Errorf("unknown service manager %q (expected systemd, launchd, supervisord or openrc)", kind)
+	}
+}
+
+func renderSystemd(spec Spec) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "[Unit]\n")
+	fmt.Fprintf(&b, "Description=%s\n", spec.Description)
+	fmt.Fprintf(&b, "Wants=network-online.target\n")
+	fmt.Fprintf(&b, "After=network-online.target\n")
+	fmt.Fprintf(&b, "\n[Service]\n")
+	// pull-watch sends READY=1 once the command is up and WATCHDOG=1 from its loop
+	fmt.Fprintf(&b, "Type=notify\n")
+	fmt.Fprintf(&b, "NotifyAccess=main\n")
+	fmt.Fprintf(&b, "ExecStart=%s\n", systemdCommandLine(spec.Args))
+	if spec.WorkingDir != "" {
+		fmt.Fprintf(&b, "WorkingDirectory=%s\n", systemdQuote(spec.WorkingDir))
+	}
+	if spec.User != "" {
+		fmt.Fprintf(&b, "User=%s\n", spec.User)
+	}
+	for _, env := range spec.Env {
+		fmt.Fprintf(&b, "Environment=%s\n", systemdQuote(env))
+	}
+	fmt.Fprintf(&b, "Restart=%s\n", spec.Restart)
+	fmt.Fprintf(&b, "RestartSec=5\n")
+	if spec.Watchdog > 0 {
+		fmt.Fprintf(&b, "WatchdogSec=%d\n", int(spec.Watchdog.Seconds()))
+	}
+	// pull-watch stops its own command, let it do so before systemd kills the group
+	fmt.Fprintf(&b, "KillMode=mixed\n")
+	if spec.Delegate {
+		fmt.Fprintf(&b, "Delegate=yes\n")
+	}
+	if spec.StopTimeout > 0 {
+		fmt.Fprintf(&b, "TimeoutStopSec=%d\n", int((spec.StopTimeout + 10*time.Second).Seconds()))
+	}
+	fmt.Fprintf(&b, "\n[Install]\n")
+	fmt.Fprintf(&b, "WantedBy=multi-user.target\n")
+	return b.String()
+}
+
+func renderLaunchd(spec Spec) string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+`)
+	fmt.Fprintf(&b, "\t<key>Label</key>\n\t<string>%s</string>\n", xmlEscape(spec.Name))
+	b.WriteString("\t<key>ProgramArguments</key>\n\t<array>\n")
+	for _, arg := range spec.Args {
+		fmt.Fprintf(&b, "\t\t<string>%s</string>\n", xmlEscape(arg))
+	}
+	b.WriteString("\t</array>\n")
+	if spec.WorkingDir != "" {
+		fmt.Fprintf(&b, "\t<key>WorkingDirectory</key>\n\t<string>%s</string>\n", xmlEscape(spec.WorkingDir))
+	}
+	if spec.User != "" {
+		fmt.Fprintf(&b, "\t<key>UserName</key>\n\t<string>%s</string>\n", xmlEscape(spec.User))
+	}
+	if len(spec.Env) > 0 {
+		b.WriteString("\t<key>EnvironmentVariables</key>\n\t<dict>\n")
+		for _, env := range spec.Env {
+			key, value, _ := strings.Cut(env, "=")
+			fmt.Fprintf(&b, "\t\t<key>%s</key>\n\t\t<string>%s</string>\n", xmlEscape(key), xmlEscape(value))
+		}
+		b.WriteString("\t</dict>\n")
+	}
+	b.WriteString("\t<key>RunAtLoad</key>\n\t<true/>\n")
+	switch spec.Restart {
+	case RestartAlways:
+		b.WriteString("\t<key>KeepAlive</key>\n\t<true/>\n")
+	case RestartOnFailure:
+		b.WriteString("\t<key>KeepAlive</key>\n\t<dict>\n\t\t<key>SuccessfulExit</key>\n\t\t<false/>\n\t</dict>\n")
+	}
+	if spec.StopTimeout > 0 {
+		fmt.Fprintf(&b, "\t<key>ExitTimeOut</key>\n\t<integer>%d</integer>\n", int((spec.StopTimeout + 10*time.Second).Seconds()))
+	}
+	b.WriteString("</dict>\n</plist>\n")
+	return b.String()
+}
+
+func renderSupervisord(spec Spec) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "[program:%s]\n", spec.Name)
+	fmt.Fprintf(&b, "command=%s\n", supervisordEscape(shellCommandLine(spec.Args)))
+	if spec.WorkingDir != "" {
+		fmt.Fprintf(&b, "directory=%s\n", supervisordEscape(spec.WorkingDir))
+	}
+	if spec.User != "" {
+		fmt.Fprintf(&b, "user=%s\n", spec.User)
+	}
+	if len(spec.Env) > 0 {
+		quoted := make([]string, len(spec.Env))
+		for i, env := range spec.Env {
+			key, value, _ := strings.Cut(env, "=")
+			quoted[i] = fmt.Sprintf("%s=\"%s\"", key, strings.ReplaceAll(value, `"`, `\"`))
+		}
+		fmt.Fprintf(&b, "environment=%s\n", supervisordEscape(strings.Join(quoted, ",")))
+	}
+	b.WriteString("autostart=true\n")
+	switch spec.Restart {
+	case RestartAlways:
+		b.WriteString("autorestart=true\n")
+	case RestartOnFailure:
+		b.WriteString("autorestart=unexpected\nexitcodes=0\n")
+	default:
+		b.WriteString("autorestart=false\n")
+	}
+	b.WriteString("stopsignal=TERM\n")
+	if spec.StopTimeout > 0 {
+		fmt.Fprintf(&b, "stopwaitsecs=%d\n", int((spec.StopTimeout + 10*time.Second).Seconds()))
+	}
+	// pull-watch stops its own command, only fall back to killing the group
+	b.WriteString("stopasgroup=false\nkillasgroup=true\n")
+	b.WriteString("redirect_stderr=true\n")
+	return b.String()
+}
+
+func renderOpenRC(spec Spec) string {
+	var b strings.Builder
+	b.WriteString("#!/sbin/openrc-run\n\n")
+	fmt.Fprintf(&b, "name=%s\n", shellQuote(spec.Name))
+	fmt.Fprintf(&b, "description=%s\n", shellQuote(spec.Description))
+	if spec.Restart == RestartNever {
+		b.WriteString("command_background=true\n")
+		b.WriteString("pidfile=\"/run/${RC_SVCNAME}.pid\"\n")
+	} else {
+		// supervise-daemon can't tell failures from clean exits, it always respawns
+		b.WriteString("supervisor=supervise-daemon\n")
+		b.WriteString("respawn_delay=5\n")
+		b.WriteString("respawn_max=0\n")
+	}
+	fmt.Fprintf(&b, "command=%s\n", shellQuote(spec.Args[0]))
+	fmt.Fprintf(&b, "command_args=%s\n", shellQuote(shellCommandLine(spec.Args[1:])))
+	if spec.WorkingDir != "" {
+		fmt.Fprintf(&b, "directory=%s\n", shellQuote(spec.WorkingDir))
+	}
+	if spec.User != "" {
+		fmt.Fprintf(&b, "command_user=%s\n", shellQuote(spec.User))
+	}
+	if spec.StopTimeout > 0 {
+		fmt.Fprintf(&b, "retry=\"TERM/%d/KILL/5\"\n", int((spec.StopTimeout + 10*time.Second).Seconds()))
+	}
+	b.WriteString("output_log=\"/var/log/${RC_SVCNAME}.log\"\n")
+	b.WriteString("error_log=\"/var/log/${RC_SVCNAME}.log\"\n")
+	if len(spec.Env) > 0 {
+		b.WriteString("\nstart_pre() {\n")
+		for _, env := range spec.Env {
+			key, value, _ := strings.Cut(env, "=")
+			fmt.Fprintf(&b, "\texport %s=%s\n", key, shellQuote(value))
+		}
+		b.WriteString("}\n")
+	}
+	b.WriteString("\ndepend() {\n\tneed net\n}\n")
+	return b.String()
+}
+
+// systemdCommandLine quotes args for ExecStart
+func systemdCommandLine(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = systemdQuote(arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// systemdQuote quotes s when needed and escapes the specifiers and variables systemd would expand
+func systemdQuote(s string) string {
+	s = strings.ReplaceAll(s, "%", "%%")
+	s = strings.ReplaceAll(s, "$", "$$")
+	if s != "" && !strings.ContainsAny(s, " \t\n\"'\\;") {
+		return s
+	}
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	return `"` + s + `"`
+}
+
+// shellCommandLine quotes args for a POSIX shell
+func shellCommandLine(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = shellQuote(arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+func shellQuote(s string) string {
+	if s != "" && strings.IndexFunc(s, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_./=:,@+", r))
+	}) < 0 {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// supervisordEscape escapes the % used by supervisord's own expansions
+func supervisordEscape(s string) string {
+	return strings.ReplaceAll(s, "%", "%%")
+}
+
+func xmlEscape(s string) string {
+	r := strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;", "'", "&apos;")
+	return r.Replace(s)
+}
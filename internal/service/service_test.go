@@ -0,0 +1,110 @@
+package service
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func testSpec() Spec {
+	return Spec{
+		Name:        "pull-watch-shop",
+		Description: "pull-watch for /srv/shop",
+		Args:        []string{"/usr/local/bin/pull-watch", "-interval=30s", "-watch-exclude=a b", "--", "sh", "-c", `echo "100%" $HOME`},
+		WorkingDir:  "/srv/shop",
+		User:        "deploy",
+		Restart:     RestartAlways,
+		Env:         []string{"PATH=/usr/local/bin:/usr/bin"},
+		Watchdog:    time.Minute,
+		StopTimeout: 5 * time.Second,
+		Delegate:    true,
+	}
+}
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		kind Kind
+		want []string
+	}{
+		{
+			kind: Systemd,
+			want: []string{
+				"Type=notify\n",
+				`ExecStart=/usr/local/bin/pull-watch -interval=30s "-watch-exclude=a b" -- sh -c "echo \"100%%\" $$HOME"` + "\n",
+				"WorkingDirectory=/srv/shop\n",
+				"User=deploy\n",
+				"Restart=always\n",
+				"WatchdogSec=60\n",
+				"Delegate=yes\n",
+				"TimeoutStopSec=15\n",
+			},
+		},
+		{
+			kind: Launchd,
+			want: []string{
+				"<string>-watch-exclude=a b</string>",
+				"<string>echo &quot;100%&quot; $HOME</string>",
+				"<key>UserName</key>\n\t<string>deploy</string>",
+				"<key>KeepAlive</key>\n\t<true/>",
+			},
+		},
+		{
+			kind: Supervisord,
+			want: []string{
+				"[program:pull-watch-shop]\n",
+				`command=/usr/local/bin/pull-watch -interval=30s '-watch-exclude=a b' -- sh -c 'echo "100%%" $HOME'` + "\n",
+				"user=deploy\n",
+				"autorestart=true\n",
+				`environment=PATH="/usr/local/bin:/usr/bin"` + "\n",
+			},
+		},
+		{
+			kind: OpenRC,
+			want: []string{
+				"#!/sbin/openrc-run\n",
+				"supervisor=supervise-daemon\n",
+				"command=/usr/local/bin/pull-watch\n",
+				`command_args='-interval=30s '\''-watch-exclude=a b'\'' -- sh -c '\''echo "100%" $HOME'\'''` + "\n",
+				"command_user=deploy\n",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.kind), func(t *testing.T) {
+			got, err := Render(tt.kind, testSpec())
+			if err != nil {
+				t.Fatalf("Render() error = %v", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("Render() missing %q in:\n%s", want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestRender_RestartPolicies(t *testing.T) {
+	spec := testSpec()
+	spec.Restart = RestartOnFailure
+
+	got, _ := Render(Supervisord, spec)
+	if !strings.Contains(got, "autorestart=unexpected\n") {
+		t.Errorf("supervisord on-failure policy missing:\n%s", got)
+	}
+
+	spec.Restart = RestartNever
+	got, _ = Render(OpenRC, spec)
+	if strings.Contains(got, "supervise-daemon") || !strings.Contains(got, "command_background=true\n") {
+		t.Errorf("openrc without restarts should not be supervised:\n%s", got)
+	}
+
+	spec.Restart = "sometimes"
+	if _, err := Render(Systemd, spec); err == nil {
+		t.Error("Render() should reject an unknown restart policy")
+	}
+	if _, err := Render(Kind("upstart"), testSpec()); err == nil {
+		t.Error("Render() should reject an unknown service manager")
+	}
+}
@@ -0,0 +1,121 @@
+package watcher
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ship-digital/pull-watch/internal/logger"
+)
+
+// TreeOptions configures a working tree watcher
+type TreeOptions struct {
+	// Include restricts notifications to files matching at least one glob (all files when empty)
+	Include []string
+	// Exclude drops files and directories matching any glob
+	Exclude []string
+	// Debounce is how long the tree must be quiet before a change is emitted
+	Debounce time.Duration
+	// Ignored reports whether a path is ignored by git, nil disables the check
+	Ignored func(path string) bool
+}
+
+// NewTreeWatcher watches every directory of the working tree under root.
+// Globs are matched against slash separated paths relative to root and support '**'.
+// Patterns without a slash match the base name at any depth, like in .gitignore.
+func NewTreeWatcher(root string, opts TreeOptions, log *logger.Logger) (*Watcher, error) {
+	for _, pattern := range append(append([]string{}, opts.Include...), opts.Exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid glob %q: %w", pattern, err)
+		}
+	}
+
+	debounce := opts.Debounce
+	if debounce <= 0 {
+		debounce = defaultDebounce
+	}
+
+	w, err := newWatcher(log, debounce)
+	if err != nil {
+		return nil, err
+	}
+
+	rel := func(p string) string {
+		r, err := filepath.Rel(root, p)
+		if err != nil {
+			return filepath.ToSlash(p)
+		}
+		return filepath.ToSlash(r)
+	}
+	excluded := func(p string) bool {
+		if filepath.Base(p) == ".git" {
+			return true
+		}
+		if matchAny(opts.Exclude, rel(p)) {
+			return true
+		}
+		return opts.Ignored != nil && opts.Ignored(p)
+	}
+
+	w.skipDir = func(p string) bool {
+		return p != root && excluded(p)
+	}
+	w.match = func(p string) bool {
+		if strings.Contains(filepath.ToSlash(p), "/.git/") || excluded(p) {
+			return false
+		}
+		return len(opts.Include) == 0 || matchAny(opts.Include, rel(p))
+	}
+
+	if err := w.addRecursive(root); err != nil {
+		w.fsw.Close()
+		return nil, err
+	}
+
+	go w.loop()
+	return w, nil
+}
+
+// matchAny reports whether rel matches any of the patterns
+func matchAny(patterns []string, rel string) bool {
+	for _, pattern := range patterns {
+		if Match(pattern, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// Match reports whether the slash separated relative path matches the glob.
+// '**' matches any number of path segments, including none.
+func Match(pattern, rel string) bool {
+	pattern = strings.TrimPrefix(pattern, "./")
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, path.Base(rel))
+		return ok
+	}
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(rel, "/"))
+}
+
+func matchSegments(pattern, name []string) bool {
+	if len(pattern) == 0 {
+		return len(name) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(name); i++ {
+			if matchSegments(pattern[1:], name[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(name) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(pattern[0], name[0]); !ok {
+		return false
+	}
+	return matchSegments(pattern[1:], name[1:])
+}
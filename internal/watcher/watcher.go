@@ -0,0 +1,199 @@
+package watcher
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/ship-digital/pull-watch/internal/logger"
+)
+
+// defaultDebounce is how long the watcher waits for the filesystem to settle
+// before emitting a change, git writes several files per operation
+const defaultDebounce = 200 * time.Millisecond
+
+// Watcher emits debounced change notifications for a set of watched paths
+type Watcher struct {
+	fsw      *fsnotify.Watcher
+	logger   *logger.Logger
+	match    func(path string) bool
+	skipDir  func(path string) bool
+	debounce time.Duration
+	changes  chan struct{}
+	done     chan struct{}
+	exited   chan struct{}
+	once     sync.Once
+}
+
+// NewHeadWatcher watches HEAD, packed-refs and refs/ inside gitDir so moves of
+// the local HEAD made by other tools are noticed without polling
+func NewHeadWatcher(gitDir string, log *logger.Logger) (*Watcher, error) {
+	w, err := newWatcher(log, defaultDebounce)
+	if err != nil {
+		return nil, err
+	}
+
+	w.match = func(path string) bool {
+		if strings.HasSuffix(path, ".lock") {
+			return false
+		}
+		rel, err := filepath.Rel(gitDir, path)
+		if err != nil {
+			return false
+		}
+		rel = filepath.ToSlash(rel)
+		return rel == "HEAD" || rel == "packed-refs" || strings.HasPrefix(rel, "refs/")
+	}
+	// Only refs/ is walked; objects/ and friends would add thousands of watches
+	w.skipDir = func(path string) bool {
+		return path != gitDir && !strings.HasPrefix(filepath.ToSlash(path), filepath.ToSlash(filepath.Join(gitDir, "refs")))
+	}
+
+	if err := w.fsw.Add(gitDir); err != nil {
+		w.fsw.Close()
+		return nil, fmt.Errorf("failed to watch %s: %w", gitDir, err)
+	}
+	if err := w.addRecursive(filepath.Join(gitDir, "refs")); err != nil {
+		w.fsw.Close()
+		return nil, err
+	}
+
+	go w.loop()
+	return w, nil
+}
+
+// NewFileWatcher watches a single file. Its directory is watched rather than the
+// file itself, so the file is still followed after an editor replaces it.
+func NewFileWatcher(path string, log *logger.Logger) (*Watcher, error) {
+	w, err := newWatcher(log, defaultDebounce)
+	if err != nil {
+		return nil, err
+	}
+
+	path = filepath.Clean(path)
+	w.match = func(p string) bool {
+		return filepath.Clean(p) == path
+	}
+	w.skipDir = func(string) bool { return true }
+
+	if err := w.fsw.Add(filepath.Dir(path)); err != nil {
+		w.fsw.Close()
+		return nil, fmt.Errorf("failed to watch %s: %w", path, err)
+	}
+
+	go w.loop()
+	return w, nil
+}
+
+func newWatcher(log *logger.Logger, debounce time.Duration) (*Watcher, error) {
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file watcher: %w", err)
+	}
+	return &Watcher{
+		fsw:      fsw,
+		logger:   log,
+		match:    func(string) bool { return true },
+		skipDir:  func(string) bool { return false },
+		debounce: debounce,
+		changes:  make(chan struct{}, 1),
+		done:     make(chan struct{}),
+		exited:   make(chan struct{}),
+	}, nil
+}
+
+// Changes returns a channel that receives a value after each settled burst of changes
+func (w *Watcher) Changes() <-chan struct{} {
+	return w.changes
+}
+
+// Close stops watching and releases the underlying file descriptors.
+// It returns once the watcher stopped calling its match functions.
+func (w *Watcher) Close() error {
+	var err error
+	w.once.Do(func() {
+		close(w.done)
+		err = w.fsw.Close()
+	})
+	<-w.exited
+	return err
+}
+
+// addRecursive watches dir and every subdirectory not excluded by skipDir
+func (w *Watcher) addRecursive(dir string) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			// Directories may disappear while walking, that's fine
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if w.skipDir(path) {
+			return filepath.SkipDir
+		}
+		if err := w.fsw.Add(path); err != nil {
+			return fmt.Errorf("failed to watch %s: %w", path, err)
+		}
+		return nil
+	})
+}
+
+func (w *Watcher) loop() {
+	defer close(w.exited)
+	var timer *time.Timer
+	var fire <-chan time.Time
+
+	for {
+		select {
+		case <-w.done:
+			if timer != nil {
+				timer.Stop()
+			}
+			return
+
+		case event, ok := <-w.fsw.Events:
+			if !ok {
+				return
+			}
+			// New directories (e.g. refs/heads/feature/) need their own watch
+			if event.Has(fsnotify.Create) {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() && !w.skipDir(event.Name) {
+					if err := w.addRecursive(event.Name); err != nil {
+						w.logger.Debug("Failed to watch new directory %s: %v", event.Name, err)
+					}
+				}
+			}
+			if !w.match(event.Name) {
+				continue
+			}
+			w.logger.Debug("File change detected: %s (%s)", event.Name, event.Op)
+			if timer != nil {
+				timer.Stop()
+			}
+			timer = time.NewTimer(w.debounce)
+			fire = timer.C
+
+		case <-fire:
+			fire = nil
+			select {
+			case w.changes <- struct{}{}:
+			default:
+				// A notification is already pending
+			}
+
+		case err, ok := <-w.fsw.Errors:
+			if !ok {
+				return
+			}
+			w.logger.Warn("File watcher error: %v", err)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ship-digital/pull-watch/internal/logger"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		rel     string
+		want    bool
+	}{
+		{pattern: "*.go", rel: "main.go", want: true},
+		{pattern: "*.go", rel: "internal/runner/runner.go", want: true},
+		{pattern: "*.go", rel: "README.md", want: false},
+		{pattern: "internal/*.go", rel: "internal/main.go", want: true},
+		{pattern: "internal/*.go", rel: "internal/runner/runner.go", want: false},
+		{pattern: "internal/**/*.go", rel: "internal/runner/runner.go", want: true},
+		{pattern: "internal/**/*.go", rel: "internal/main.go", want: true},
+		{pattern: "node_modules/**", rel: "node_modules", want: true},
+		{pattern: "node_modules/**", rel: "node_modules/left-pad/index.js", want: true},
+		{pattern: "**/testdata/**", rel: "internal/git/testdata/repo/HEAD", want: true},
+		{pattern: "./cmd/*", rel: "cmd/tool", want: true},
+		{pattern: "docs/**", rel: "src/docs/index.md", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.rel, func(t *testing.T) {
+			if got := Match(tt.pattern, tt.rel); got != tt.want {
+				t.Errorf("Match(%q, %q) = %v, want %v", tt.pattern, tt.rel, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTreeWatcher(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"src", "build", ".git"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	w, err := NewTreeWatcher(root, TreeOptions{
+		Include:  []string{"*.go"},
+		Exclude:  []string{"*_test.go"},
+		Debounce: 50 * time.Millisecond,
+		Ignored: func(path string) bool {
+			return filepath.Base(path) == "build"
+		},
+	}, logger.New(logger.WithLogLevel(logger.QuietLevel)))
+	if err != nil {
+		t.Fatalf("NewTreeWatcher() error = %v", err)
+	}
+	defer w.Close()
+
+	tests := []struct {
+		name string
+		file string
+		want bool
+	}{
+		{name: "excluded file", file: "src/main_test.go", want: false},
+		{name: "not included file", file: "src/notes.txt", want: false},
+		{name: "ignored directory", file: "build/gen.go", want: false},
+		{name: "git directory", file: ".git/index.go", want: false},
+		{name: "included file", file: "src/main.go", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := os.WriteFile(filepath.Join(root, tt.file), []byte("package main\n"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			select {
+			case <-w.Changes():
+				if !tt.want {
+					t.Errorf("unexpected change notification for %s", tt.file)
+				}
+			case <-time.After(300 * time.Millisecond):
+				if tt.want {
+					t.Errorf("no change notification for %s", tt.file)
+				}
+			}
+		})
+	}
+}
+
+func TestFileWatcher(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "pull-watch.json")
+	if err := os.WriteFile(path, []byte("{}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := NewFileWatcher(path, logger.New(logger.WithLogLevel(logger.QuietLevel)))
+	if err != nil {
+		t.Fatalf("NewFileWatcher() error = %v", err)
+	}
+	defer w.Close()
+
+	expect := func(name string, want bool) {
+		t.Helper()
+		select {
+		case <-w.Changes():
+			if !want {
+				t.Errorf("%s: unexpected change notification", name)
+			}
+		case <-time.After(500 * time.Millisecond):
+			if want {
+				t.Errorf("%s: no change notification", name)
+			}
+		}
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "other.json"), []byte("{}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	expect("other file", false)
+
+	if err := os.WriteFile(path, []byte(`{"interval": "1m"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	expect("write", true)
+
+	// Editors often write a new file and rename it over the old one
+	tmp := filepath.Join(dir, ".pull-watch.json.swp")
+	if err := os.WriteFile(tmp, []byte(`{"interval": "2m"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatal(err)
+	}
+	expect("replace", true)
+}
@@ -1,17 +1,26 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"runtime/debug"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/hashicorp/cli"
 	"github.com/ship-digital/pull-watch/internal/config"
+	"github.com/ship-digital/pull-watch/internal/control"
+	"github.com/ship-digital/pull-watch/internal/history"
+	"github.com/ship-digital/pull-watch/internal/limits"
+	"github.com/ship-digital/pull-watch/internal/logfile"
 	"github.com/ship-digital/pull-watch/internal/logger"
+	"github.com/ship-digital/pull-watch/internal/notify"
+	"github.com/ship-digital/pull-watch/internal/output"
 	"github.com/ship-digital/pull-watch/internal/runner"
 )
 
@@ -20,6 +29,10 @@ var version = "dev"
 type MainCommand struct {
 	ui  cli.Ui
 	log *logger.Logger
+	// reload builds the configuration again, set by parseConfig
+	reload func() (*config.Config, error)
+	// commandOptional accepts a configuration without a command
+	commandOptional bool
 
 	// Flag values
 	pollInterval  time.Duration
@@ -28,10 +41,109 @@ type MainCommand struct {
 	verbose       bool
 	graceful      bool
 	stopTimeout   time.Duration
+	stopSignal    string
+	stopSequence  string
+	signalGroup   bool
+	reloadSignal  string
+	reloadCheck   string
+	reloadTimeout time.Duration
+	limitMemory   string
+	limitNofile   uint64
+	limitCPU      time.Duration
+	runAs         string
+	nice          int
+	ionice        string
+	cgroupMemory  string
+	cgroupCPU     float64
+	exitWithChild bool
+	maxInterval   time.Duration
+	remoteFlags
 	runOnStart    bool
 	showTimestamp bool
 	showVersion   bool
 	noRestart     bool
+	mode          string
+	watchTree     bool
+	watchInclude  listFlag
+	watchExclude  listFlag
+	watchDebounce time.Duration
+	dryRun        bool
+	noHistory     bool
+	historyFile   string
+	historyMaxMB  int
+	historyKeep   int
+	notifyURLs    listFlag
+	notifyEvents  listFlag
+	notifyTmpl    string
+	stdoutFile    string
+	stderrFile    string
+	logMaxMB      int
+	logMaxAge     time.Duration
+	logKeep       int
+	logNoCompress bool
+	outputFormat  string
+	configFile    string
+	env           repeatFlag
+	deployMode    string
+	deployDir     string
+	deployBuild   string
+	buildTimeout  time.Duration
+	deployKeep    int
+}
+
+// listFlag collects a flag that can be repeated or given as a comma separated list
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(value string) error {
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			*l = append(*l, v)
+		}
+	}
+	return nil
+}
+
+// repeatFlag collects a flag that can be repeated, values may contain commas
+type repeatFlag []string
+
+func (r *repeatFlag) String() string {
+	return strings.Join(*r, " ")
+}
+
+func (r *repeatFlag) Set(value string) error {
+	*r = append(*r, value)
+	return nil
+}
+
+// flagArgs renders the flags set in flags as arguments, skipping those keep rejects
+func flagArgs(flags *flag.FlagSet, keep func(name string) bool) []string {
+	var out []string
+	flags.Visit(func(f *flag.Flag) {
+		if !keep(f.Name) {
+			return
+		}
+		if values, ok := f.Value.(*repeatFlag); ok {
+			for _, v := range *values {
+				out = append(out, fmt.Sprintf("-%s=%s", f.Name, v))
+			}
+			return
+		}
+		out = append(out, fmt.Sprintf("-%s=%s", f.Name, f.Value.String()))
+	})
+	return out
+}
+
+func isOutputFormat(f output.Format) bool {
+	for _, known := range output.Formats {
+		if f == known {
+			return true
+		}
+	}
+	return false
 }
 
 func (c *MainCommand) setupFlags(flags *flag.FlagSet) {
@@ -41,10 +153,54 @@ func (c *MainCommand) setupFlags(flags *flag.FlagSet) {
 	flags.BoolVar(&c.quiet, "quiet", false, "Show only errors and warnings")
 	flags.BoolVar(&c.graceful, "graceful", false, "Try graceful stop before force kill")
 	flags.DurationVar(&c.stopTimeout, "stop-timeout", 5*time.Second, "Timeout for graceful stop before force kill")
+	flags.StringVar(&c.stopSignal, "stop-signal", "", "Signal of a graceful stop, e.g. SIGINT for node or SIGQUIT for nginx (default SIGTERM, implies -graceful)")
+	flags.StringVar(&c.stopSequence, "stop-sequence", "", "Escalating stop `steps` like 'SIGINT:10s,SIGTERM:5s,SIGKILL', overrides -graceful, -stop-signal and -stop-timeout")
+	flags.BoolVar(&c.signalGroup, "signal-group", true, "Send stop signals to the command's whole process group, false signals only the command itself")
+	flags.StringVar(&c.reloadSignal, "reload-signal", "", "After changes, send this signal (e.g. SIGHUP) to the command itself so it reloads in place, it is restarted if it exits or fails -reload-check")
+	flags.StringVar(&c.reloadCheck, "reload-check", "", "Shell `command` that succeeds once the reloaded command is ready, e.g. 'curl -fs localhost:8080/health'")
+	flags.DurationVar(&c.reloadTimeout, "reload-timeout", 10*time.Second, "How long -reload-check may take to pass before the command is restarted")
+	flags.StringVar(&c.limitMemory, "limit-memory", "", "Cap the command's address space (RLIMIT_AS) at this `size`, e.g. 512M")
+	flags.Uint64Var(&c.limitNofile, "limit-nofile", 0, "Cap the number of files the command can open (RLIMIT_NOFILE)")
+	flags.DurationVar(&c.limitCPU, "limit-cpu", 0, "Cap the CPU time of the command (RLIMIT_CPU), it is killed once used up")
+	flags.StringVar(&c.runAs, "run-as", "", "Run the command as `user[:group]` (name or id), pull-watch needs to run as root")
+	flags.IntVar(&c.nice, "nice", 0, "Scheduling priority of the command, from -20 (highest) to 19 (lowest)")
+	flags.StringVar(&c.ionice, "ionice", "", "I/O scheduling `class[:level]` of the command: realtime, best-effort or idle, with a level from 0 (highest) to 7 (Linux only)")
+	flags.StringVar(&c.cgroupMemory, "cgroup-memory-max", "", "Start the command in its own cgroup with memory.max set to this `size`, OOM kills are reported distinctly (Linux only)")
+	flags.Float64Var(&c.cgroupCPU, "cgroup-cpu-max", 0, "Start the command in its own cgroup limited to this many `CPUs`, e.g. 1.5 (Linux only)")
+	flags.BoolVar(&c.exitWithChild, "exit-with-child", false, "Exit with the command's status when it exits on its own instead of waiting for changes, 128+N when killed by signal N")
 	flags.BoolVar(&c.runOnStart, "run-on-start", false, "Run command on startup regardless of git state")
 	flags.BoolVar(&c.showTimestamp, "timestamp", false, "Show timestamps in logs")
 	flags.BoolVar(&c.showVersion, "version", false, "Show version information")
 	flags.BoolVar(&c.noRestart, "no-restart", false, "Pull changes without restarting the command, useful if the command has a built-in auto-reload feature")
+	flags.BoolVar(&c.watchTree, "watch", false, "Also restart the command when files in the working tree change (respects .gitignore)")
+	flags.Var(&c.watchInclude, "watch-include", "Only react to working tree files matching these `globs` (comma separated or repeated, supports **)")
+	flags.Var(&c.watchExclude, "watch-exclude", "Ignore working tree files matching these `globs` (comma separated or repeated, supports **)")
+	flags.DurationVar(&c.watchDebounce, "watch-debounce", 500*time.Millisecond, "Quiet period after the last working tree change before restarting")
+	flags.BoolVar(&c.dryRun, "dry-run", false, "Log the git commands and process actions that would run, without pulling or starting anything")
+	flags.BoolVar(&c.noHistory, "no-history", false, "Don't record changes, pulls and restarts to the history file")
+	flags.StringVar(&c.historyFile, "history-file", "", "History file location (default \"<git dir>/pull-watch/history.jsonl\")")
+	flags.IntVar(&c.historyMaxMB, "history-max-mb", history.DefaultMaxSize/(1024*1024), "Rotate the history file when it grows larger than this many megabytes")
+	flags.IntVar(&c.historyKeep, "history-keep", history.DefaultMaxFiles, "Number of rotated history files to keep")
+	flags.Var(&c.notifyURLs, "notify", "Send notifications to this webhook `[format=]url`, format is json (default), slack, discord or teams (repeatable)")
+	flags.Var(&c.notifyEvents, "notify-events", "Event `types` to notify about: update, restart, reload, crash, exit, rollback, error, change, start (default \"update,crash,rollback,error\")")
+	flags.StringVar(&c.notifyTmpl, "notify-template", notify.DefaultTemplate, "Go `template` for notification messages, fields: .Host .Repository .Type .Commit .ShortCommit .Subject .PID .Outcome .Error .Exit .Summary")
+	flags.StringVar(&c.stdoutFile, "stdout-file", "", "Write the command's stdout to this `file` instead of the terminal")
+	flags.StringVar(&c.stderrFile, "stderr-file", "", "Write the command's stderr to this `file` instead of the terminal (use the -stdout-file path for a combined log)")
+	flags.IntVar(&c.logMaxMB, "log-max-mb", logfile.DefaultMaxSize/(1024*1024), "Rotate command output files when they grow larger than this many megabytes")
+	flags.DurationVar(&c.logMaxAge, "log-max-age", 0, "Rotate command output files when they get older than this (e.g. 24h, 0 disables)")
+	flags.IntVar(&c.logKeep, "log-keep", logfile.DefaultMaxFiles, "Number of rotated command output files to keep")
+	flags.BoolVar(&c.logNoCompress, "log-no-compress", false, "Don't gzip rotated command output files")
+	flags.StringVar(&c.outputFormat, "output-format", string(output.FormatRaw), "Command output format: 'raw', 'prefixed' (each line tagged with commit, PID, stream and time) or 'json' (one record per line)")
+	flags.StringVar(&c.configFile, "config", "", "Read settings from this JSON `file`, reloaded when it changes or on SIGHUP (command line flags win)")
+	flags.Var(&c.env, "env", "Set this `KEY=VALUE` in the command's environment (repeatable)")
+	flags.StringVar(&c.mode, "mode", config.ModeRemote, "Change detection mode: 'remote' pulls from upstream, 'local' restarts when the local HEAD moves without contacting the remote")
+	flags.StringVar(&c.deployMode, "deploy", config.DeployInPlace, "How new commits go live: 'in-place' pulls into the working tree, 'worktree' checks each one out in a git worktree of its own, switches a current symlink to it and restarts the command there")
+	flags.StringVar(&c.deployDir, "deploy-dir", "", "`Directory` holding the worktrees and the current symlink (default \"<git dir>/pull-watch/deploys\")")
+	flags.StringVar(&c.deployBuild, "build", "", "Shell `command` run in a new worktree before it goes live, a failed build leaves the live one running (needs -deploy worktree)")
+	flags.DurationVar(&c.buildTimeout, "build-timeout", 10*time.Minute, "How long -build may take, 0 for no limit")
+	flags.IntVar(&c.deployKeep, "deploy-keep", 3, "Number of worktrees to keep for rolling back, the live one included")
+	c.remoteFlags.setupFlags(flags)
+	flags.DurationVar(&c.maxInterval, "max-interval", 5*time.Minute, "Longest poll interval while the remote is unreachable, checks back off from -interval up to it")
 }
 
 func (c *MainCommand) Run(args []string) int {
@@ -54,26 +210,49 @@ func (c *MainCommand) Run(args []string) int {
 		return 0
 	}
 
-	// Find the index of "--" separator
-	cmdIndex := -1
-	for i, arg := range args {
-		if arg == "--" {
-			cmdIndex = i
-			break
-		}
+	cfg, code := c.parseConfig(args, c.setupFlags, c.Help)
+	if cfg == nil {
+		return code
+	}
+
+	return watchExitCode(c.ui, runner.Run(cfg, runner.WithReload(c.reload)))
+}
+
+// watchExitCode returns the exit code for the outcome of runner.Run, the
+// command's own code when it exited with -exit-with-child
+func watchExitCode(ui cli.Ui, err error) int {
+	var exitErr *runner.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.Status.Code
+	}
+	if err != nil {
+		reportError(ui, err)
+		return 1
+	}
+	return 0
+}
+
+// Errors that show the usage
+var (
+	errNoSeparator = errors.New("command separator '--' not found")
+	errNoCommand   = errors.New("no command provided")
+)
+
+// parseConfig parses the flags registered by setup, the config file they name and
+// the command after "--". It returns a nil config and the exit code when there
+// is nothing to run.
+func (c *MainCommand) parseConfig(args []string, setup func(*flag.FlagSet), help func() string) (*config.Config, int) {
+	flagArgs, cmdArgs, found := splitArgs(args)
+	if !found {
+		cmdArgs = nil
 	}
 
 	// Parse flags first
 	flags := flag.NewFlagSet("pull-watch", flag.ContinueOnError)
 	flags.SetOutput(io.Discard) // Suppress flag errors
-	c.setupFlags(flags)
-	// Parse flags before "--" or all flags if no "--" found
-	flagArgs := args
-	if cmdIndex != -1 {
-		flagArgs = args[:cmdIndex]
-	}
+	setup(flags)
 	if err := flags.Parse(flagArgs); err != nil {
-		return 1
+		return nil, 1
 	}
 
 	// Handle version flag
@@ -82,32 +261,137 @@ func (c *MainCommand) Run(args []string) int {
 			Version: version,
 			ui:      c.ui,
 		}
-		return versionCmd.Run(nil)
+		return nil, versionCmd.Run(nil)
 	}
 
-	if cmdIndex == -1 {
-		c.ui.Error("Error: command separator '--' not found")
-		c.ui.Output(c.Help())
-		return 1
+	// Before newConfig, which marks the flags set by the config file as given
+	reload := c.reloader(flags, cmdArgs, found)
+	cfg, err := c.newConfig(flags, cmdArgs, found)
+	if err != nil {
+		c.ui.Error(fmt.Sprintf("Error: %v", err))
+		if errors.Is(err, errNoSeparator) || errors.Is(err, errNoCommand) {
+			c.ui.Output(help())
+		}
+		return nil, 1
 	}
+	c.reload = reload
 
-	// Get command and its args after "--"
-	cmdArgs := args[cmdIndex+1:]
-	if len(cmdArgs) == 0 {
-		c.ui.Error("Error: no command provided")
-		c.ui.Output(c.Help())
-		return 1
+	if c.verbose && c.quiet {
+		c.log.MultiColor(logger.QuietLevel,
+			logger.ErrorSegment("Warning: "),
+			logger.InfoSegment("both "),
+			logger.HighlightSegment("-verbose"),
+			logger.InfoSegment(" and "),
+			logger.HighlightSegment("-quiet"),
+			logger.InfoSegment(" flags set. Only "),
+			logger.HighlightSegment("-verbose"),
+			logger.InfoSegment(" considered!"),
+		)
+	}
+
+	return cfg, 0
+}
+
+// reloader returns a function that builds the configuration again from the same
+// command line, picking up the changes made to the config file since
+func (c *MainCommand) reloader(flags *flag.FlagSet, cmdArgs []string, found bool) func() (*config.Config, error) {
+	// Subcommands add flags of their own, only the main ones matter here
+	main := flag.NewFlagSet("pull-watch", flag.ContinueOnError)
+	(&MainCommand{}).setupFlags(main)
+	args := flagArgs(flags, func(name string) bool {
+		return main.Lookup(name) != nil
+	})
+
+	return func() (*config.Config, error) {
+		fresh := &MainCommand{ui: c.ui}
+		flags := flag.NewFlagSet("pull-watch", flag.ContinueOnError)
+		flags.SetOutput(io.Discard)
+		fresh.setupFlags(flags)
+		if err := flags.Parse(args); err != nil {
+			return nil, err
+		}
+		return fresh.newConfig(flags, cmdArgs, found)
+	}
+}
+
+// newConfig builds the configuration from the parsed flags, the config file they
+// name and the command after "--", which takes precedence over the file's
+func (c *MainCommand) newConfig(flags *flag.FlagSet, cmdArgs []string, found bool) (*config.Config, error) {
+	var configFile string
+	if c.configFile != "" {
+		path, err := filepath.Abs(c.configFile)
+		if err != nil {
+			return nil, err
+		}
+		configFile = path
+		fileCommand, err := applyConfigFile(flags, configFile)
+		if err != nil {
+			return nil, err
+		}
+		if len(cmdArgs) == 0 {
+			cmdArgs = fileCommand
+		}
 	}
 
-	// quietVerbose indicates that the user passed in both flags
-	quietVerbose := false
+	if len(cmdArgs) == 0 && !c.commandOptional {
+		if !found && configFile == "" {
+			return nil, errNoSeparator
+		}
+		return nil, errNoCommand
+	}
+
+	if !isOutputFormat(output.Format(c.outputFormat)) {
+		return nil, fmt.Errorf("invalid output format %q (expected \"raw\", \"prefixed\" or \"json\")", c.outputFormat)
+	}
+
+	if c.mode != config.ModeRemote && c.mode != config.ModeLocal {
+		return nil, fmt.Errorf("invalid mode %q (expected %q or %q)", c.mode, config.ModeRemote, config.ModeLocal)
+	}
+
+	deploy, err := c.deploy()
+	if err != nil {
+		return nil, err
+	}
+
+	var stopSignal string
+	if c.stopSignal != "" {
+		signal, err := runner.CanonicalSignal(c.stopSignal)
+		if err != nil {
+			return nil, fmt.Errorf("invalid -stop-signal: %w", err)
+		}
+		stopSignal = signal
+	}
+	var reloadSignal string
+	if c.reloadSignal != "" {
+		signal, err := runner.CanonicalSignal(c.reloadSignal)
+		if err != nil {
+			return nil, fmt.Errorf("invalid -reload-signal: %w", err)
+		}
+		reloadSignal = signal
+	}
+	var stopSequence []config.StopStep
+	if c.stopSequence != "" {
+		steps, err := runner.ParseStopSequence(c.stopSequence)
+		if err != nil {
+			return nil, fmt.Errorf("invalid -stop-sequence: %w", err)
+		}
+		stopSequence = steps
+	}
+
+	resourceLimits, err := c.resourceLimits()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, kv := range c.env {
+		if name, _, ok := strings.Cut(kv, "="); !ok || name == "" {
+			return nil, fmt.Errorf("invalid environment variable %q (expected KEY=VALUE)", kv)
+		}
+	}
 
-	// Determine log level
+	// Determine log level, -verbose wins over -quiet
 	var logLevel logger.LogLevel
 	switch {
-	case c.verbose && c.quiet:
-		quietVerbose = true
-		logLevel = logger.VerboseLevel
 	case c.verbose:
 		logLevel = logger.VerboseLevel
 	case c.quiet:
@@ -126,37 +410,127 @@ func (c *MainCommand) Run(args []string) int {
 	c.log = logger.New(opts...)
 
 	cfg := &config.Config{
-		PollInterval:  c.pollInterval,
-		Command:       cmdArgs,
-		GitDir:        c.gitDir,
-		LogLevel:      logLevel,
-		GracefulStop:  c.graceful,
-		StopTimeout:   c.stopTimeout,
-		Logger:        c.log,
-		RunOnStart:    c.runOnStart,
-		ShowTimestamp: c.showTimestamp,
-		NoRestart:     c.noRestart,
-	}
-
-	if quietVerbose {
-		c.log.MultiColor(logger.QuietLevel,
-			logger.ErrorSegment("Warning: "),
-			logger.InfoSegment("both "),
-			logger.HighlightSegment("-verbose"),
-			logger.InfoSegment(" and "),
-			logger.HighlightSegment("-quiet"),
-			logger.InfoSegment(" flags set. Only "),
-			logger.HighlightSegment("-verbose"),
-			logger.InfoSegment(" considered!"),
-		)
+		PollInterval:   c.pollInterval,
+		Command:        cmdArgs,
+		Env:            c.env,
+		GitDir:         c.gitDir,
+		LogLevel:       logLevel,
+		GracefulStop:   c.graceful,
+		StopTimeout:    c.stopTimeout,
+		StopSignal:     stopSignal,
+		StopSequence:   stopSequence,
+		SignalLeader:   !c.signalGroup,
+		ReloadSignal:   reloadSignal,
+		ReloadCheck:    c.reloadCheck,
+		ReloadTimeout:  c.reloadTimeout,
+		Limits:         resourceLimits,
+		ExitWithChild:  c.exitWithChild,
+		MaxInterval:    c.maxInterval,
+		Logger:         c.log,
+		RunOnStart:     c.runOnStart,
+		ShowTimestamp:  c.showTimestamp,
+		NoRestart:      c.noRestart,
+		Mode:           c.mode,
+		WatchTree:      c.watchTree,
+		WatchInclude:   c.watchInclude,
+		WatchExclude:   c.watchExclude,
+		WatchDebounce:  c.watchDebounce,
+		DryRun:         c.dryRun,
+		History:        !c.noHistory,
+		HistoryFile:    c.historyFile,
+		HistoryMaxMB:   c.historyMaxMB,
+		HistoryKeep:    c.historyKeep,
+		NotifyURLs:     c.notifyURLs,
+		NotifyEvents:   c.notifyEvents,
+		NotifyTemplate: c.notifyTmpl,
+		StdoutFile:     c.stdoutFile,
+		StderrFile:     c.stderrFile,
+		LogMaxMB:       c.logMaxMB,
+		LogMaxAge:      c.logMaxAge,
+		LogKeep:        c.logKeep,
+		LogCompress:    !c.logNoCompress,
+		OutputFormat:   output.Format(c.outputFormat),
+		ConfigFile:     configFile,
+		Deploy:         deploy,
+	}
+	if err := c.remoteFlags.apply(cfg); err != nil {
+		return nil, err
 	}
+	return cfg, nil
+}
 
-	if err := runner.Run(cfg); err != nil {
-		c.ui.Error(fmt.Sprintf("Error: %v", err))
-		return 1
+// deploy validates how new commits go live, worktree deploys can't follow
+// changes that don't come with a new commit
+func (c *MainCommand) deploy() (config.Deploy, error) {
+	d := config.Deploy{Mode: c.deployMode, Build: c.deployBuild, BuildTimeout: c.buildTimeout, Keep: c.deployKeep}
+	switch c.deployMode {
+	case config.DeployInPlace:
+		if c.deployBuild != "" || c.deployDir != "" {
+			return d, errors.New("-build and -deploy-dir need -deploy worktree")
+		}
+		return d, nil
+	case config.DeployWorktree:
+	default:
+		return d, fmt.Errorf("invalid -deploy %q (expected %q or %q)", c.deployMode, config.DeployInPlace, config.DeployWorktree)
+	}
+	if c.deployKeep < 1 {
+		return d, fmt.Errorf("invalid -deploy-keep %d (the live worktree is always kept)", c.deployKeep)
+	}
+	for _, conflict := range []struct {
+		flag string
+		set  bool
+	}{
+		{"-reload-signal", c.reloadSignal != ""},
+		{"-no-restart", c.noRestart},
+		{"-watch", c.watchTree},
+		{"-submodule-remote", c.submoduleRemote},
+	} {
+		if conflict.set {
+			return d, fmt.Errorf("%s can't be used with -deploy worktree, the command runs in a new directory for every commit", conflict.flag)
+		}
 	}
+	if c.pullStrategy == config.PullRebase || c.pullStrategy == config.PullMerge {
+		return d, fmt.Errorf("-pull-strategy %s can't be used with -deploy worktree, worktrees check out the remote commit as it is", c.pullStrategy)
+	}
+	if c.deployDir != "" {
+		dir, err := filepath.Abs(c.deployDir)
+		if err != nil {
+			return d, fmt.Errorf("invalid -deploy-dir: %w", err)
+		}
+		d.Dir = dir
+	}
+	return d, nil
+}
 
-	return 0
+// resourceLimits parses the limits of the command from the flags
+func (c *MainCommand) resourceLimits() (limits.Limits, error) {
+	l := limits.Limits{
+		OpenFiles:    c.limitNofile,
+		CPUTime:      c.limitCPU,
+		RunAs:        c.runAs,
+		Nice:         c.nice,
+		CgroupCPUMax: c.cgroupCPU,
+	}
+	var err error
+	if c.limitMemory != "" {
+		if l.Memory, err = limits.ParseSize(c.limitMemory); err != nil {
+			return l, fmt.Errorf("invalid -limit-memory: %w", err)
+		}
+	}
+	if c.cgroupMemory != "" {
+		if l.CgroupMemoryMax, err = limits.ParseSize(c.cgroupMemory); err != nil {
+			return l, fmt.Errorf("invalid -cgroup-memory-max: %w", err)
+		}
+	}
+	if c.ionice != "" {
+		if l.IOClass, l.IOLevel, err = limits.ParseIONice(c.ionice); err != nil {
+			return l, fmt.Errorf("invalid -ionice: %w", err)
+		}
+	}
+	if err := l.Validate(); err != nil {
+		return l, fmt.Errorf("invalid resource limits: %w", err)
+	}
+	return l, nil
 }
 
 func (c *MainCommand) Help() string {
@@ -167,15 +541,30 @@ func (c *MainCommand) Help() string {
 	flags.SetOutput(&buf)
 	flags.PrintDefaults()
 
+	commands := subcommands(c.ui)
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var cmdBuf strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&cmdBuf, "  %-16s %s\n", name, commands[name].Synopsis())
+	}
+
 	return fmt.Sprintf(`
 Usage: pull-watch [options] -- <command>
+       pull-watch <subcommand> [options]
 
  Watch git repository for remote changes and run commands.
 
  It's like: 'git pull && <command>' but with polling and automatic process management.
 
+Subcommands:
+%s
 Options:
-%s`, buf.String())
+%s`, cmdBuf.String(), buf.String())
 }
 
 func (c *MainCommand) Synopsis() string {
@@ -204,20 +593,64 @@ func (c *VersionCommand) Synopsis() string {
 	return "Prints the pull-watch version"
 }
 
+// subcommands returns the commands that can be run as "pull-watch <name>"
+func subcommands(ui cli.Ui) map[string]cli.Command {
+	return map[string]cli.Command{
+		"version": &VersionCommand{Version: version, ui: ui},
+		"check":   &CheckCommand{ui: ui},
+		"update":  &UpdateCommand{ui: ui},
+		"history": &HistoryCommand{ui: ui},
+		"doctor":  &DoctorCommand{MainCommand: MainCommand{ui: ui}},
+		"daemon":  &DaemonCommand{MainCommand: MainCommand{ui: ui}},
+		"status": &ControlCommand{
+			ui:       ui,
+			command:  control.CommandStatus,
+			synopsis: "Show what the background daemon is doing",
+			help:     "Show the commit, process and last check of a pull-watch daemon.",
+		},
+		"stop": &ControlCommand{
+			ui:       ui,
+			command:  control.CommandStop,
+			synopsis: "Stop the background daemon and its command",
+			help:     "Stop the command run by a pull-watch daemon, then the daemon itself.",
+		},
+		"restart": &ControlCommand{
+			ui:       ui,
+			command:  control.CommandRestart,
+			synopsis: "Restart the command run by the background daemon",
+			help:     "Restart the command run by a pull-watch daemon without waiting for changes.",
+		},
+		"reload": &ControlCommand{
+			ui:       ui,
+			command:  control.CommandReload,
+			synopsis: "Make the background daemon reload its configuration",
+			help:     "Make a pull-watch daemon read its configuration again, like SIGHUP does, and check for changes right away.",
+		},
+		"logs":            &LogsCommand{ui: ui},
+		"install-service": &InstallServiceCommand{MainCommand: MainCommand{ui: ui}},
+	}
+}
+
 func main() {
+	// pull-watch re-executes itself to set the command's limits before exec
+	limits.RunShim(os.Args)
+
 	ui := &cli.BasicUi{
 		Reader:      os.Stdin,
 		Writer:      os.Stdout,
 		ErrorWriter: os.Stderr,
 	}
 
-	// Handle version subcommand
-	if len(os.Args) > 1 && os.Args[1] == "version" {
-		versionCmd := &VersionCommand{
-			Version: version,
-			ui:      ui,
+	// Handle subcommands
+	if len(os.Args) > 1 {
+		if cmd, ok := subcommands(ui)[os.Args[1]]; ok {
+			args := os.Args[2:]
+			if len(args) > 0 && (args[0] == "-h" || args[0] == "--help") {
+				ui.Output(cmd.Help())
+				os.Exit(0)
+			}
+			os.Exit(cmd.Run(args))
 		}
-		os.Exit(versionCmd.Run(nil))
 	}
 
 	// Create the command directly
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/hashicorp/cli"
+	"github.com/ship-digital/pull-watch/internal/config"
+	"github.com/ship-digital/pull-watch/internal/logger"
+	"github.com/ship-digital/pull-watch/internal/runner"
+)
+
+type UpdateCommand struct {
+	ui cli.Ui
+
+	commonFlags
+	remoteFlags
+	runOnStart bool
+}
+
+func (c *UpdateCommand) setupFlags(flags *flag.FlagSet) {
+	c.commonFlags.setupFlags(flags)
+	c.remoteFlags.setupFlags(flags)
+	flags.BoolVar(&c.runOnStart, "run-on-start", false, "Run the command even if no changes were pulled")
+}
+
+func (c *UpdateCommand) Run(args []string) int {
+	flagArgs, cmdArgs, _ := splitArgs(args)
+
+	flags := flag.NewFlagSet("update", flag.ContinueOnError)
+	c.setupFlags(flags)
+	if err := parseFlags(c.ui, flags, flagArgs); err != nil {
+		return 1
+	}
+
+	logLevel := c.logLevel(logger.DefaultLevel)
+	cfg := &config.Config{
+		Command:    cmdArgs,
+		GitDir:     c.gitDir,
+		LogLevel:   logLevel,
+		Logger:     c.newLogger(logLevel),
+		RunOnStart: c.runOnStart,
+	}
+	if err := c.remoteFlags.apply(cfg); err != nil {
+		c.ui.Error(fmt.Sprintf("Error: %v", err))
+		return 1
+	}
+
+	exitCode, err := runner.Update(cfg)
+	if err != nil {
+		reportError(c.ui, err)
+	}
+	return exitCode
+}
+
+func (c *UpdateCommand) Help() string {
+	return fmt.Sprintf(`
+Usage: pull-watch update [options] [-- <command>]
+
+ Pull remote changes once and run the command if anything was pulled.
+
+ Exits with the command's exit status, 0 if there was nothing to do,
+ or 1 if the update itself failed.
+
+Options:
+%s`, flagDefaults(c.setupFlags))
+}
+
+func (c *UpdateCommand) Synopsis() string {
+	return "Pull once, run the command if something changed, and exit"
+}